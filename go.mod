@@ -4,6 +4,7 @@ go 1.22.0
 
 require (
 	github.com/alicebob/miniredis/v2 v2.33.0
+	github.com/andybalholm/brotli v1.2.0
 	github.com/go-redis/redis/v8 v8.11.5
 	github.com/sirupsen/logrus v1.9.3
 	golang.org/x/time v0.8.0
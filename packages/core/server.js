@@ -36,6 +36,11 @@ async function createServer() {
     )
   }
 
+  // Lightweight liveness route probed by the Go worker pool
+  app.get("/__blastra/health", (req, res) => {
+    res.status(200).end("OK")
+  })
+
   app.use("*", async (req, res, next) => {
     const url = req.originalUrl
     try {
@@ -64,15 +69,24 @@ async function createServer() {
     }
   })
 
-  const port = process.env.PORT || 5173
+  // The Go worker pool passes a Unix socket path instead of a port when using the unix transport
+  const port = process.env.BLASTRA_SOCKET || process.env.PORT || 5173
+  const address = process.env.BLASTRA_SOCKET ? `unix:${port}` : `http://localhost:${port}`
 
   // Store active connections
   const connections = new Set()
 
+  // Import the SSR bundle up front so the worker only reports ready once it can render
+  if (isProd) {
+    await import(resolve(process.cwd(), "dist/server/entry-server.js"))
+  }
+
   // Create HTTP server instance
   const server = app.listen(port, () => {
     const mode = isProd ? "production" : "development"
-    console.log(`Server running at http://localhost:${port} (${mode} mode)`)
+    console.log(`Server running at ${address} (${mode} mode)`)
+    // Readiness marker watched by the Go worker pool (BLASTRA_WORKER_READY_METHOD=stdout)
+    console.log(process.env.BLASTRA_WORKER_READY_PATTERN || "BLASTRA_READY")
   })
 
   // Track connections
@@ -1,8 +1,17 @@
 package cache
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
 	"time"
+
+	log "github.com/sirupsen/logrus"
 )
 
 var (
@@ -11,15 +20,66 @@ var (
 
 // CacheEntry represents a generic cache entry that can be used by any cache implementation
 type CacheEntry struct {
-	Content     []byte
+	Content     []byte            // Raw body, nil when only compressed variants are stored (see Body)
+	Encodings   map[string][]byte // Body compressed by content coding, see Compress
 	LastUpdated time.Time
 	ETag        string
+	Status      int         // Response status, 0 means the cache's default (200, or 404 for the 404 cache)
+	Header      http.Header // Response headers replayed on hits
+	Tags        []string    // Cache tags the entry can be purged by
+
+	// Freshness. Past ExpiresAt the entry is stale, but caches keep it for the
+	// longest of the stale windows so it can still be served while it is
+	// refreshed, or when refreshing it fails. A zero ExpiresAt never expires.
+	ExpiresAt            time.Time
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+// NewCacheEntry creates an entry for content, with an ETag derived from it
+func NewCacheEntry(content []byte) CacheEntry {
+	hasher := sha256.New()
+	hasher.Write(content)
+	return CacheEntry{
+		Content:     content,
+		LastUpdated: time.Now(),
+		ETag:        `"` + hex.EncodeToString(hasher.Sum(nil)) + `"`,
+	}
+}
+
+// Fresh reports whether the entry is within its TTL
+func (e CacheEntry) Fresh(now time.Time) bool {
+	return e.ExpiresAt.IsZero() || now.Before(e.ExpiresAt)
+}
+
+// StaleWhileRevalidating reports whether a stale entry may be served while it is refreshed
+func (e CacheEntry) StaleWhileRevalidating(now time.Time) bool {
+	return now.Before(e.ExpiresAt.Add(e.StaleWhileRevalidate))
+}
+
+// StaleOnError reports whether a stale entry may be served when refreshing it fails
+func (e CacheEntry) StaleOnError(now time.Time) bool {
+	return now.Before(e.ExpiresAt.Add(e.StaleIfError))
+}
+
+// HardExpiry returns when the entry can no longer be served at all
+func (e CacheEntry) HardExpiry() time.Time {
+	return e.ExpiresAt.Add(max(e.StaleWhileRevalidate, e.StaleIfError))
+}
+
+// Expired reports whether the entry is past its hard expiry
+func (e CacheEntry) Expired(now time.Time) bool {
+	return !e.ExpiresAt.IsZero() && !now.Before(e.HardExpiry())
 }
 
 // Cache defines the interface that all cache implementations must satisfy
 type Cache interface {
 	Get(key string) (CacheEntry, bool)
 	Set(key string, content []byte)
+	SetEntry(key string, entry CacheEntry, ttl time.Duration) // Stores an entry fresh for ttl (0 uses the cache's TTL, unless the entry carries its expiry)
+	Delete(key string) bool                                   // Removes an entry, reporting whether it existed
+	DeletePrefix(prefix string) int                           // Removes all entries whose key starts with prefix, returning how many
+	DeleteTag(tag string) int                                 // Removes all entries tagged with tag, returning how many
 	GetMetrics() map[string]interface{}
 }
 
@@ -36,6 +96,32 @@ const (
 type CacheConfig struct {
 	TTL     time.Duration
 	MaxSize int
+
+	// Total size budget of in-memory caches, 0 for no limit. It is split
+	// between up to 16 shards, and entries larger than the budget of a shard,
+	// 1/16 of MaxBytes in caches of 1024 entries or more, aren't cached.
+	MaxBytes int64
+
+	// Default stale windows for entries that don't carry their own
+	StaleWhileRevalidate time.Duration
+	StaleIfError         time.Duration
+}
+
+// stamp sets the expiry of an entry stored for ttl, and fills in the freshness
+// it doesn't carry yet from the cache configuration
+func (c CacheConfig) stamp(entry CacheEntry, ttl time.Duration) CacheEntry {
+	if ttl > 0 {
+		entry.ExpiresAt = entry.LastUpdated.Add(ttl)
+	} else if entry.ExpiresAt.IsZero() && c.TTL > 0 {
+		entry.ExpiresAt = entry.LastUpdated.Add(c.TTL)
+	}
+	if entry.StaleWhileRevalidate == 0 {
+		entry.StaleWhileRevalidate = c.StaleWhileRevalidate
+	}
+	if entry.StaleIfError == 0 {
+		entry.StaleIfError = c.StaleIfError
+	}
+	return entry
 }
 
 // ExternalCacheConfig represents configuration specific to external caches
@@ -44,18 +130,46 @@ type ExternalCacheConfig struct {
 	Type ExternalCacheType
 
 	// Redis specific config
-	RedisURL      string
-	RedisPassword string
-	RedisDB       int
+	RedisURL              string    // host:port or redis:// / rediss:// URL, comma separated for Sentinel and Cluster
+	RedisMode             RedisMode // standalone (default), sentinel or cluster
+	RedisUsername         string    // ACL user
+	RedisPassword         string
+	RedisDB               int
+	RedisSentinelMaster   string // Name of the master monitored by Sentinel
+	RedisSentinelPassword string
+	RedisTLS              bool   // Connect over TLS, implied by rediss:// URLs
+	RedisTLSCAFile        string // PEM file of the CA to verify the servers against instead of the system ones
+	RedisTLSSkipVerify    bool
+	RedisPoolSize         int           // Connections per node, 0 for the go-redis default
+	RedisDialTimeout      time.Duration // 0 for the go-redis defaults
+	RedisReadTimeout      time.Duration
+	RedisWriteTimeout     time.Duration
 
 	// Filesystem specific config
 	CacheDir string
+
+	// Resilience, so a slow or unavailable external cache doesn't hold up rendering
+	Timeout          time.Duration // Deadline of each call made while serving a request, 0 for none
+	BreakerThreshold int           // Consecutive failures after which the cache is bypassed, 0 for DefaultBreakerThreshold
+	BreakerCooldown  time.Duration // How long it is bypassed before it is probed again, 0 for DefaultBreakerCooldown
+	WriteQueueSize   int           // Writes made in the background, see UseWriteBehind, 0 writes synchronously
 }
 
 // CacheProvider manages the cache hierarchy
 type CacheProvider struct {
 	memoryCache   Cache
 	externalCache Cache
+	writes        *writeBehind // nil writes to the external cache synchronously
+
+	bus           *InvalidationBus
+	scope         string       // Of the invalidations of this provider on the bus
+	invalidations atomic.Int64 // Invalidations received from other replicas
+
+	// Entries read from the external cache are only promoted into memory when
+	// no entry was overwritten or purged while they were read, see invalidate
+	promoting  sync.RWMutex
+	generation int64 // Bumped when an overwrite or purge starts and ends
+	changing   int   // Overwrites and purges in progress
 }
 
 func NewCacheProvider(memoryCache Cache, externalCache Cache) *CacheProvider {
@@ -68,6 +182,8 @@ func NewCacheProvider(memoryCache Cache, externalCache Cache) *CacheProvider {
 // Get retrieves an entry from the cache hierarchy
 // First checks memory cache, then external cache if available
 func (p *CacheProvider) Get(key string) (CacheEntry, bool) {
+	generation := p.currentGeneration()
+
 	// Check memory cache first if available
 	if p.memoryCache != nil {
 		if entry, found := p.memoryCache.Get(key); found {
@@ -78,9 +194,14 @@ func (p *CacheProvider) Get(key string) (CacheEntry, bool) {
 	// Check external cache if available
 	if p.externalCache != nil {
 		if entry, found := p.externalCache.Get(key); found {
-			// Store in memory cache if available
+			// Store in memory cache if available, keeping the entry's freshness. The entry
+			// is not promoted when entries were overwritten or purged meanwhile, as it may be outdated.
 			if p.memoryCache != nil {
-				p.memoryCache.Set(key, entry.Content)
+				p.promoting.RLock()
+				if p.changing == 0 && p.generation == generation {
+					p.memoryCache.SetEntry(key, entry, 0)
+				}
+				p.promoting.RUnlock()
 			}
 			return entry, true
 		}
@@ -91,12 +212,153 @@ func (p *CacheProvider) Get(key string) (CacheEntry, bool) {
 
 // Set stores an entry in all available caches
 func (p *CacheProvider) Set(key string, content []byte) {
+	if p.writes != nil {
+		p.SetEntry(key, NewCacheEntry(content), 0)
+		return
+	}
+	defer p.invalidate()()
 	if p.memoryCache != nil {
 		p.memoryCache.Set(key, content)
 	}
 	if p.externalCache != nil {
 		p.externalCache.Set(key, content)
 	}
+	p.publish(Invalidation{Kind: InvalidateKey, Value: key})
+}
+
+// SetEntry stores an entry fresh for ttl in all available caches (0 uses each cache's TTL)
+func (p *CacheProvider) SetEntry(key string, entry CacheEntry, ttl time.Duration) {
+	defer p.invalidate()()
+	if p.memoryCache != nil {
+		p.memoryCache.SetEntry(key, entry, ttl)
+	}
+	if p.writes != nil {
+		if !p.writes.enqueue(pendingWrite{key: key, entry: entry, ttl: ttl}) {
+			// The other replicas' copy is outdated, and the external cache won't get this one
+			log.Warnf("Write-behind queue is full, %s is only cached in memory", key)
+			p.publish(Invalidation{Kind: InvalidateKey, Value: key})
+		}
+		return
+	}
+	if p.externalCache != nil {
+		p.externalCache.SetEntry(key, entry, ttl)
+	}
+	p.publish(Invalidation{Kind: InvalidateKey, Value: key})
+}
+
+// UseWriteBehind makes writes to the external cache in the background, so
+// storing a render doesn't wait for it. Up to queueSize writes are queued,
+// further ones are dropped until the queue drains: the entry is then only
+// cached in memory, and other replicas drop their copy. Other replicas are
+// told about a write once it is made.
+func (p *CacheProvider) UseWriteBehind(queueSize int) {
+	if p.externalCache == nil || queueSize <= 0 {
+		return
+	}
+	p.writes = newWriteBehind(queueSize, func(w pendingWrite) {
+		defer p.invalidate()()
+		p.externalCache.SetEntry(w.key, w.entry, w.ttl)
+		p.publish(Invalidation{Kind: InvalidateKey, Value: w.key})
+	})
+}
+
+// Close makes the writes still queued for the external cache, giving up once
+// ctx is done, and stops writing in the background. It is called on shutdown,
+// after the server stopped serving.
+func (p *CacheProvider) Close(ctx context.Context) error {
+	if p.writes == nil {
+		return nil
+	}
+	left := p.writes.drain(ctx)
+	p.writes.stop()
+	if left > 0 {
+		return fmt.Errorf("%d writes to the external cache were not made: %w", left, ctx.Err())
+	}
+	return nil
+}
+
+// PurgeKey removes an entry from all available caches, returning how many held it
+func (p *CacheProvider) PurgeKey(key string) int {
+	return p.purge(Invalidation{Kind: InvalidateKey, Value: key})
+}
+
+// PurgePrefix removes all entries whose key starts with prefix from all available caches,
+// returning how many were removed
+func (p *CacheProvider) PurgePrefix(prefix string) int {
+	return p.purge(Invalidation{Kind: InvalidatePrefix, Value: prefix})
+}
+
+// PurgeTag removes all entries tagged with tag from all available caches, returning how many were removed
+func (p *CacheProvider) PurgeTag(tag string) int {
+	return p.purge(Invalidation{Kind: InvalidateTag, Value: tag})
+}
+
+// purge applies inv to the external cache, then to memory, then has the other
+// replicas drop the entries from their memory. Gets that read a purged entry
+// from the external cache before it was purged there don't promote it.
+func (p *CacheProvider) purge(inv Invalidation) int {
+	defer p.invalidate()()
+	var removed int
+	apply := func() {
+		if p.externalCache != nil {
+			removed += inv.apply(p.externalCache)
+		}
+		if p.memoryCache != nil {
+			removed += inv.apply(p.memoryCache)
+		}
+		p.publish(inv)
+	}
+	if p.writes != nil {
+		// Queued writes of purged entries must not land after the purge
+		p.writes.purge(inv, apply)
+	} else {
+		apply()
+	}
+	return removed
+}
+
+// UseInvalidationBus shares invalidations with the other replicas using the
+// same external cache: entries purged or overwritten here are evicted from
+// their memory cache, and the other way round. Only invalidations of scope,
+// published by the provider of the same name on other replicas, are applied.
+func (p *CacheProvider) UseInvalidationBus(bus *InvalidationBus, scope string) {
+	p.bus, p.scope = bus, scope
+	bus.Subscribe(scope, func(inv Invalidation) {
+		p.invalidations.Add(1)
+		defer p.invalidate()()
+		if p.memoryCache != nil {
+			inv.apply(p.memoryCache)
+		}
+	})
+}
+
+// invalidate marks the start of an overwrite or purge, returning the function
+// marking its end. Gets don't promote what they read from the external cache
+// while one is in progress, nor when one started or ended since they began.
+func (p *CacheProvider) invalidate() (done func()) {
+	p.promoting.Lock()
+	p.generation++
+	p.changing++
+	p.promoting.Unlock()
+	return func() {
+		p.promoting.Lock()
+		p.generation++
+		p.changing--
+		p.promoting.Unlock()
+	}
+}
+
+func (p *CacheProvider) currentGeneration() int64 {
+	p.promoting.RLock()
+	defer p.promoting.RUnlock()
+	return p.generation
+}
+
+func (p *CacheProvider) publish(inv Invalidation) {
+	if p.bus != nil {
+		inv.Scope = p.scope
+		p.bus.Publish(inv)
+	}
 }
 
 // GetMetrics returns combined metrics from all caches
@@ -109,6 +371,12 @@ func (p *CacheProvider) GetMetrics() map[string]interface{} {
 	if p.externalCache != nil {
 		metrics["external"] = p.externalCache.GetMetrics()
 	}
+	if p.bus != nil {
+		metrics["invalidations"] = p.invalidations.Load()
+	}
+	if p.writes != nil {
+		metrics["write_behind"] = p.writes.metrics()
+	}
 
 	return metrics
 }
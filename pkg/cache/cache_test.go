@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"testing"
 	"time"
 )
@@ -69,7 +70,7 @@ func TestCacheProvider(t *testing.T) {
 		}
 
 		// Test memory cache miss, external cache hit
-		memCache.data = make(map[string]CacheEntry) // Clear memory cache
+		memCache.Delete("key1") // Clear memory cache
 		entry, found := provider.Get("key1")
 		if !found {
 			t.Error("Expected to find entry via external cache")
@@ -121,4 +122,291 @@ func TestCacheProvider(t *testing.T) {
 			t.Errorf("Expected 1 external cache miss, got %d", extMetrics["misses"])
 		}
 	})
+
+	t.Run("per-entry ttl", func(t *testing.T) {
+		memCache := NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10})
+		provider := NewCacheProvider(memCache, nil)
+
+		provider.SetEntry("short", NewCacheEntry([]byte("content")), time.Second)
+		provider.Set("default", []byte("content"))
+
+		short, _ := provider.Get("short")
+		if ttl := short.ExpiresAt.Sub(short.LastUpdated); ttl != time.Second {
+			t.Errorf("Expected entry TTL of 1s, got %v", ttl)
+		}
+		entry, _ := provider.Get("default")
+		if ttl := entry.ExpiresAt.Sub(entry.LastUpdated); ttl != time.Minute {
+			t.Errorf("Expected cache TTL of 1m, got %v", ttl)
+		}
+	})
+
+	t.Run("promotion keeps freshness", func(t *testing.T) {
+		memCache := NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10})
+		externalCache := NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10})
+		provider := NewCacheProvider(memCache, externalCache)
+
+		entry := NewCacheEntry([]byte("content"))
+		entry.LastUpdated = time.Now().Add(-2 * time.Minute)
+		entry.StaleWhileRevalidate = time.Hour
+		externalCache.SetEntry("key1", entry, 0)
+
+		promoted, found := provider.Get("key1")
+		if !found {
+			t.Fatal("Expected to find stale entry via external cache")
+		}
+		if promoted.Fresh(time.Now()) {
+			t.Error("Expected promoted entry to stay stale")
+		}
+		if memEntry, _ := memCache.Get("key1"); !memEntry.ExpiresAt.Equal(promoted.ExpiresAt) {
+			t.Errorf("Expected memory entry to expire at %v, got %v", promoted.ExpiresAt, memEntry.ExpiresAt)
+		}
+	})
+}
+
+func TestCacheEntryFreshness(t *testing.T) {
+	now := time.Now()
+	entry := CacheEntry{
+		ExpiresAt:            now,
+		StaleWhileRevalidate: time.Minute,
+		StaleIfError:         time.Hour,
+	}
+
+	tests := []struct {
+		name                                  string
+		at                                    time.Time
+		fresh, revalidating, onError, expired bool
+	}{
+		{"before expiry", now.Add(-time.Second), true, true, true, false},
+		{"while revalidating", now.Add(30 * time.Second), false, true, true, false},
+		{"on error", now.Add(30 * time.Minute), false, false, true, false},
+		{"past hard expiry", now.Add(2 * time.Hour), false, false, false, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := entry.Fresh(tt.at); got != tt.fresh {
+				t.Errorf("Fresh() = %v, want %v", got, tt.fresh)
+			}
+			if got := entry.StaleWhileRevalidating(tt.at); got != tt.revalidating {
+				t.Errorf("StaleWhileRevalidating() = %v, want %v", got, tt.revalidating)
+			}
+			if got := entry.StaleOnError(tt.at); got != tt.onError {
+				t.Errorf("StaleOnError() = %v, want %v", got, tt.onError)
+			}
+			if got := entry.Expired(tt.at); got != tt.expired {
+				t.Errorf("Expired() = %v, want %v", got, tt.expired)
+			}
+		})
+	}
+
+	if (CacheEntry{}).Expired(now) || !(CacheEntry{}).Fresh(now) {
+		t.Error("Expected entry without expiry to stay fresh")
+	}
+}
+
+// testPurging checks a cache removes entries by key, key prefix and tag
+func testPurging(t *testing.T, c Cache) {
+	t.Helper()
+	set := func(key string, tags ...string) {
+		entry := NewCacheEntry([]byte(key))
+		entry.Tags = tags
+		c.SetEntry(key, entry, 0)
+	}
+	set("/products/42", "product-42", "products")
+	set("/products/43", "product-43", "products")
+	set("/blog/hello", "product-42")
+	set("/about")
+
+	if !c.Delete("/about") || c.Delete("/about") {
+		t.Error("Expected Delete to report whether the entry existed")
+	}
+	if n := c.DeleteTag("product-42"); n != 2 {
+		t.Errorf("Expected 2 entries tagged product-42 to be purged, got %d", n)
+	}
+	if _, found := c.Get("/blog/hello"); found {
+		t.Error("Expected tagged entry to be purged")
+	}
+	if _, found := c.Get("/products/43"); !found {
+		t.Error("Expected untagged entry to be kept")
+	}
+
+	// Replacing an entry drops the tags it no longer carries
+	set("/products/43", "products")
+	if n := c.DeleteTag("product-43"); n != 0 {
+		t.Errorf("Expected no entry left tagged product-43, got %d", n)
+	}
+
+	set("/products/44")
+	if n := c.DeletePrefix("/products/"); n != 2 {
+		t.Errorf("Expected 2 entries with prefix to be purged, got %d", n)
+	}
+	if n := c.DeleteTag("products"); n != 0 {
+		t.Errorf("Expected purged entries to be gone from the tag index, got %d", n)
+	}
+}
+
+func TestCacheProviderPurge(t *testing.T) {
+	memCache := NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10})
+	externalCache := NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10})
+	provider := NewCacheProvider(memCache, externalCache)
+
+	entry := NewCacheEntry([]byte("content"))
+	entry.Tags = []string{"product-42"}
+	provider.SetEntry("/products/42", entry, 0)
+	provider.Set("/products/43", []byte("content"))
+	provider.Set("/about", []byte("content"))
+
+	if n := provider.PurgeTag("product-42"); n != 2 {
+		t.Errorf("Expected the entry to be purged from both tiers, got %d", n)
+	}
+	if n := provider.PurgePrefix("/products/"); n != 2 {
+		t.Errorf("Expected the remaining product to be purged from both tiers, got %d", n)
+	}
+	if n := provider.PurgeKey("/about"); n != 2 {
+		t.Errorf("Expected the key to be purged from both tiers, got %d", n)
+	}
+	if _, found := provider.Get("/about"); found {
+		t.Error("Expected purged entry to be gone")
+	}
+}
+
+// gatedCache holds writes until the gate is opened
+type gatedCache struct {
+	Cache
+	gate chan struct{}
+}
+
+func (c *gatedCache) SetEntry(key string, entry CacheEntry, ttl time.Duration) {
+	<-c.gate
+	c.Cache.SetEntry(key, entry, ttl)
+}
+
+func TestCacheProviderWriteBehind(t *testing.T) {
+	memCache := NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10})
+	externalCache := &gatedCache{
+		Cache: NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10}),
+		gate:  make(chan struct{}),
+	}
+	provider := NewCacheProvider(memCache, externalCache)
+	provider.UseWriteBehind(2)
+
+	// The first write is being made, the next ones wait in the queue
+	provider.Set("/first", []byte("first"))
+	waitFor(t, func() bool { return provider.writes.metrics()["queued"] == 0 })
+	provider.Set("/second", []byte("old"))
+	provider.Set("/second", []byte("new"))
+	provider.Set("/third", []byte("third"))
+	provider.Set("/dropped", []byte("dropped"))
+
+	if _, found := provider.Get("/dropped"); !found {
+		t.Error("Expected entry to be stored in memory right away")
+	}
+	metrics := provider.GetMetrics()["write_behind"].(map[string]interface{})
+	if metrics["queued"] != 2 || metrics["dropped"].(int64) != 1 {
+		t.Errorf("Expected 2 queued writes and 1 dropped, got %v", metrics)
+	}
+
+	// Purging drops the queued writes of purged entries
+	purged := make(chan int)
+	go func() { purged <- provider.PurgeKey("/third") }()
+	waitFor(t, func() bool { return provider.writes.metrics()["queued"] == 1 })
+	close(externalCache.gate)
+	<-purged
+
+	waitFor(t, func() bool { return provider.writes.metrics()["queued"] == 0 })
+	provider.PurgeKey("/noop") // Waits for the last write to be made
+	if entry, found := externalCache.Get("/second"); !found || string(entry.Content) != "new" {
+		t.Errorf("Expected latest queued entry to be written, got %q", entry.Content)
+	}
+	for _, key := range []string{"/third", "/dropped"} {
+		if _, found := externalCache.Get(key); found {
+			t.Errorf("Expected %s not to be written", key)
+		}
+	}
+	if written := provider.writes.written.Load(); written != 2 {
+		t.Errorf("Expected 2 writes, got %d", written)
+	}
+}
+
+func TestCacheProviderClose(t *testing.T) {
+	externalCache := &gatedCache{
+		Cache: NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10}),
+		gate:  make(chan struct{}),
+	}
+	provider := NewCacheProvider(NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10}), externalCache)
+	provider.UseWriteBehind(10)
+	provider.Set("/first", []byte("first"))
+	provider.Set("/second", []byte("second"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if err := provider.Close(ctx); err == nil {
+		t.Error("Expected an error when writes are left at the deadline")
+	}
+
+	close(externalCache.gate)
+	if err := provider.Close(context.Background()); err != nil {
+		t.Errorf("Expected queued writes to be made, got %v", err)
+	}
+	for _, key := range []string{"/first", "/second"} {
+		if _, found := externalCache.Get(key); !found {
+			t.Errorf("Expected %s to be written", key)
+		}
+	}
+	select {
+	case <-provider.writes.stopped:
+	case <-time.After(time.Second):
+		t.Error("Expected the background writes to stop")
+	}
+}
+
+// slowGetCache holds reads, once the entry was read, until released
+type slowGetCache struct {
+	Cache
+	read    chan struct{}
+	release chan struct{}
+}
+
+func (c *slowGetCache) Get(key string) (CacheEntry, bool) {
+	entry, found := c.Cache.Get(key)
+	close(c.read)
+	<-c.release
+	return entry, found
+}
+
+func TestCacheProviderPurgeDuringGet(t *testing.T) {
+	memCache := NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10})
+	externalCache := &slowGetCache{
+		Cache:   NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10}),
+		read:    make(chan struct{}),
+		release: make(chan struct{}),
+	}
+	externalCache.Cache.Set("/page", []byte("outdated"))
+	provider := NewCacheProvider(memCache, externalCache)
+
+	got := make(chan string)
+	go func() {
+		entry, _ := provider.Get("/page")
+		got <- string(entry.Content)
+	}()
+	<-externalCache.read
+	provider.PurgeKey("/page")
+	close(externalCache.release)
+	if content := <-got; content != "outdated" {
+		t.Errorf("Expected the read entry to be returned, got %q", content)
+	}
+
+	if _, found := memCache.Get("/page"); found {
+		t.Error("Expected the purged entry not to be promoted into memory")
+	}
+}
+
+func waitFor(t *testing.T, condition func() bool) {
+	t.Helper()
+	deadline := time.Now().Add(time.Second)
+	for !condition() {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for condition")
+		}
+		time.Sleep(time.Millisecond)
+	}
 }
@@ -0,0 +1,130 @@
+package cache
+
+import (
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Circuit breaker states
+const (
+	circuitClosed   = "closed"    // Calls go through
+	circuitOpen     = "open"      // Calls are bypassed until the cooldown elapses
+	circuitHalfOpen = "half-open" // A single probe call decides whether to close again
+)
+
+// circuitBreaker stops calling a backend after consecutive failures, so an
+// unavailable cache is bypassed instead of slowing down every request. Once
+// the cooldown has elapsed a single call probes whether it recovered.
+type circuitBreaker struct {
+	name      string
+	threshold int
+	cooldown  time.Duration
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+
+	trips    atomic.Int64 // Times the circuit opened
+	bypassed atomic.Int64 // Calls skipped while open
+}
+
+// Defaults of the circuit breakers of external caches
+const (
+	DefaultBreakerThreshold = 5
+	DefaultBreakerCooldown  = 10 * time.Second
+)
+
+// newCircuitBreaker creates a breaker opening after threshold consecutive
+// failures for cooldown, 0 using the defaults
+func newCircuitBreaker(name string, threshold int, cooldown time.Duration) *circuitBreaker {
+	if threshold <= 0 {
+		threshold = DefaultBreakerThreshold
+	}
+	if cooldown <= 0 {
+		cooldown = DefaultBreakerCooldown
+	}
+	return &circuitBreaker{name: name, threshold: threshold, cooldown: cooldown, state: circuitClosed}
+}
+
+// allow reports whether a call may go through, counting it as bypassed otherwise
+func (b *circuitBreaker) allow() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	switch b.state {
+	case circuitOpen:
+		if time.Since(b.openedAt) < b.cooldown {
+			break
+		}
+		b.state = circuitHalfOpen
+		fallthrough
+	case circuitHalfOpen:
+		if b.probing {
+			break
+		}
+		b.probing = true
+		return true
+	default:
+		return true
+	}
+	b.bypassed.Add(1)
+	return false
+}
+
+// closed reports whether calls go through, without taking part in probing
+func (b *circuitBreaker) closed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.state == circuitClosed
+}
+
+// record reports the outcome of an allowed call
+func (b *circuitBreaker) record(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if err == nil {
+		if b.state != circuitClosed {
+			log.Infof("%s recovered, no longer bypassing it", b.name)
+		}
+		b.state, b.failures, b.probing = circuitClosed, 0, false
+		return
+	}
+
+	b.failures++
+	if b.state == circuitHalfOpen || b.failures >= b.threshold {
+		b.trip(err)
+	}
+}
+
+// trip opens the circuit, until a probe after the cooldown succeeds
+func (b *circuitBreaker) trip(err error) {
+	if b.state != circuitOpen {
+		b.trips.Add(1)
+		log.Warnf("%s unavailable, bypassing it for %v: %v", b.name, b.cooldown, err)
+	}
+	b.state, b.openedAt, b.probing = circuitOpen, time.Now(), false
+}
+
+// open trips the circuit right away, e.g. when the backend can't be reached at startup
+func (b *circuitBreaker) open(err error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.trip(err)
+}
+
+func (b *circuitBreaker) metrics() map[string]interface{} {
+	b.mu.Lock()
+	state := b.state
+	b.mu.Unlock()
+	return map[string]interface{}{
+		"state":    state,
+		"trips":    b.trips.Load(),
+		"bypassed": b.bypassed.Load(),
+	}
+}
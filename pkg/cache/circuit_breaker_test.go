@@ -0,0 +1,45 @@
+package cache
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestCircuitBreaker(t *testing.T) {
+	b := newCircuitBreaker("test", 2, 50*time.Millisecond)
+	errDown := errors.New("down")
+
+	b.record(errDown)
+	if !b.allow() {
+		t.Fatal("Expected calls to go through below the threshold")
+	}
+	b.record(errDown)
+	if b.allow() {
+		t.Fatal("Expected calls to be bypassed once the threshold is reached")
+	}
+
+	// A single probe goes through after the cooldown
+	time.Sleep(60 * time.Millisecond)
+	if !b.allow() || b.allow() {
+		t.Fatal("Expected a single probe after the cooldown")
+	}
+	b.record(errDown)
+	if b.allow() {
+		t.Fatal("Expected a failed probe to open the circuit again")
+	}
+
+	time.Sleep(60 * time.Millisecond)
+	if !b.allow() {
+		t.Fatal("Expected a probe after the cooldown")
+	}
+	b.record(nil)
+	if !b.closed() || !b.allow() {
+		t.Fatal("Expected a successful probe to close the circuit")
+	}
+
+	metrics := b.metrics()
+	if metrics["trips"].(int64) != 2 || metrics["bypassed"].(int64) != 3 {
+		t.Errorf("Expected 2 trips and 3 bypassed calls, got %v", metrics)
+	}
+}
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content codings cached bodies can be stored in
+const (
+	EncodingGzip   = "gzip"
+	EncodingBrotli = "br"
+)
+
+// minCompressSize is the body size below which compressing isn't worth it
+const minCompressSize = 256
+
+// Compress stores the body of entry compressed with each of encodings instead
+// of raw, so hits can be served without compressing again. Small bodies are kept raw.
+func Compress(entry CacheEntry, encodings []string) (CacheEntry, error) {
+	if len(encodings) == 0 || len(entry.Content) < minCompressSize {
+		return entry, nil
+	}
+
+	variants := make(map[string][]byte, len(encodings))
+	for _, encoding := range encodings {
+		var buf bytes.Buffer
+		var w io.WriteCloser
+		// Every cache miss is compressed, so levels favour speed over size
+		switch encoding {
+		case EncodingGzip:
+			w = gzip.NewWriter(&buf)
+		case EncodingBrotli:
+			w = brotli.NewWriterLevel(&buf, 4) // About as fast as gzip's default level, and smaller
+		default:
+			return entry, fmt.Errorf("unsupported content encoding %q", encoding)
+		}
+		if _, err := w.Write(entry.Content); err != nil {
+			return entry, err
+		}
+		if err := w.Close(); err != nil {
+			return entry, err
+		}
+		variants[encoding] = buf.Bytes()
+	}
+
+	entry.Encodings = variants
+	entry.Content = nil
+	return entry, nil
+}
+
+// Body returns the raw body of the entry, decompressing it when only compressed variants are stored
+func (e CacheEntry) Body() ([]byte, error) {
+	if e.Content != nil || len(e.Encodings) == 0 {
+		return e.Content, nil
+	}
+
+	// gzip decompresses fastest
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli} {
+		variant, ok := e.Encodings[encoding]
+		if !ok {
+			continue
+		}
+		var r io.Reader
+		if encoding == EncodingGzip {
+			gz, err := gzip.NewReader(bytes.NewReader(variant))
+			if err != nil {
+				return nil, err
+			}
+			defer gz.Close()
+			r = gz
+		} else {
+			r = brotli.NewReader(bytes.NewReader(variant))
+		}
+		return io.ReadAll(r)
+	}
+	return nil, fmt.Errorf("no supported content encoding among %d variants", len(e.Encodings))
+}
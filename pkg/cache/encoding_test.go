@@ -0,0 +1,43 @@
+package cache
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestCompress(t *testing.T) {
+	content := []byte(strings.Repeat("<p>hello world</p>", 100))
+	entry, err := Compress(NewCacheEntry(content), []string{EncodingGzip, EncodingBrotli})
+	if err != nil {
+		t.Fatalf("Failed to compress entry: %v", err)
+	}
+	if entry.Content != nil {
+		t.Error("Expected raw body not to be stored")
+	}
+	for _, encoding := range []string{EncodingGzip, EncodingBrotli} {
+		if variant := entry.Encodings[encoding]; len(variant) == 0 || len(variant) >= len(content) {
+			t.Errorf("Expected smaller %s variant, got %d bytes", encoding, len(variant))
+		}
+	}
+
+	body, err := entry.Body()
+	if err != nil || !bytes.Equal(body, content) {
+		t.Errorf("Expected body to decompress to the original content, got %d bytes (%v)", len(body), err)
+	}
+
+	// Brotli alone decompresses too
+	delete(entry.Encodings, EncodingGzip)
+	if body, err := entry.Body(); err != nil || !bytes.Equal(body, content) {
+		t.Errorf("Expected br variant to decompress to the original content, got %d bytes (%v)", len(body), err)
+	}
+
+	small, _ := Compress(NewCacheEntry([]byte("tiny")), []string{EncodingGzip})
+	if string(small.Content) != "tiny" || small.Encodings != nil {
+		t.Errorf("Expected small bodies to be stored raw, got %+v", small)
+	}
+
+	if _, err := Compress(NewCacheEntry(content), []string{"zstd"}); err == nil {
+		t.Error("Expected error for unsupported encoding")
+	}
+}
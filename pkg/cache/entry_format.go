@@ -0,0 +1,186 @@
+package cache
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// External caches store entries in a binary format: a fixed header, the
+// metadata of the entry as JSON, then the raw bodies back to back. Bodies
+// aren't base64 encoded like in JSON, and the metadata can be read without
+// going through them.
+//
+//	magic "BLCE" | version uint8 | metadata length uint32 | metadata | bodies
+//
+// Entries written as JSON before the binary format was introduced start
+// with '{' and can still be read.
+
+// EntryFormatVersion is the version of the binary format entries are written in
+const EntryFormatVersion = 1
+
+var entryMagic = [4]byte{'B', 'L', 'C', 'E'}
+
+const entryHeaderSize = len(entryMagic) + 1 + 4
+
+var ErrUnsupportedEntryFormat = errors.New("unsupported cache entry format")
+
+// entryMetadata is everything about an entry but its bodies
+type entryMetadata struct {
+	LastUpdated          time.Time     `json:"lastUpdated"`
+	ETag                 string        `json:"etag,omitempty"`
+	Status               int           `json:"status,omitempty"`
+	Header               http.Header   `json:"header,omitempty"`
+	Tags                 []string      `json:"tags,omitempty"`
+	ExpiresAt            time.Time     `json:"expiresAt"`
+	StaleWhileRevalidate time.Duration `json:"swr,omitempty"`
+	StaleIfError         time.Duration `json:"sie,omitempty"`
+
+	// Layout of the bodies following the metadata
+	ContentLength int           `json:"contentLength"` // -1 when there is no raw body
+	Encodings     []bodySection `json:"encodings,omitempty"`
+}
+
+type bodySection struct {
+	Encoding string `json:"encoding"`
+	Length   int    `json:"length"`
+}
+
+// writeEntry writes entry to w in the binary format, streaming the bodies as they are
+func writeEntry(w io.Writer, entry CacheEntry) error {
+	meta := entryMetadata{
+		LastUpdated:          entry.LastUpdated,
+		ETag:                 entry.ETag,
+		Status:               entry.Status,
+		Header:               entry.Header,
+		Tags:                 entry.Tags,
+		ExpiresAt:            entry.ExpiresAt,
+		StaleWhileRevalidate: entry.StaleWhileRevalidate,
+		StaleIfError:         entry.StaleIfError,
+		ContentLength:        -1,
+	}
+	if entry.Content != nil {
+		meta.ContentLength = len(entry.Content)
+	}
+	encodings := make([]string, 0, len(entry.Encodings))
+	for encoding := range entry.Encodings {
+		encodings = append(encodings, encoding)
+	}
+	sort.Strings(encodings) // Same entry, same bytes
+	for _, encoding := range encodings {
+		meta.Encodings = append(meta.Encodings, bodySection{Encoding: encoding, Length: len(entry.Encodings[encoding])})
+	}
+
+	metadata, err := json.Marshal(meta)
+	if err != nil {
+		return err
+	}
+	header := make([]byte, 0, entryHeaderSize)
+	header = append(header, entryMagic[:]...)
+	header = append(header, EntryFormatVersion)
+	header = binary.BigEndian.AppendUint32(header, uint32(len(metadata)))
+
+	bw := bufio.NewWriter(w)
+	bw.Write(header)
+	bw.Write(metadata)
+	bw.Write(entry.Content)
+	for _, encoding := range encodings {
+		bw.Write(entry.Encodings[encoding])
+	}
+	return bw.Flush()
+}
+
+// marshalEntry encodes entry in the binary format
+func marshalEntry(entry CacheEntry) ([]byte, error) {
+	var buf bytes.Buffer
+	if err := writeEntry(&buf, entry); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// unmarshalEntry decodes an entry in the binary format, or in the JSON format
+// of older versions. The bodies of the entry share the memory of data.
+func unmarshalEntry(data []byte) (CacheEntry, error) {
+	if len(data) > 0 && data[0] == '{' {
+		var entry CacheEntry
+		err := json.Unmarshal(data, &entry)
+		return entry, err
+	}
+
+	meta, bodies, err := decodeEntryMetadata(data)
+	if err != nil {
+		return CacheEntry{}, err
+	}
+	entry := CacheEntry{
+		LastUpdated:          meta.LastUpdated,
+		ETag:                 meta.ETag,
+		Status:               meta.Status,
+		Header:               meta.Header,
+		Tags:                 meta.Tags,
+		ExpiresAt:            meta.ExpiresAt,
+		StaleWhileRevalidate: meta.StaleWhileRevalidate,
+		StaleIfError:         meta.StaleIfError,
+	}
+	next := func(length int) ([]byte, error) {
+		if length < 0 || length > len(bodies) {
+			return nil, errors.New("truncated cache entry")
+		}
+		body := bodies[:length:length]
+		bodies = bodies[length:]
+		return body, nil
+	}
+	if meta.ContentLength >= 0 {
+		if entry.Content, err = next(meta.ContentLength); err != nil {
+			return CacheEntry{}, err
+		}
+	}
+	if len(meta.Encodings) > 0 {
+		entry.Encodings = make(map[string][]byte, len(meta.Encodings))
+		for _, section := range meta.Encodings {
+			if entry.Encodings[section.Encoding], err = next(section.Length); err != nil {
+				return CacheEntry{}, err
+			}
+		}
+	}
+	return entry, nil
+}
+
+// unmarshalEntryTags decodes only the tags of an entry, without its bodies
+func unmarshalEntryTags(data []byte) ([]string, error) {
+	if len(data) > 0 && data[0] == '{' {
+		var entry struct{ Tags []string }
+		err := json.Unmarshal(data, &entry)
+		return entry.Tags, err
+	}
+	meta, _, err := decodeEntryMetadata(data)
+	return meta.Tags, err
+}
+
+// decodeEntryMetadata checks the header of a binary entry and decodes its
+// metadata, returning the bodies that follow it
+func decodeEntryMetadata(data []byte) (entryMetadata, []byte, error) {
+	var meta entryMetadata
+	if len(data) < entryHeaderSize || !bytes.Equal(data[:len(entryMagic)], entryMagic[:]) {
+		return meta, nil, ErrUnsupportedEntryFormat
+	}
+	if version := data[len(entryMagic)]; version != EntryFormatVersion {
+		return meta, nil, fmt.Errorf("%w: version %d", ErrUnsupportedEntryFormat, version)
+	}
+	length := binary.BigEndian.Uint32(data[len(entryMagic)+1:])
+	rest := data[entryHeaderSize:]
+	if uint64(length) > uint64(len(rest)) {
+		return meta, nil, errors.New("truncated cache entry")
+	}
+	if err := json.Unmarshal(rest[:length], &meta); err != nil {
+		return meta, nil, err
+	}
+	return meta, rest[length:], nil
+}
@@ -0,0 +1,82 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestEntryFormat(t *testing.T) {
+	entry := NewCacheEntry([]byte(strings.Repeat("<p>hello</p>", 100)))
+	entry.Status = http.StatusMovedPermanently
+	entry.Header = http.Header{"Location": {"/new"}}
+	entry.Tags = []string{"product:42"}
+	entry.ExpiresAt = entry.LastUpdated.Add(time.Minute)
+	entry.StaleIfError = time.Hour
+
+	t.Run("round trip", func(t *testing.T) {
+		compressed, err := Compress(entry, []string{EncodingGzip, EncodingBrotli})
+		if err != nil {
+			t.Fatalf("Failed to compress entry: %v", err)
+		}
+		for _, want := range []CacheEntry{entry, compressed, NewCacheEntry([]byte{})} {
+			data, err := marshalEntry(want)
+			if err != nil {
+				t.Fatalf("Failed to marshal entry: %v", err)
+			}
+			if !bytes.HasPrefix(data, []byte("BLCE\x01")) {
+				t.Errorf("Expected binary entry header, got %q", data[:5])
+			}
+			got, err := unmarshalEntry(data)
+			if err != nil {
+				t.Fatalf("Failed to unmarshal entry: %v", err)
+			}
+			if !got.LastUpdated.Equal(want.LastUpdated) || !got.ExpiresAt.Equal(want.ExpiresAt) {
+				t.Errorf("Expected times to round trip, got %v and %v", got.LastUpdated, got.ExpiresAt)
+			}
+			got.LastUpdated, got.ExpiresAt = want.LastUpdated, want.ExpiresAt
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("Expected entry to round trip, got %+v", got)
+			}
+		}
+	})
+
+	t.Run("bodies are stored raw", func(t *testing.T) {
+		data, _ := marshalEntry(entry)
+		legacy, _ := json.Marshal(entry)
+		if len(data) >= len(legacy) || !bytes.Contains(data, entry.Content) {
+			t.Errorf("Expected raw body in a smaller entry, got %d bytes against %d in JSON", len(data), len(legacy))
+		}
+	})
+
+	t.Run("json entries", func(t *testing.T) {
+		data, _ := json.Marshal(entry)
+		got, err := unmarshalEntry(data)
+		if err != nil || !bytes.Equal(got.Content, entry.Content) || got.Status != entry.Status {
+			t.Errorf("Expected JSON entry to be read, got %+v (%v)", got, err)
+		}
+		if tags, err := unmarshalEntryTags(data); err != nil || !reflect.DeepEqual(tags, entry.Tags) {
+			t.Errorf("Expected tags of JSON entry, got %v (%v)", tags, err)
+		}
+	})
+
+	t.Run("invalid entries", func(t *testing.T) {
+		data, _ := marshalEntry(entry)
+		future := bytes.Clone(data)
+		future[4] = EntryFormatVersion + 1
+		if _, err := unmarshalEntry(future); !errors.Is(err, ErrUnsupportedEntryFormat) {
+			t.Errorf("Expected unsupported version error, got %v", err)
+		}
+		if _, err := unmarshalEntry(data[:len(data)-1]); err == nil {
+			t.Error("Expected error for truncated entry")
+		}
+		if _, err := unmarshalEntry([]byte("garbage")); !errors.Is(err, ErrUnsupportedEntryFormat) {
+			t.Errorf("Expected unsupported format error, got %v", err)
+		}
+	})
+}
@@ -1,7 +1,9 @@
 package cache
 
 import (
+	"errors"
 	"fmt"
+	"sync"
 )
 
 // NewExternalCache creates a new external cache based on the provided configuration
@@ -18,12 +20,64 @@ func NewExternalCache(config ExternalCacheConfig) (Cache, error) {
 	}
 }
 
-// CreateCacheProvider creates a new CacheProvider with the specified memory and external caches
-func CreateCacheProvider(memoryCache Cache, externalConfig ExternalCacheConfig) (*CacheProvider, error) {
+// ProviderFactory creates the cache providers of a replica. Providers backed by
+// the same Redis share a single invalidation bus, their invalidations being
+// scoped by provider name, so a replica holds one subscription per Redis.
+type ProviderFactory struct {
+	mu    sync.Mutex
+	buses map[string]*InvalidationBus // By Redis URL
+}
+
+func NewProviderFactory() *ProviderFactory {
+	return &ProviderFactory{buses: make(map[string]*InvalidationBus)}
+}
+
+// CreateCacheProvider creates a new CacheProvider named name with the specified memory and external caches.
+// Names must be unique within the factory, the same provider of every replica having the same name.
+func (f *ProviderFactory) CreateCacheProvider(name string, memoryCache Cache, externalConfig ExternalCacheConfig) (*CacheProvider, error) {
 	externalCache, err := NewExternalCache(externalConfig)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create external cache: %w", err)
 	}
 
-	return NewCacheProvider(memoryCache, externalCache), nil
+	provider := NewCacheProvider(memoryCache, externalCache)
+	provider.UseWriteBehind(externalConfig.WriteQueueSize)
+
+	// Replicas sharing Redis keep their memory caches in sync
+	if redisCache, ok := externalCache.(*RedisCache); ok && memoryCache != nil {
+		bus, err := f.bus(externalConfig.RedisURL, redisCache)
+		if err != nil {
+			return nil, fmt.Errorf("failed to subscribe to cache invalidations: %w", err)
+		}
+		provider.UseInvalidationBus(bus, name)
+	}
+	return provider, nil
+}
+
+// bus returns the invalidation bus of the Redis at url, subscribing through redisCache the first time
+func (f *ProviderFactory) bus(url string, redisCache *RedisCache) (*InvalidationBus, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if bus, ok := f.buses[url]; ok {
+		return bus, nil
+	}
+	bus, err := NewInvalidationBus(redisCache.client, InvalidationChannel)
+	if err != nil {
+		return nil, err
+	}
+	bus.breaker, bus.timeout = redisCache.breaker, redisCache.timeout
+	f.buses[url] = bus
+	return bus, nil
+}
+
+// Close unsubscribes from the invalidations of every Redis
+func (f *ProviderFactory) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	var errs []error
+	for url, bus := range f.buses {
+		errs = append(errs, bus.Close())
+		delete(f.buses, url)
+	}
+	return errors.Join(errs...)
 }
@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"strings"
 	"sync"
 	"time"
 
@@ -14,6 +15,7 @@ import (
 
 type FilesystemCache struct {
 	cacheDir string
+	config   CacheConfig
 	ttl      time.Duration
 	mutex    sync.RWMutex
 	metrics  struct {
@@ -33,6 +35,7 @@ func NewFilesystemCache(config ExternalCacheConfig) (*FilesystemCache, error) {
 
 	cache := &FilesystemCache{
 		cacheDir: config.CacheDir,
+		config:   config.CacheConfig,
 		ttl:      config.TTL,
 	}
 
@@ -42,12 +45,34 @@ func NewFilesystemCache(config ExternalCacheConfig) (*FilesystemCache, error) {
 	return cache, nil
 }
 
-func (c *FilesystemCache) getFilePath(key string) string {
-	// Hash the key to create a safe filename
+// Each entry is stored in a <hash>.cache file, next to a <hash>.meta sidecar
+// recording its key and tags. Tags are indexed by empty tags/<tag hash>/<hash>
+// marker files, so purging a tag doesn't have to read every entry.
+const (
+	entrySuffix = ".cache"
+	metaSuffix  = ".meta"
+	tagsDir     = "tags"
+)
+
+// entryMeta is the sidecar of a cache file
+type entryMeta struct {
+	Key  string   `json:"key"`
+	Tags []string `json:"tags,omitempty"`
+}
+
+// hashName hashes a key or tag to create a safe filename
+func hashName(name string) string {
 	hasher := sha256.New()
-	hasher.Write([]byte(key))
-	hash := hex.EncodeToString(hasher.Sum(nil))
-	return filepath.Join(c.cacheDir, hash+".cache")
+	hasher.Write([]byte(name))
+	return hex.EncodeToString(hasher.Sum(nil))
+}
+
+func (c *FilesystemCache) getFilePath(key string) string {
+	return filepath.Join(c.cacheDir, hashName(key)+entrySuffix)
+}
+
+func (c *FilesystemCache) tagDir(tag string) string {
+	return filepath.Join(c.cacheDir, tagsDir, hashName(tag))
 }
 
 func (c *FilesystemCache) Get(key string) (CacheEntry, bool) {
@@ -61,42 +86,170 @@ func (c *FilesystemCache) Get(key string) (CacheEntry, bool) {
 		return CacheEntry{}, false
 	}
 
-	var entry CacheEntry
-	if err := json.Unmarshal(data, &entry); err != nil {
+	entry, err := unmarshalEntry(data)
+	if err != nil {
 		log.Errorf("Failed to unmarshal cache entry: %v", err)
 		c.metrics.misses++
 		return CacheEntry{}, false
 	}
+	if entry.Expired(time.Now()) {
+		c.metrics.misses++
+		return CacheEntry{}, false
+	}
 
 	c.metrics.hits++
 	return entry, true
 }
 
 func (c *FilesystemCache) Set(key string, content []byte) {
+	c.SetEntry(key, NewCacheEntry(content), 0)
+}
+
+func (c *FilesystemCache) SetEntry(key string, entry CacheEntry, ttl time.Duration) {
 	c.mutex.Lock()
 	defer c.mutex.Unlock()
 
-	// Generate ETag
-	hasher := sha256.New()
-	hasher.Write(content)
-	etag := `"` + hex.EncodeToString(hasher.Sum(nil)) + `"`
+	entry = c.config.stamp(entry, ttl)
+	hash := hashName(key)
+	c.unindexTags(hash) // Tags of the entry being replaced
+	filePath := filepath.Join(c.cacheDir, hash+entrySuffix)
+	if err := writeEntryFile(filePath, entry); err != nil {
+		log.Errorf("Failed to write cache file: %v", err)
+		return
+	}
+	metaPath := filepath.Join(c.cacheDir, hash+metaSuffix)
+	if err := c.writeMeta(metaPath, entryMeta{Key: key, Tags: entry.Tags}); err != nil {
+		log.Errorf("Failed to write cache metadata: %v", err)
+	}
+	for _, tag := range entry.Tags {
+		if err := os.MkdirAll(c.tagDir(tag), 0755); err != nil {
+			log.Errorf("Failed to index cache tag: %v", err)
+			continue
+		}
+		if err := os.WriteFile(filepath.Join(c.tagDir(tag), hash), nil, 0644); err != nil {
+			log.Errorf("Failed to index cache tag: %v", err)
+		}
+	}
 
-	entry := CacheEntry{
-		Content:     content,
-		LastUpdated: time.Now(),
-		ETag:        etag,
+	// The modification time records when the entry can be cleaned up, so
+	// cleanup doesn't have to decode every file
+	if !entry.ExpiresAt.IsZero() {
+		expiry := entry.HardExpiry()
+		for _, path := range []string{filePath, metaPath} {
+			if err := os.Chtimes(path, expiry, expiry); err != nil {
+				log.Errorf("Failed to stamp cache file expiry: %v", err)
+			}
+		}
 	}
+}
 
-	data, err := json.Marshal(entry)
+func (c *FilesystemCache) writeMeta(path string, meta entryMeta) error {
+	data, err := json.Marshal(meta)
 	if err != nil {
-		log.Errorf("Failed to marshal cache entry: %v", err)
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// writeEntryFile streams entry to path, so bodies aren't copied into another buffer first
+func writeEntryFile(path string, entry CacheEntry) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	if err := writeEntry(f, entry); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func (c *FilesystemCache) readMeta(hash string) (entryMeta, bool) {
+	var meta entryMeta
+	data, err := os.ReadFile(filepath.Join(c.cacheDir, hash+metaSuffix))
+	if err != nil {
+		return meta, false
+	}
+	if err := json.Unmarshal(data, &meta); err != nil {
+		log.Errorf("Failed to unmarshal cache metadata: %v", err)
+		return meta, false
+	}
+	return meta, true
+}
+
+// unindexTags removes the tag markers of the entry stored under hash
+func (c *FilesystemCache) unindexTags(hash string) {
+	meta, ok := c.readMeta(hash)
+	if !ok {
 		return
 	}
+	for _, tag := range meta.Tags {
+		os.Remove(filepath.Join(c.tagDir(tag), hash))
+		os.Remove(c.tagDir(tag)) // Only succeeds once the tag has no entries left
+	}
+}
+
+// deleteHash removes the entry stored under hash with its sidecar and tag
+// markers, the caller must hold the write lock
+func (c *FilesystemCache) deleteHash(hash string) bool {
+	c.unindexTags(hash)
+	os.Remove(filepath.Join(c.cacheDir, hash+metaSuffix))
+	err := os.Remove(filepath.Join(c.cacheDir, hash+entrySuffix))
+	if err != nil && !os.IsNotExist(err) {
+		log.Errorf("Failed to remove cache file: %v", err)
+	}
+	return err == nil
+}
 
-	filePath := c.getFilePath(key)
-	if err := os.WriteFile(filePath, data, 0644); err != nil {
-		log.Errorf("Failed to write cache file: %v", err)
+func (c *FilesystemCache) Delete(key string) bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.deleteHash(hashName(key))
+}
+
+func (c *FilesystemCache) DeletePrefix(prefix string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entries, err := os.ReadDir(c.cacheDir)
+	if err != nil {
+		log.Errorf("Failed to read cache directory: %v", err)
+		return 0
+	}
+	var removed int
+	for _, entry := range entries {
+		hash, ok := strings.CutSuffix(entry.Name(), metaSuffix)
+		if !ok {
+			continue
+		}
+		if meta, ok := c.readMeta(hash); ok && strings.HasPrefix(meta.Key, prefix) && c.deleteHash(hash) {
+			removed++
+		}
 	}
+	return removed
+}
+
+func (c *FilesystemCache) DeleteTag(tag string) int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	dir := c.tagDir(tag)
+	markers, err := os.ReadDir(dir)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			log.Errorf("Failed to read cache tag index: %v", err)
+		}
+		return 0
+	}
+	var removed int
+	for _, marker := range markers {
+		if c.deleteHash(marker.Name()) {
+			removed++
+		}
+	}
+	os.RemoveAll(dir)
+	return removed
 }
 
 func (c *FilesystemCache) cleanupRoutine() {
@@ -124,16 +277,15 @@ func (c *FilesystemCache) cleanup() {
 			continue
 		}
 
-		filePath := filepath.Join(c.cacheDir, entry.Name())
 		info, err := entry.Info()
 		if err != nil {
 			continue
 		}
 
-		if now.Sub(info.ModTime()) > c.ttl {
-			if err := os.Remove(filePath); err != nil {
-				log.Errorf("Failed to remove expired cache file: %v", err)
-			}
+		// Files are stamped with their hard expiry, see SetEntry
+		if c.ttl > 0 && info.ModTime().Before(now) {
+			hash := strings.TrimSuffix(strings.TrimSuffix(entry.Name(), entrySuffix), metaSuffix)
+			c.deleteHash(hash)
 		}
 	}
 }
@@ -145,7 +297,11 @@ func (c *FilesystemCache) GetMetrics() map[string]interface{} {
 	var size int64
 	entries, err := os.ReadDir(c.cacheDir)
 	if err == nil {
-		size = int64(len(entries))
+		for _, entry := range entries {
+			if strings.HasSuffix(entry.Name(), entrySuffix) {
+				size++
+			}
+		}
 	} else {
 		size = -1
 	}
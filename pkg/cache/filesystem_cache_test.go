@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"net/http"
 	"os"
 	"path/filepath"
 	"testing"
@@ -79,6 +80,68 @@ func TestFilesystemCache(t *testing.T) {
 		}
 	})
 
+	t.Run("stale entries survive cleanup", func(t *testing.T) {
+		cache, err := NewFilesystemCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{
+				TTL:                  50 * time.Millisecond,
+				StaleWhileRevalidate: time.Hour,
+			},
+			Type:     ExternalCacheFilesystem,
+			CacheDir: filepath.Join(tempDir, "stale-test"),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+
+		cache.Set("key1", []byte("content1"))
+		time.Sleep(100 * time.Millisecond)
+		cache.cleanup()
+
+		entry, found := cache.Get("key1")
+		if !found {
+			t.Fatal("Expected stale entry to be kept")
+		}
+		if entry.Fresh(time.Now()) {
+			t.Error("Expected entry to be stale")
+		}
+	})
+
+	t.Run("status and headers", func(t *testing.T) {
+		cache, err := NewFilesystemCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute},
+			Type:        ExternalCacheFilesystem,
+			CacheDir:    filepath.Join(tempDir, "status-test"),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+
+		entry := NewCacheEntry([]byte("moved"))
+		entry.Status = http.StatusMovedPermanently
+		entry.Header = http.Header{"Location": {"/new"}, "Link": {"</app.css>; rel=preload", "</app.js>; rel=preload"}}
+		cache.SetEntry("redirect", entry, 0)
+
+		got, found := cache.Get("redirect")
+		if !found {
+			t.Fatal("Expected to find entry")
+		}
+		if got.Status != http.StatusMovedPermanently || got.Header.Get("Location") != "/new" || len(got.Header.Values("Link")) != 2 {
+			t.Errorf("Expected status and headers to round trip, got %d %v", got.Status, got.Header)
+		}
+	})
+
+	t.Run("purging", func(t *testing.T) {
+		cache, err := NewFilesystemCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute},
+			Type:        ExternalCacheFilesystem,
+			CacheDir:    filepath.Join(tempDir, "purge-test"),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		testPurging(t, cache)
+	})
+
 	t.Run("metrics", func(t *testing.T) {
 		metricsDir := filepath.Join(tempDir, "metrics-test")
 		cache, err := NewFilesystemCache(ExternalCacheConfig{
@@ -0,0 +1,172 @@
+package cache
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// EvictionPolicy selects the entries an in-memory cache evicts when it is full
+type EvictionPolicy string
+
+const (
+	EvictionLRU  EvictionPolicy = "lru"  // Least recently used entries first
+	EvictionFIFO EvictionPolicy = "fifo" // Oldest entries first, regardless of hits
+)
+
+// InMemoryCacheConfig configures an in-memory cache tier
+type InMemoryCacheConfig struct {
+	CacheConfig
+	Name     string         // Name used in logs and metrics, e.g. "SSR" or "404"
+	Eviction EvictionPolicy // Defaults to EvictionLRU
+	Negative bool           // Holds error responses: entries default to status 404 and are never served stale
+}
+
+// InMemoryCache is the in-memory tier of a CacheProvider
+type InMemoryCache struct {
+	data      *lru
+	config    CacheConfig
+	name      string
+	negative  bool
+	eviction  EvictionPolicy
+	hits      atomic.Int64
+	misses    atomic.Int64
+	evictions atomic.Int64
+	rejected  atomic.Int64 // Entries too large to be cached
+	cleanups  atomic.Int64
+}
+
+// NewInMemoryCache creates an in-memory cache, holding 1000 entries unless configured otherwise
+func NewInMemoryCache(config InMemoryCacheConfig) *InMemoryCache {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 1000 // Default max entries
+	}
+	switch config.Eviction {
+	case EvictionLRU, EvictionFIFO:
+	case "":
+		config.Eviction = EvictionLRU
+	default:
+		log.Warnf("Unknown eviction policy %q for %s cache, using %s", config.Eviction, config.Name, EvictionLRU)
+		config.Eviction = EvictionLRU
+	}
+	if config.Negative {
+		// Not found pages are never served stale
+		config.StaleWhileRevalidate = 0
+		config.StaleIfError = 0
+	}
+
+	cache := &InMemoryCache{
+		data:     newLRU(config.MaxSize, config.MaxBytes, config.Eviction),
+		config:   config.CacheConfig,
+		name:     config.Name,
+		negative: config.Negative,
+		eviction: config.Eviction,
+	}
+
+	go func() {
+		ticker := time.NewTicker(time.Minute * 5) // Reduced cleanup frequency
+		defer ticker.Stop()
+		for range ticker.C {
+			cache.cleanup()
+		}
+	}()
+
+	return cache
+}
+
+// NewSSRInMemoryCache creates the in-memory tier of the SSR cache
+func NewSSRInMemoryCache(config CacheConfig) *InMemoryCache {
+	return NewInMemoryCache(InMemoryCacheConfig{CacheConfig: config, Name: "SSR"})
+}
+
+// NewNotFoundInMemoryCache creates the in-memory tier of the 404 cache, holding 250 entries unless configured otherwise
+func NewNotFoundInMemoryCache(config CacheConfig) *InMemoryCache {
+	if config.MaxSize <= 0 {
+		config.MaxSize = 250 // Default max entries for 404s
+	}
+	return NewInMemoryCache(InMemoryCacheConfig{CacheConfig: config, Name: "404", Negative: true})
+}
+
+func (c *InMemoryCache) Get(key string) (CacheEntry, bool) {
+	entry, exists := c.data.get(key)
+	if !exists || entry.Expired(time.Now()) {
+		c.misses.Add(1)
+		log.Debugf("%s cache miss for key: %s", c.name, key)
+		return CacheEntry{}, false
+	}
+
+	c.hits.Add(1)
+	log.Debugf("%s cache hit for key: %s", c.name, key)
+	return entry, true
+}
+
+func (c *InMemoryCache) Set(key string, content []byte) {
+	c.SetEntry(key, NewCacheEntry(content), 0)
+}
+
+func (c *InMemoryCache) SetEntry(key string, entry CacheEntry, ttl time.Duration) {
+	if c.negative {
+		entry.StaleWhileRevalidate, entry.StaleIfError = 0, 0
+		if entry.Status == 0 {
+			entry.Status = http.StatusNotFound
+		}
+	}
+
+	evicted, stored := c.data.set(key, c.config.stamp(entry, ttl))
+	if !stored {
+		c.rejected.Add(1)
+		log.Warnf("%s cache entry for key %s takes %d bytes, over the %d bytes an entry may take, not caching it",
+			c.name, key, entrySize(key, entry), c.data.maxEntryBytes())
+		return
+	}
+	if len(evicted) > 0 {
+		c.evictions.Add(int64(len(evicted)))
+		log.Debugf("Evicted %s cache entries: %v", c.name, evicted)
+	}
+	log.Debugf("%s cache entry set for key: %s", c.name, key)
+}
+
+func (c *InMemoryCache) Delete(key string) bool {
+	return c.data.delete(key)
+}
+
+func (c *InMemoryCache) DeletePrefix(prefix string) int {
+	removed := c.data.deletePrefix(prefix)
+	log.Debugf("Purged %d %s cache entries with prefix: %s", removed, c.name, prefix)
+	return removed
+}
+
+func (c *InMemoryCache) DeleteTag(tag string) int {
+	removed := c.data.deleteTag(tag)
+	log.Debugf("Purged %d %s cache entries tagged: %s", removed, c.name, tag)
+	return removed
+}
+
+func (c *InMemoryCache) cleanup() {
+	if removed := c.data.deleteExpired(time.Now()); removed > 0 {
+		c.cleanups.Add(1)
+		log.Debugf("%s cache cleanup: removed %d expired entries", c.name, removed)
+	}
+}
+
+func (c *InMemoryCache) GetMetrics() map[string]interface{} {
+	size, bytes := c.data.stats()
+	return map[string]interface{}{
+		"type":          "memory",
+		"name":          c.name,
+		"eviction":      string(c.eviction),
+		"size":          size,
+		"maxSize":       c.config.MaxSize,
+		"bytes":         bytes,
+		"maxBytes":      c.config.MaxBytes,
+		"maxEntryBytes": c.data.maxEntryBytes(),
+		"hits":          c.hits.Load(),
+		"misses":        c.misses.Load(),
+		"evictions":     c.evictions.Load(),
+		"rejected":      c.rejected.Load(),
+		"cleanups":      c.cleanups.Load(),
+		"ttl":           c.config.TTL.String(),
+	}
+}
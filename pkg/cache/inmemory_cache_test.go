@@ -0,0 +1,361 @@
+package cache
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestSSRInMemoryCache(t *testing.T) {
+	t.Run("basic operations", func(t *testing.T) {
+		cache := NewSSRInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 10,
+		})
+
+		// Test Set and Get
+		content := []byte("test content")
+		cache.Set("key1", content)
+
+		entry, found := cache.Get("key1")
+		if !found {
+			t.Error("Expected to find entry")
+		}
+		if string(entry.Content) != string(content) {
+			t.Errorf("Expected content %s, got %s", content, entry.Content)
+		}
+		if entry.ETag == "" {
+			t.Error("Expected ETag to be generated")
+		}
+
+		// Test miss
+		_, found = cache.Get("nonexistent")
+		if found {
+			t.Error("Expected not to find nonexistent entry")
+		}
+	})
+
+	t.Run("capacity limit", func(t *testing.T) {
+		cache := NewSSRInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 2,
+		})
+
+		// Fill cache
+		cache.Set("key1", []byte("content1"))
+		cache.Set("key2", []byte("content2"))
+
+		// Add one more, should evict oldest
+		cache.Set("key3", []byte("content3"))
+
+		// Check key1 was evicted
+		_, found := cache.Get("key1")
+		if found {
+			t.Error("Expected key1 to be evicted")
+		}
+
+		// Check key2 and key3 are still there
+		_, found = cache.Get("key2")
+		if !found {
+			t.Error("Expected key2 to be present")
+		}
+		_, found = cache.Get("key3")
+		if !found {
+			t.Error("Expected key3 to be present")
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		cache := NewSSRInMemoryCache(CacheConfig{
+			TTL:     50 * time.Millisecond,
+			MaxSize: 10,
+		})
+
+		cache.Set("key1", []byte("content1"))
+
+		// Wait for TTL to expire
+		time.Sleep(100 * time.Millisecond)
+
+		// Trigger cleanup
+		cache.cleanup()
+
+		// Check entry was removed
+		_, found := cache.Get("key1")
+		if found {
+			t.Error("Expected entry to be cleaned up")
+		}
+	})
+
+	t.Run("purging", func(t *testing.T) {
+		testPurging(t, NewSSRInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10}))
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		cache := NewSSRInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 10,
+		})
+
+		// Generate some hits and misses
+		cache.Set("key1", []byte("content1"))
+		cache.Get("key1") // Hit
+		cache.Get("key2") // Miss
+
+		metrics := cache.GetMetrics()
+
+		if metrics["type"] != "memory" {
+			t.Error("Expected type to be 'memory'")
+		}
+		if metrics["hits"].(int64) != 1 {
+			t.Errorf("Expected 1 hit, got %d", metrics["hits"])
+		}
+		if metrics["misses"].(int64) != 1 {
+			t.Errorf("Expected 1 miss, got %d", metrics["misses"])
+		}
+		if metrics["size"].(int) != 1 {
+			t.Errorf("Expected size 1, got %d", metrics["size"])
+		}
+	})
+
+	t.Run("etag generation", func(t *testing.T) {
+		cache := NewSSRInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 10,
+		})
+
+		// Set same content twice, should get same ETag
+		content := []byte("test content")
+		cache.Set("key1", content)
+		cache.Set("key2", content)
+
+		entry1, _ := cache.Get("key1")
+		entry2, _ := cache.Get("key2")
+
+		if entry1.ETag != entry2.ETag {
+			t.Error("Expected same ETag for same content")
+		}
+
+		// Set different content, should get different ETag
+		cache.Set("key3", []byte("different content"))
+		entry3, _ := cache.Get("key3")
+
+		if entry1.ETag == entry3.ETag {
+			t.Error("Expected different ETag for different content")
+		}
+	})
+}
+
+func TestNotFoundInMemoryCache(t *testing.T) {
+	t.Run("basic operations", func(t *testing.T) {
+		cache := NewNotFoundInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 10,
+		})
+
+		// Test Set and Get
+		content := []byte("404 not found content")
+		cache.Set("key1", content)
+
+		entry, found := cache.Get("key1")
+		if !found {
+			t.Error("Expected to find entry")
+		}
+		if string(entry.Content) != string(content) {
+			t.Errorf("Expected content %s, got %s", content, entry.Content)
+		}
+		if entry.ETag == "" {
+			t.Error("Expected ETag to be generated")
+		}
+
+		// Test miss
+		_, found = cache.Get("nonexistent")
+		if found {
+			t.Error("Expected not to find nonexistent entry")
+		}
+	})
+
+	t.Run("capacity limit", func(t *testing.T) {
+		cache := NewNotFoundInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 2,
+		})
+
+		// Fill cache
+		cache.Set("key1", []byte("404 content1"))
+		cache.Set("key2", []byte("404 content2"))
+
+		// Add one more, should evict oldest
+		cache.Set("key3", []byte("404 content3"))
+
+		// Check key1 was evicted
+		_, found := cache.Get("key1")
+		if found {
+			t.Error("Expected key1 to be evicted")
+		}
+
+		// Check key2 and key3 are still there
+		_, found = cache.Get("key2")
+		if !found {
+			t.Error("Expected key2 to be present")
+		}
+		_, found = cache.Get("key3")
+		if !found {
+			t.Error("Expected key3 to be present")
+		}
+	})
+
+	t.Run("cleanup", func(t *testing.T) {
+		cache := NewNotFoundInMemoryCache(CacheConfig{
+			TTL:     50 * time.Millisecond,
+			MaxSize: 10,
+		})
+
+		cache.Set("key1", []byte("404 content1"))
+
+		// Wait for TTL to expire
+		time.Sleep(100 * time.Millisecond)
+
+		// Trigger cleanup
+		cache.cleanup()
+
+		// Check entry was removed
+		_, found := cache.Get("key1")
+		if found {
+			t.Error("Expected entry to be cleaned up")
+		}
+	})
+
+	t.Run("purging", func(t *testing.T) {
+		testPurging(t, NewNotFoundInMemoryCache(CacheConfig{TTL: time.Minute, MaxSize: 10}))
+	})
+
+	t.Run("metrics", func(t *testing.T) {
+		cache := NewNotFoundInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 10,
+		})
+
+		// Generate some hits and misses
+		cache.Set("key1", []byte("404 content1"))
+		cache.Get("key1") // Hit
+		cache.Get("key2") // Miss
+
+		metrics := cache.GetMetrics()
+
+		if metrics["type"] != "memory" {
+			t.Error("Expected type to be 'memory'")
+		}
+		if metrics["hits"].(int64) != 1 {
+			t.Errorf("Expected 1 hit, got %d", metrics["hits"])
+		}
+		if metrics["misses"].(int64) != 1 {
+			t.Errorf("Expected 1 miss, got %d", metrics["misses"])
+		}
+		if metrics["size"].(int) != 1 {
+			t.Errorf("Expected size 1, got %d", metrics["size"])
+		}
+	})
+
+	t.Run("etag generation", func(t *testing.T) {
+		cache := NewNotFoundInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 10,
+		})
+
+		// Set same content twice, should get same ETag
+		content := []byte("404 not found content")
+		cache.Set("key1", content)
+		cache.Set("key2", content)
+
+		entry1, _ := cache.Get("key1")
+		entry2, _ := cache.Get("key2")
+
+		if entry1.ETag != entry2.ETag {
+			t.Error("Expected same ETag for same content")
+		}
+
+		// Set different content, should get different ETag
+		cache.Set("key3", []byte("different 404 content"))
+		entry3, _ := cache.Get("key3")
+
+		if entry1.ETag == entry3.ETag {
+			t.Error("Expected different ETag for different content")
+		}
+	})
+
+	t.Run("default size", func(t *testing.T) {
+		cache := NewNotFoundInMemoryCache(CacheConfig{
+			TTL:     time.Second,
+			MaxSize: 0, // Should use default size
+		})
+
+		metrics := cache.GetMetrics()
+		if metrics["maxSize"].(int) != 250 {
+			t.Errorf("Expected default size 250, got %d", metrics["maxSize"])
+		}
+	})
+}
+
+func TestInMemoryCache(t *testing.T) {
+	t.Run("negative caching", func(t *testing.T) {
+		cache := NewNotFoundInMemoryCache(CacheConfig{TTL: time.Minute, StaleIfError: time.Hour})
+
+		entry := NewCacheEntry([]byte("not found"))
+		entry.StaleWhileRevalidate = time.Hour
+		cache.SetEntry("/missing", entry, 0)
+		gone := NewCacheEntry([]byte("gone"))
+		gone.Status = http.StatusGone
+		cache.SetEntry("/gone", gone, 0)
+
+		got, _ := cache.Get("/missing")
+		if got.Status != http.StatusNotFound {
+			t.Errorf("Expected status to default to 404, got %d", got.Status)
+		}
+		if got.StaleWhileRevalidate != 0 || got.StaleIfError != 0 {
+			t.Errorf("Expected negative entries never to be served stale, got %+v", got)
+		}
+		if got, _ := cache.Get("/gone"); got.Status != http.StatusGone {
+			t.Errorf("Expected status 410 to be kept, got %d", got.Status)
+		}
+	})
+
+	t.Run("fifo eviction", func(t *testing.T) {
+		cache := NewInMemoryCache(InMemoryCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute, MaxSize: 2},
+			Name:        "test",
+			Eviction:    EvictionFIFO,
+		})
+		cache.Set("key1", []byte("content1"))
+		cache.Set("key2", []byte("content2"))
+		cache.Get("key1") // Doesn't save key1 from eviction
+		cache.Set("key3", []byte("content3"))
+
+		if _, found := cache.Get("key1"); found {
+			t.Error("Expected oldest entry to be evicted")
+		}
+		if metrics := cache.GetMetrics(); metrics["name"] != "test" || metrics["eviction"] != "fifo" || metrics["evictions"].(int64) != 1 {
+			t.Errorf("Unexpected metrics %v", metrics)
+		}
+	})
+	t.Run("entries over the shard budget are rejected", func(t *testing.T) {
+		cache := NewInMemoryCache(InMemoryCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute, MaxSize: 2048, MaxBytes: 16 << 10},
+			Name:        "test",
+		})
+		if limit := cache.GetMetrics()["maxEntryBytes"].(int64); limit != 1<<10 {
+			t.Fatalf("Expected entries to be limited to 1/16 of the budget, got %d", limit)
+		}
+
+		cache.Set("small", make([]byte, 512))
+		cache.Set("large", make([]byte, 2<<10))
+		if _, found := cache.Get("small"); !found {
+			t.Error("Expected entry within the limit to be cached")
+		}
+		if _, found := cache.Get("large"); found {
+			t.Error("Expected entry over the limit not to be cached")
+		}
+		if rejected := cache.GetMetrics()["rejected"].(int64); rejected != 1 {
+			t.Errorf("Expected 1 rejected entry, got %d", rejected)
+		}
+	})
+}
@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+	log "github.com/sirupsen/logrus"
+)
+
+// InvalidationChannel is the Redis channel replicas announce invalidations on
+const InvalidationChannel = "blastra:invalidate"
+
+// Kinds of invalidation
+const (
+	InvalidateKey    = "key"
+	InvalidatePrefix = "prefix"
+	InvalidateTag    = "tag"
+)
+
+// Invalidation selects the cache entries to drop, by exact key, key prefix or tag
+type Invalidation struct {
+	Node  string `json:"node"`  // Replica that published it
+	Scope string `json:"scope"` // Cache provider it applies to, see UseInvalidationBus
+	Kind  string `json:"kind"`
+	Value string `json:"value"`
+}
+
+// apply removes the entries selected by inv from c, returning how many were removed
+func (inv Invalidation) apply(c Cache) int {
+	switch inv.Kind {
+	case InvalidateKey:
+		if c.Delete(inv.Value) {
+			return 1
+		}
+		return 0
+	case InvalidatePrefix:
+		return c.DeletePrefix(inv.Value)
+	case InvalidateTag:
+		return c.DeleteTag(inv.Value)
+	default:
+		log.Warnf("Ignoring unknown cache invalidation kind %q", inv.Kind)
+		return 0
+	}
+}
+
+// InvalidationBus relays invalidations between replicas over Redis pub/sub, so
+// that purging or overwriting an entry in a shared Redis cache on one replica
+// also evicts it from the memory tier of every other replica. The cache
+// providers of a replica share its bus, each receiving its own scope only.
+type InvalidationBus struct {
+	client  redis.UniversalClient
+	breaker *circuitBreaker // Of the cache sharing the client, nothing is published while it is open
+	timeout time.Duration   // Deadline of publishing, 0 for none
+	channel string
+	node    string
+	pubsub  *redis.PubSub
+
+	mu       sync.RWMutex
+	handlers map[string][]func(Invalidation) // By scope
+}
+
+// NewInvalidationBus subscribes to channel, returning once the subscription is
+// active. When Redis can't be reached, it keeps trying to subscribe in the background.
+func NewInvalidationBus(client redis.UniversalClient, channel string) (*InvalidationBus, error) {
+	node := make([]byte, 8)
+	if _, err := rand.Read(node); err != nil {
+		return nil, err
+	}
+
+	ctx := context.Background()
+	pubsub := client.Subscribe(ctx, channel)
+	if _, err := pubsub.Receive(ctx); err != nil {
+		log.Warnf("Failed to subscribe to cache invalidations, retrying in the background: %v", err)
+	}
+
+	bus := &InvalidationBus{
+		client:   client,
+		channel:  channel,
+		node:     hex.EncodeToString(node),
+		pubsub:   pubsub,
+		handlers: make(map[string][]func(Invalidation)),
+	}
+	go bus.listen()
+	return bus, nil
+}
+
+func (b *InvalidationBus) listen() {
+	// The channel is closed by Close, and go-redis resubscribes after connection errors
+	for msg := range b.pubsub.Channel() {
+		var inv Invalidation
+		if err := json.Unmarshal([]byte(msg.Payload), &inv); err != nil {
+			log.Errorf("Failed to unmarshal cache invalidation: %v", err)
+			continue
+		}
+		if inv.Node == b.node {
+			continue // Already applied locally
+		}
+
+		b.mu.RLock()
+		handlers := b.handlers[inv.Scope]
+		b.mu.RUnlock()
+		for _, handler := range handlers {
+			handler(inv)
+		}
+	}
+}
+
+// Subscribe calls handler with every invalidation of scope published by other replicas
+func (b *InvalidationBus) Subscribe(scope string, handler func(Invalidation)) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.handlers[scope] = append(b.handlers[scope], handler)
+}
+
+// Publish announces an invalidation to the other replicas
+func (b *InvalidationBus) Publish(inv Invalidation) {
+	if b.breaker != nil && !b.breaker.closed() {
+		return
+	}
+	inv.Node = b.node
+	data, err := json.Marshal(inv)
+	if err != nil {
+		log.Errorf("Failed to marshal cache invalidation: %v", err)
+		return
+	}
+	ctx := context.Background()
+	if b.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, b.timeout)
+		defer cancel()
+	}
+	if err := b.client.Publish(ctx, b.channel, data).Err(); err != nil {
+		log.Errorf("Failed to publish cache invalidation: %v", err)
+	}
+}
+
+// Close unsubscribes from the channel
+func (b *InvalidationBus) Close() error {
+	return b.pubsub.Close()
+}
@@ -0,0 +1,146 @@
+package cache
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestInvalidationBus(t *testing.T) {
+	s, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Failed to start miniredis: %v", err)
+	}
+	defer s.Close()
+
+	// Two replicas with their own memory caches in front of the same Redis
+	newReplica := func() (*CacheProvider, *CacheProvider) {
+		factory := NewProviderFactory()
+		t.Cleanup(func() { factory.Close() })
+		create := func(name string) *CacheProvider {
+			provider, err := factory.CreateCacheProvider(name, NewSSRInMemoryCache(CacheConfig{TTL: time.Minute}), ExternalCacheConfig{
+				CacheConfig: CacheConfig{TTL: time.Minute},
+				Type:        ExternalCacheRedis,
+				RedisURL:    s.Addr(),
+			})
+			if err != nil {
+				t.Fatalf("Failed to create cache provider: %v", err)
+			}
+			return provider
+		}
+		return create("ssr"), create("404")
+	}
+	a, aNotFound := newReplica()
+	b, bNotFound := newReplica()
+	if a.bus != aNotFound.bus {
+		t.Error("Expected the providers of a replica to share its invalidation bus")
+	}
+
+	// eventually waits for an invalidation published by a to reach b
+	eventually := func(what string, cond func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}
+	// published counts a's invalidations, settle waits until b received them all
+	var published int64
+	settle := func() {
+		t.Helper()
+		published++
+		eventually("invalidations to reach the replica", func() bool {
+			return b.invalidations.Load() >= published
+		})
+	}
+	inMemory := func(p *CacheProvider, key string) (string, bool) {
+		entry, found := p.memoryCache.Get(key)
+		return string(entry.Content), found
+	}
+
+	a.Set("/page", []byte("v1"))
+	settle()
+	if entry, found := b.Get("/page"); !found || string(entry.Content) != "v1" {
+		t.Fatalf("Expected replica to read the shared entry, got %q", entry.Content)
+	}
+	if _, found := inMemory(b, "/page"); !found {
+		t.Fatal("Expected entry to be promoted to the replica's memory")
+	}
+
+	t.Run("overwrite", func(t *testing.T) {
+		a.Set("/page", []byte("v2"))
+		settle()
+		eventually("overwrite to evict the replica's copy", func() bool {
+			_, found := inMemory(b, "/page")
+			return !found
+		})
+		if entry, _ := b.Get("/page"); string(entry.Content) != "v2" {
+			t.Errorf("Expected replica to read the new entry, got %q", entry.Content)
+		}
+		if content, _ := inMemory(a, "/page"); content != "v2" {
+			t.Errorf("Expected publisher to keep its own entry, got %q", content)
+		}
+	})
+
+	t.Run("purge by tag", func(t *testing.T) {
+		entry := NewCacheEntry([]byte("product"))
+		entry.Tags = []string{"product-42"}
+		a.SetEntry("/products/42", entry, 0)
+		settle()
+		b.Get("/products/42")
+
+		a.PurgeTag("product-42")
+		eventually("purge to evict the replica's copy", func() bool {
+			_, found := inMemory(b, "/products/42")
+			return !found
+		})
+		if _, found := b.Get("/products/42"); found {
+			t.Error("Expected purged entry to be gone on the replica")
+		}
+	})
+
+	t.Run("other providers are left alone", func(t *testing.T) {
+		bNotFound.memoryCache.Set("/page", []byte("missing"))
+		a.Set("/page", []byte("v3"))
+		settle()
+		if _, found := inMemory(bNotFound, "/page"); !found {
+			t.Error("Expected the invalidation to be scoped to the provider that published it")
+		}
+		if bNotFound.invalidations.Load() != 0 || aNotFound.invalidations.Load() != 0 {
+			t.Error("Expected no invalidation to reach the 404 providers")
+		}
+	})
+
+	t.Run("purge by prefix", func(t *testing.T) {
+		b.Get("/page")
+		a.PurgePrefix("/")
+		eventually("purge to evict the replica's copy", func() bool {
+			_, found := inMemory(b, "/page")
+			return !found
+		})
+	})
+	t.Run("dropped write-behind write", func(t *testing.T) {
+		a.Set("/page", []byte("v4"))
+		settle()
+		b.Get("/page")
+
+		// One write is being made and another fills the queue
+		gate := make(chan struct{})
+		a.writes = newWriteBehind(1, func(pendingWrite) { <-gate })
+		defer a.writes.stop()
+		defer close(gate)
+		a.Set("/first", []byte("first"))
+		waitFor(t, func() bool { return a.writes.metrics()["queued"] == 0 })
+		a.Set("/second", []byte("second"))
+
+		a.Set("/page", []byte("v5"))
+		settle()
+		if _, found := inMemory(b, "/page"); found {
+			t.Error("Expected a dropped write to evict the replica's copy")
+		}
+	})
+}
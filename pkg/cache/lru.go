@@ -0,0 +1,212 @@
+package cache
+
+import (
+	"container/list"
+	"hash/maphash"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	maxLRUShards      = 16
+	minEntriesByShard = 64 // Small caches use fewer shards so their limits stay meaningful
+
+	entryOverhead = 256 // Approximate bookkeeping bytes per entry on top of its content
+)
+
+// lru is an in-memory cache with least recently used eviction, bounded both
+// by number of entries and by total bytes. Keys are spread over shards with
+// their own lock and limits, so hits on different keys don't contend. An
+// entry can't be larger than the byte budget of its shard, see maxEntryBytes.
+type lru struct {
+	seed   maphash.Seed
+	shards []*lruShard
+	fifo   bool // Hits don't refresh entries, so the oldest are evicted first
+}
+
+type lruShard struct {
+	mu         sync.Mutex // Hits reorder the list, so reads lock exclusively too
+	items      map[string]*list.Element
+	order      *list.List // Most recently used first
+	tags       tagIndex
+	bytes      int64
+	maxEntries int
+	maxBytes   int64 // 0 means unbounded
+}
+
+type lruItem struct {
+	key   string
+	entry CacheEntry
+	size  int64
+}
+
+// newLRU creates an LRU holding at most maxEntries entries and maxBytes bytes (0 for no byte limit).
+// With EvictionFIFO, it evicts the oldest entries instead of the least recently used.
+func newLRU(maxEntries int, maxBytes int64, policy EvictionPolicy) *lru {
+	n := min(max(maxEntries/minEntriesByShard, 1), maxLRUShards)
+	c := &lru{seed: maphash.MakeSeed(), shards: make([]*lruShard, n), fifo: policy == EvictionFIFO}
+	for i := range c.shards {
+		// Spread the remainders so the shard limits add up to the cache limits
+		shardEntries := maxEntries / n
+		if i < maxEntries%n {
+			shardEntries++
+		}
+		var shardBytes int64
+		if maxBytes > 0 {
+			shardBytes = maxBytes / int64(n)
+		}
+		c.shards[i] = &lruShard{
+			items:      make(map[string]*list.Element),
+			order:      list.New(),
+			tags:       make(tagIndex),
+			maxEntries: shardEntries,
+			maxBytes:   shardBytes,
+		}
+	}
+	return c
+}
+
+// maxEntryBytes returns the size over which entries are rejected, the byte
+// budget split between the shards, or 0 when there is no byte limit
+func (c *lru) maxEntryBytes() int64 {
+	return c.shards[0].maxBytes
+}
+
+func (c *lru) shard(key string) *lruShard {
+	return c.shards[maphash.String(c.seed, key)%uint64(len(c.shards))]
+}
+
+// entrySize estimates the memory held by an entry
+func entrySize(key string, entry CacheEntry) int64 {
+	size := int64(len(key)+len(entry.Content)+len(entry.ETag)) + entryOverhead
+	for name, values := range entry.Header {
+		size += int64(len(name))
+		for _, value := range values {
+			size += int64(len(value))
+		}
+	}
+	for encoding, variant := range entry.Encodings {
+		size += int64(len(encoding) + len(variant))
+	}
+	for _, tag := range entry.Tags {
+		size += int64(len(tag))
+	}
+	return size
+}
+
+// get returns the entry for key, marking it as recently used
+func (c *lru) get(key string) (CacheEntry, bool) {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	elem, ok := s.items[key]
+	if !ok {
+		return CacheEntry{}, false
+	}
+	if !c.fifo {
+		s.order.MoveToFront(elem)
+	}
+	return elem.Value.(*lruItem).entry, true
+}
+
+// set stores an entry, evicting the least recently used entries to make room.
+// It returns the keys evicted, and false if the entry is too large to be cached at all.
+func (c *lru) set(key string, entry CacheEntry) (evicted []string, stored bool) {
+	s := c.shard(key)
+	size := entrySize(key, entry)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.remove(key)
+	if s.maxEntries <= 0 || (s.maxBytes > 0 && size > s.maxBytes) {
+		return nil, false
+	}
+	for len(s.items) >= s.maxEntries || (s.maxBytes > 0 && s.bytes+size > s.maxBytes) {
+		oldest := s.order.Back().Value.(*lruItem)
+		s.remove(oldest.key)
+		evicted = append(evicted, oldest.key)
+	}
+
+	s.items[key] = s.order.PushFront(&lruItem{key: key, entry: entry, size: size})
+	s.bytes += size
+	s.tags.add(key, entry.Tags)
+	return evicted, true
+}
+
+// delete removes the entry for key, reporting whether it existed
+func (c *lru) delete(key string) bool {
+	s := c.shard(key)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.remove(key)
+}
+
+// deleteFunc removes the entries of every shard selected by keys, returning how many were removed
+func (c *lru) deleteFunc(keys func(s *lruShard) []string) int {
+	var removed int
+	for _, s := range c.shards {
+		s.mu.Lock()
+		for _, key := range keys(s) {
+			if s.remove(key) {
+				removed++
+			}
+		}
+		s.mu.Unlock()
+	}
+	return removed
+}
+
+func (c *lru) deletePrefix(prefix string) int {
+	return c.deleteFunc(func(s *lruShard) []string {
+		var keys []string
+		for key := range s.items {
+			if strings.HasPrefix(key, prefix) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	})
+}
+
+func (c *lru) deleteTag(tag string) int {
+	return c.deleteFunc(func(s *lruShard) []string { return s.tags.keys(tag) })
+}
+
+// deleteExpired removes the entries past their hard expiry, returning how many were removed
+func (c *lru) deleteExpired(now time.Time) int {
+	return c.deleteFunc(func(s *lruShard) []string {
+		var keys []string
+		for key, elem := range s.items {
+			if elem.Value.(*lruItem).entry.Expired(now) {
+				keys = append(keys, key)
+			}
+		}
+		return keys
+	})
+}
+
+// stats returns the number of entries and bytes held
+func (c *lru) stats() (entries int, bytes int64) {
+	for _, s := range c.shards {
+		s.mu.Lock()
+		entries += len(s.items)
+		bytes += s.bytes
+		s.mu.Unlock()
+	}
+	return entries, bytes
+}
+
+// remove drops an entry from the shard, the caller must hold its lock
+func (s *lruShard) remove(key string) bool {
+	elem, ok := s.items[key]
+	if !ok {
+		return false
+	}
+	item := elem.Value.(*lruItem)
+	s.order.Remove(elem)
+	delete(s.items, key)
+	s.bytes -= item.size
+	s.tags.remove(key, item.entry.Tags)
+	return true
+}
@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLRU(t *testing.T) {
+	entry := func(size int, tags ...string) CacheEntry {
+		return CacheEntry{Content: []byte(strings.Repeat("x", size)), Tags: tags}
+	}
+
+	t.Run("evicts least recently used", func(t *testing.T) {
+		c := newLRU(3, 0, EvictionLRU)
+		c.set("a", entry(1))
+		c.set("b", entry(1))
+		c.set("c", entry(1))
+		c.get("a") // a is now more recently used than b
+
+		evicted, _ := c.set("d", entry(1))
+		if len(evicted) != 1 || evicted[0] != "b" {
+			t.Errorf("Expected b to be evicted, got %v", evicted)
+		}
+		if _, ok := c.get("a"); !ok {
+			t.Error("Expected recently used entry to be kept")
+		}
+	})
+
+	t.Run("byte budget", func(t *testing.T) {
+		c := newLRU(10, 3*(1000+entryOverhead), EvictionLRU)
+		for _, key := range []string{"a", "b", "c", "d"} {
+			c.set(key, entry(1000-len(key)))
+		}
+		if entries, bytes := c.stats(); entries != 3 || bytes > 3*(1000+entryOverhead) {
+			t.Errorf("Expected 3 entries within the byte budget, got %d entries of %d bytes", entries, bytes)
+		}
+		if _, ok := c.get("a"); ok {
+			t.Error("Expected oldest entry to be evicted to stay within the byte budget")
+		}
+
+		if _, stored := c.set("huge", entry(10000)); stored {
+			t.Error("Expected entry larger than the budget not to be stored")
+		}
+		if entries, _ := c.stats(); entries != 3 {
+			t.Errorf("Expected oversized entry not to evict anything, got %d entries", entries)
+		}
+	})
+
+	t.Run("replacing keeps accounting", func(t *testing.T) {
+		c := newLRU(10, 0, EvictionLRU)
+		c.set("a", entry(100, "old"))
+		c.set("a", entry(10, "new"))
+		if entries, bytes := c.stats(); entries != 1 || bytes != entrySize("a", entry(10, "new")) {
+			t.Errorf("Expected a single entry of the new size, got %d entries of %d bytes", entries, bytes)
+		}
+		if n := c.deleteTag("old"); n != 0 {
+			t.Errorf("Expected replaced entry to lose its old tag, got %d", n)
+		}
+	})
+
+	t.Run("evicted entries leave the tag index", func(t *testing.T) {
+		c := newLRU(1, 0, EvictionLRU)
+		c.set("a", entry(1, "tag"))
+		c.set("b", entry(1))
+		if n := c.deleteTag("tag"); n != 0 {
+			t.Errorf("Expected evicted entry not to be purged, got %d", n)
+		}
+		if len(c.shards[0].tags) != 0 {
+			t.Errorf("Expected empty tag index, got %v", c.shards[0].tags)
+		}
+	})
+
+	t.Run("shards", func(t *testing.T) {
+		c := newLRU(1000, 0, EvictionLRU)
+		if len(c.shards) != 1000/minEntriesByShard {
+			t.Errorf("Expected %d shards, got %d", 1000/minEntriesByShard, len(c.shards))
+		}
+		var total int
+		for _, s := range c.shards {
+			total += s.maxEntries
+		}
+		if total != 1000 {
+			t.Errorf("Expected shard limits to add up to 1000 entries, got %d", total)
+		}
+	})
+
+	t.Run("expired entries", func(t *testing.T) {
+		c := newLRU(10, 0, EvictionLRU)
+		expired := entry(1)
+		expired.ExpiresAt = time.Now().Add(-time.Second)
+		c.set("expired", expired)
+		c.set("fresh", entry(1))
+		if n := c.deleteExpired(time.Now()); n != 1 {
+			t.Errorf("Expected 1 expired entry to be removed, got %d", n)
+		}
+	})
+
+	t.Run("concurrent use", func(t *testing.T) {
+		c := newLRU(500, 0, EvictionLRU)
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					key := fmt.Sprintf("/page/%d", (i*1000+j)%700)
+					c.set(key, entry(10))
+					c.get(key)
+				}
+			}(i)
+		}
+		wg.Wait()
+		if entries, _ := c.stats(); entries > 500 {
+			t.Errorf("Expected at most 500 entries, got %d", entries)
+		}
+	})
+}
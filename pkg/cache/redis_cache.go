@@ -2,9 +2,10 @@ package cache
 
 import (
 	"context"
-	"crypto/sha256"
-	"encoding/hex"
-	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/go-redis/redis/v8"
@@ -12,7 +13,10 @@ import (
 )
 
 type RedisCache struct {
-	client  *redis.Client
+	client  redis.UniversalClient
+	breaker *circuitBreaker
+	timeout time.Duration // Deadline of calls made while serving requests
+	config  CacheConfig
 	ttl     time.Duration
 	metrics struct {
 		hits   int64
@@ -25,41 +29,96 @@ func NewRedisCache(config ExternalCacheConfig) (*RedisCache, error) {
 		return nil, ErrInvalidCacheType
 	}
 
-	client := redis.NewClient(&redis.Options{
-		Addr:     config.RedisURL,
-		Password: config.RedisPassword,
-		DB:       config.RedisDB,
-	})
+	client, err := newRedisClient(config)
+	if err != nil {
+		return nil, err
+	}
+	c := &RedisCache{
+		client:  client,
+		breaker: newCircuitBreaker("Redis cache", config.BreakerThreshold, config.BreakerCooldown),
+		timeout: config.Timeout,
+		config:  config.CacheConfig,
+		ttl:     config.TTL,
+	}
 
-	// Test connection
-	ctx := context.Background()
+	// Redis being down doesn't prevent starting, the cache is bypassed until it is back
+	ctx, cancel := c.context()
+	defer cancel()
 	if err := client.Ping(ctx).Err(); err != nil {
-		return nil, err
+		c.breaker.open(err)
+	}
+	return c, nil
+}
+
+// context returns the context of a call made while serving a request, so a
+// slow Redis fails the call, and eventually trips the breaker, instead of
+// holding up the request
+func (c *RedisCache) context() (context.Context, context.CancelFunc) {
+	if c.timeout <= 0 {
+		return context.Background(), func() {}
+	}
+	return context.WithTimeout(context.Background(), c.timeout)
+}
+
+// adminTimeout bounds purges and metrics, which go through many keys and may
+// take longer than calls made while serving requests
+const adminTimeout = 30 * time.Second
+
+// adminContext returns the context of purges and metrics. It is bounded so a
+// stuck Redis doesn't hold up the background writes waiting for a purge.
+func (c *RedisCache) adminContext() (context.Context, context.CancelFunc) {
+	return context.WithTimeout(context.Background(), max(c.timeout, adminTimeout))
+}
+
+// failed records the outcome of a Redis call with the circuit breaker, reporting whether it failed
+func (c *RedisCache) failed(err error) bool {
+	if err == redis.Nil {
+		err = nil
 	}
+	c.breaker.record(err)
+	return err != nil
+}
 
-	return &RedisCache{
-		client: client,
-		ttl:    config.TTL,
-	}, nil
+// forEachNode calls fn with every master, as keys are sharded across them in a cluster
+func (c *RedisCache) forEachNode(ctx context.Context, fn func(context.Context, *redis.Client) error) error {
+	switch client := c.client.(type) {
+	case *redis.ClusterClient:
+		return client.ForEachMaster(ctx, fn)
+	case *redis.Client:
+		return fn(ctx, client)
+	default:
+		return fmt.Errorf("unsupported Redis client %T", c.client)
+	}
 }
 
 func (c *RedisCache) prefixKey(key string) string {
 	return "blastra:" + key
 }
 
+// tagKey is the key of the set indexing the entries tagged with tag
+func (c *RedisCache) tagKey(tag string) string {
+	return "blastra-tag:" + tag
+}
+
 func (c *RedisCache) Get(key string) (CacheEntry, bool) {
-	ctx := context.Background()
+	if !c.breaker.allow() {
+		c.metrics.misses++
+		return CacheEntry{}, false
+	}
+
+	ctx, cancel := c.context()
+	defer cancel()
 	data, err := c.client.Get(ctx, c.prefixKey(key)).Bytes()
+	if c.failed(err) {
+		log.Errorf("Redis get error: %v", err)
+	}
 	if err != nil {
-		if err != redis.Nil {
-			log.Errorf("Redis get error: %v", err)
-		}
 		c.metrics.misses++
 		return CacheEntry{}, false
 	}
 
-	var entry CacheEntry
-	if err := json.Unmarshal(data, &entry); err != nil {
+	entry, err := unmarshalEntry(data)
+	if err != nil {
 		log.Errorf("Failed to unmarshal cache entry: %v", err)
 		c.metrics.misses++
 		return CacheEntry{}, false
@@ -70,42 +129,223 @@ func (c *RedisCache) Get(key string) (CacheEntry, bool) {
 }
 
 func (c *RedisCache) Set(key string, content []byte) {
-	// Generate ETag
-	hasher := sha256.New()
-	hasher.Write(content)
-	etag := `"` + hex.EncodeToString(hasher.Sum(nil)) + `"`
+	c.SetEntry(key, NewCacheEntry(content), 0)
+}
 
-	entry := CacheEntry{
-		Content:     content,
-		LastUpdated: time.Now(),
-		ETag:        etag,
+func (c *RedisCache) SetEntry(key string, entry CacheEntry, ttl time.Duration) {
+	entry = c.config.stamp(entry, ttl)
+
+	// Keep the entry until it can't be served stale anymore
+	var expiration time.Duration
+	if !entry.ExpiresAt.IsZero() {
+		expiration = time.Until(entry.HardExpiry())
+		if expiration <= 0 {
+			return
+		}
 	}
 
-	data, err := json.Marshal(entry)
+	data, err := marshalEntry(entry)
 	if err != nil {
 		log.Errorf("Failed to marshal cache entry: %v", err)
 		return
 	}
 
-	ctx := context.Background()
-	if err := c.client.Set(ctx, c.prefixKey(key), data, c.ttl).Err(); err != nil {
+	// Only allowed right before calling Redis, so a half-open probe always records its outcome
+	if !c.breaker.allow() {
+		return
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	if err := c.client.Set(ctx, c.prefixKey(key), data, expiration).Err(); c.failed(err) {
 		log.Errorf("Redis set error: %v", err)
+		return
+	}
+	c.indexTags(ctx, key, entry.Tags, expiration)
+}
+
+// indexTags adds key to the sets of its tags, making sure they live at least as long as the entry.
+// Sets aren't cleaned up when an entry is replaced, so they may list keys that lost the tag since,
+// DeleteTag checks the entries before removing them.
+func (c *RedisCache) indexTags(ctx context.Context, key string, tags []string, expiration time.Duration) {
+	for _, tag := range tags {
+		tagKey := c.tagKey(tag)
+		current, err := c.client.TTL(ctx, tagKey).Result()
+		if c.failed(err) {
+			log.Errorf("Redis tag index error: %v", err)
+			continue
+		}
+
+		pipe := c.client.TxPipeline()
+		pipe.SAdd(ctx, tagKey, key)
+		switch {
+		case expiration == 0:
+			pipe.Persist(ctx, tagKey)
+		case current != -1 && current < expiration: // -1 means the set never expires
+			pipe.Expire(ctx, tagKey, expiration)
+		}
+		if _, err := pipe.Exec(ctx); err != nil {
+			log.Errorf("Redis tag index error: %v", err)
+		}
+	}
+}
+
+func (c *RedisCache) Delete(key string) bool {
+	if !c.allowPurge() {
+		return false
+	}
+	ctx, cancel := c.context()
+	defer cancel()
+	removed, err := c.client.Del(ctx, c.prefixKey(key)).Result()
+	if c.failed(err) {
+		log.Errorf("Redis delete error: %v", err)
+	}
+	return removed > 0
+}
+
+func (c *RedisCache) DeletePrefix(prefix string) int {
+	if !c.allowPurge() {
+		return 0
+	}
+	ctx, cancel := c.adminContext()
+	defer cancel()
+	var removed atomic.Int64
+	err := c.forEachNode(ctx, func(ctx context.Context, node *redis.Client) error {
+		iter := node.Scan(ctx, 0, c.prefixKey(escapeGlob(prefix))+"*", 100).Iterator()
+		var batch []string
+		flush := func() {
+			n, err := deleteKeys(ctx, node, batch)
+			if err != nil {
+				log.Errorf("Redis delete error: %v", err)
+			}
+			removed.Add(int64(n))
+			batch = batch[:0]
+		}
+		for iter.Next(ctx) {
+			batch = append(batch, iter.Val())
+			if len(batch) == 100 {
+				flush()
+			}
+		}
+		flush()
+		return iter.Err()
+	})
+	if c.failed(err) {
+		log.Errorf("Redis scan error: %v", err)
 	}
+	return int(removed.Load())
+}
+
+func (c *RedisCache) DeleteTag(tag string) int {
+	if !c.allowPurge() {
+		return 0
+	}
+	ctx, cancel := c.adminContext()
+	defer cancel()
+	tagKey := c.tagKey(tag)
+	keys, err := c.client.SMembers(ctx, tagKey).Result()
+	if c.failed(err) {
+		log.Errorf("Redis tag index error: %v", err)
+		return 0
+	}
+
+	// Entries are read one by one, as they may live on different cluster nodes
+	var tagged []string
+	if len(keys) > 0 {
+		pipe := c.client.Pipeline()
+		gets := make([]*redis.StringCmd, len(keys))
+		for i, key := range keys {
+			gets[i] = pipe.Get(ctx, c.prefixKey(key))
+		}
+		if _, err := pipe.Exec(ctx); c.failed(err) {
+			log.Errorf("Redis get error: %v", err)
+			return 0
+		}
+		for i, get := range gets {
+			data, err := get.Bytes()
+			if err != nil {
+				continue // Already gone
+			}
+			if tags, err := unmarshalEntryTags(data); err == nil && slices.Contains(tags, tag) {
+				tagged = append(tagged, c.prefixKey(keys[i]))
+			}
+		}
+	}
+
+	removed, err := deleteKeys(ctx, c.client, tagged)
+	if err == nil {
+		err = c.client.Del(ctx, tagKey).Err()
+	}
+	if c.failed(err) {
+		log.Errorf("Redis delete error: %v", err)
+	}
+	return removed
+}
+
+// allowPurge reports whether Redis can be purged, warning that entries are left behind otherwise
+func (c *RedisCache) allowPurge() bool {
+	if c.breaker.allow() {
+		return true
+	}
+	log.Warn("Redis cache unavailable, its entries weren't purged")
+	return false
+}
+
+// deleteKeys deletes keys one by one in a pipeline, as a multi-key DEL is
+// refused for keys in different cluster slots, returning how many existed
+func deleteKeys(ctx context.Context, client redis.Cmdable, keys []string) (int, error) {
+	if len(keys) == 0 {
+		return 0, nil
+	}
+	pipe := client.Pipeline()
+	dels := make([]*redis.IntCmd, len(keys))
+	for i, key := range keys {
+		dels[i] = pipe.Del(ctx, key)
+	}
+	_, err := pipe.Exec(ctx)
+	var removed int
+	for _, del := range dels {
+		removed += int(del.Val())
+	}
+	return removed, err
+}
+
+// escapeGlob escapes the characters SCAN MATCH patterns treat specially
+func escapeGlob(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch r {
+		case '*', '?', '[', ']', '\\':
+			b.WriteByte('\\')
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
 }
 
 func (c *RedisCache) GetMetrics() map[string]interface{} {
-	ctx := context.Background()
-	dbSize, err := c.client.DBSize(ctx).Result()
-	if err != nil {
-		log.Errorf("Failed to get Redis DB size: %v", err)
-		dbSize = -1
+	dbSize := int64(-1)
+	if c.breaker.closed() {
+		ctx, cancel := c.adminContext()
+		defer cancel()
+		var size atomic.Int64
+		err := c.forEachNode(ctx, func(ctx context.Context, node *redis.Client) error {
+			n, err := node.DBSize(ctx).Result()
+			size.Add(n)
+			return err
+		})
+		if err != nil {
+			log.Errorf("Failed to get Redis DB size: %v", err)
+		} else {
+			dbSize = size.Load()
+		}
 	}
 
 	return map[string]interface{}{
-		"type":   "redis",
-		"size":   dbSize,
-		"hits":   c.metrics.hits,
-		"misses": c.metrics.misses,
+		"type":    "redis",
+		"size":    dbSize,
+		"hits":    c.metrics.hits,
+		"misses":  c.metrics.misses,
+		"circuit": c.breaker.metrics(),
 	}
 }
 
@@ -1,6 +1,10 @@
 package cache
 
 import (
+	"errors"
+	"net"
+	"net/http"
+	"strings"
 	"testing"
 	"time"
 
@@ -92,6 +96,117 @@ func TestRedisCache(t *testing.T) {
 		}
 	})
 
+	t.Run("per-entry ttl", func(t *testing.T) {
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute},
+			Type:        ExternalCacheRedis,
+			RedisURL:    s.Addr(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		cache.SetEntry("short", NewCacheEntry([]byte("content")), 5*time.Second)
+		if ttl := s.TTL("blastra:short"); ttl <= 0 || ttl > 5*time.Second {
+			t.Errorf("Expected key to expire with the entry TTL, got %v", ttl)
+		}
+	})
+
+	t.Run("stale entries outlive ttl", func(t *testing.T) {
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{
+				TTL:          time.Minute,
+				StaleIfError: time.Hour,
+			},
+			Type:     ExternalCacheRedis,
+			RedisURL: s.Addr(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		cache.Set("stale", []byte("content"))
+		if ttl := s.TTL("blastra:stale"); ttl <= time.Minute || ttl > time.Hour+time.Minute {
+			t.Errorf("Expected key to be kept for TTL plus stale window, got %v", ttl)
+		}
+		entry, found := cache.Get("stale")
+		if !found || entry.StaleIfError != time.Hour {
+			t.Errorf("Expected entry with stale-if-error window, got %+v", entry)
+		}
+	})
+
+	t.Run("status and headers", func(t *testing.T) {
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute},
+			Type:        ExternalCacheRedis,
+			RedisURL:    s.Addr(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		entry := NewCacheEntry([]byte("moved"))
+		entry.Status = http.StatusMovedPermanently
+		entry.Header = http.Header{"Location": {"/new"}, "Link": {"</app.css>; rel=preload", "</app.js>; rel=preload"}}
+		cache.SetEntry("redirect", entry, 0)
+
+		got, found := cache.Get("redirect")
+		if !found {
+			t.Fatal("Expected to find entry")
+		}
+		if got.Status != http.StatusMovedPermanently || got.Header.Get("Location") != "/new" || len(got.Header.Values("Link")) != 2 {
+			t.Errorf("Expected status and headers to round trip, got %d %v", got.Status, got.Header)
+		}
+	})
+
+	t.Run("json entries", func(t *testing.T) {
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute},
+			Type:        ExternalCacheRedis,
+			RedisURL:    s.Addr(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		// Entries written by older versions are still served
+		s.Set("blastra:legacy", `{"Content":"bGVnYWN5","ETag":"\"etag\"","Status":200}`)
+		entry, found := cache.Get("legacy")
+		if !found || string(entry.Content) != "legacy" || entry.ETag != `"etag"` {
+			t.Errorf("Expected JSON entry to be read, got %+v", entry)
+		}
+
+		cache.Set("binary", []byte("content"))
+		if data, _ := s.Get("blastra:binary"); !strings.HasPrefix(data, "BLCE") {
+			t.Errorf("Expected entry to be stored in the binary format, got %q", data)
+		}
+	})
+
+	t.Run("purging", func(t *testing.T) {
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute},
+			Type:        ExternalCacheRedis,
+			RedisURL:    s.Addr(),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		entry := NewCacheEntry([]byte("content"))
+		entry.Tags = []string{"indexed"}
+		cache.SetEntry("/indexed", entry, 0)
+		if ttl := s.TTL("blastra-tag:indexed"); ttl < 50*time.Second || ttl > time.Minute {
+			t.Errorf("Expected tag index to live as long as its entries, got %v", ttl)
+		}
+
+		testPurging(t, cache)
+	})
+
 	t.Run("metrics", func(t *testing.T) {
 		cache, err := NewRedisCache(ExternalCacheConfig{
 			CacheConfig: CacheConfig{
@@ -174,11 +289,133 @@ func TestRedisCache(t *testing.T) {
 		}
 	})
 
-	t.Run("connection error", func(t *testing.T) {
-		_, err := NewRedisCache(ExternalCacheConfig{
+	t.Run("url", func(t *testing.T) {
+		acl := miniredis.RunT(t)
+		acl.RequireUserAuth("blastra", "secret")
+
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute},
+			Type:        ExternalCacheRedis,
+			RedisURL:    "redis://blastra:secret@" + acl.Addr() + "/2",
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		cache.Set("key1", []byte("content"))
+		acl.Select(2)
+		if !acl.Exists("blastra:key1") {
+			t.Error("Expected key to be stored in the database of the URL")
+		}
+	})
+
+	t.Run("cluster", func(t *testing.T) {
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			CacheConfig: CacheConfig{TTL: time.Minute},
+			Type:        ExternalCacheRedis,
+			RedisURL:    s.Addr(),
+			RedisMode:   RedisCluster,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		cache.Set("key1", []byte("content"))
+		if entry, found := cache.Get("key1"); !found || string(entry.Content) != "content" {
+			t.Errorf("Expected to find entry through the cluster client, got %+v", entry)
+		}
+		testPurging(t, cache)
+	})
+
+	t.Run("unavailable", func(t *testing.T) {
+		// Startup doesn't fail, the cache is bypassed instead
+		cache, err := NewRedisCache(ExternalCacheConfig{
 			Type:     ExternalCacheRedis,
 			RedisURL: "invalid:6379",
 		})
+		if err != nil {
+			t.Fatalf("Expected cache to be created while Redis is down, got %v", err)
+		}
+		defer cache.Close()
+
+		cache.Set("key1", []byte("content"))
+		if _, found := cache.Get("key1"); found {
+			t.Error("Expected miss while Redis is down")
+		}
+		circuit := cache.GetMetrics()["circuit"].(map[string]interface{})
+		if circuit["state"] != circuitOpen || circuit["bypassed"].(int64) != 2 {
+			t.Errorf("Expected open circuit bypassing calls, got %v", circuit)
+		}
+	})
+
+	t.Run("skipped writes don't hold the probe", func(t *testing.T) {
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			Type:            ExternalCacheRedis,
+			RedisURL:        s.Addr(),
+			BreakerCooldown: time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+
+		cache.breaker.open(errors.New("down"))
+		time.Sleep(5 * time.Millisecond)
+
+		// Already past its hard expiry, so never written
+		expired := NewCacheEntry([]byte("expired"))
+		expired.ExpiresAt = time.Now().Add(-time.Minute)
+		cache.SetEntry("key1", expired, 0)
+
+		cache.Set("key2", []byte("content"))
+		if entry, found := cache.Get("key2"); !found || string(entry.Content) != "content" {
+			t.Errorf("Expected a write to probe Redis and close the circuit, got %+v", entry)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		// A server that accepts connections but never answers
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatalf("Failed to listen: %v", err)
+		}
+		defer ln.Close()
+		go func() {
+			for {
+				conn, err := ln.Accept()
+				if err != nil {
+					return
+				}
+				defer conn.Close()
+			}
+		}()
+
+		start := time.Now()
+		cache, err := NewRedisCache(ExternalCacheConfig{
+			Type:     ExternalCacheRedis,
+			RedisURL: ln.Addr().String(),
+			Timeout:  50 * time.Millisecond,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create cache: %v", err)
+		}
+		defer cache.Close()
+		cache.Get("key1")
+		if elapsed := time.Since(start); elapsed > time.Second {
+			t.Errorf("Expected calls to give up after the timeout, took %v", elapsed)
+		}
+		if state := cache.breaker.metrics()["state"]; state != circuitOpen {
+			t.Errorf("Expected slow Redis to open the circuit, got %v", state)
+		}
+	})
+
+	t.Run("invalid url", func(t *testing.T) {
+		_, err := NewRedisCache(ExternalCacheConfig{
+			Type:     ExternalCacheRedis,
+			RedisURL: "http://" + s.Addr(),
+		})
 		if err == nil {
 			t.Error("Expected error for invalid Redis URL")
 		}
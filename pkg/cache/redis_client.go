@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// RedisMode selects how Redis is deployed
+type RedisMode string
+
+const (
+	RedisStandalone RedisMode = "standalone" // A single server
+	RedisSentinel   RedisMode = "sentinel"   // A master monitored by Sentinel, failing over to a replica
+	RedisCluster    RedisMode = "cluster"    // A Redis Cluster, keys are sharded across its masters
+)
+
+// newRedisClient creates the client for the Redis deployment described by config.
+//
+// RedisURL is either host:port or a redis:// or rediss:// URL, which can carry
+// the username, password, database and go-redis options like pool_size as
+// query parameters. Sentinel and Cluster take a comma separated list of the
+// sentinels or seed nodes, the credentials and options are taken from the
+// first one. Settings given explicitly in config take precedence over the URL.
+func newRedisClient(config ExternalCacheConfig) (redis.UniversalClient, error) {
+	var addrs []string
+	var base *redis.Options
+	for _, addr := range strings.Split(config.RedisURL, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr == "" {
+			continue
+		}
+		if !strings.Contains(addr, "://") {
+			addrs = append(addrs, addr)
+			continue
+		}
+		opts, err := redis.ParseURL(addr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid Redis URL: %w", err)
+		}
+		addrs = append(addrs, opts.Addr)
+		if base == nil {
+			base = opts
+		}
+	}
+	if len(addrs) == 0 {
+		return nil, errors.New("no Redis address configured")
+	}
+	if base == nil {
+		base = &redis.Options{}
+	}
+
+	opts := &redis.UniversalOptions{
+		Addrs:        addrs,
+		DB:           base.DB,
+		Username:     base.Username,
+		Password:     base.Password,
+		MaxRetries:   base.MaxRetries,
+		DialTimeout:  base.DialTimeout,
+		ReadTimeout:  base.ReadTimeout,
+		WriteTimeout: base.WriteTimeout,
+		PoolSize:     base.PoolSize,
+		MinIdleConns: base.MinIdleConns,
+		PoolTimeout:  base.PoolTimeout,
+		IdleTimeout:  base.IdleTimeout,
+		TLSConfig:    base.TLSConfig,
+		MasterName:   config.RedisSentinelMaster,
+	}
+	if config.RedisUsername != "" {
+		opts.Username = config.RedisUsername
+	}
+	if config.RedisPassword != "" {
+		opts.Password = config.RedisPassword
+	}
+	if config.RedisDB != 0 {
+		opts.DB = config.RedisDB
+	}
+	if config.RedisPoolSize > 0 {
+		opts.PoolSize = config.RedisPoolSize
+	}
+	if config.RedisDialTimeout > 0 {
+		opts.DialTimeout = config.RedisDialTimeout
+	}
+	if config.RedisReadTimeout > 0 {
+		opts.ReadTimeout = config.RedisReadTimeout
+	}
+	if config.RedisWriteTimeout > 0 {
+		opts.WriteTimeout = config.RedisWriteTimeout
+	}
+
+	if opts.TLSConfig != nil || config.RedisTLS || config.RedisTLSCAFile != "" || config.RedisTLSSkipVerify {
+		if opts.TLSConfig == nil {
+			opts.TLSConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+		}
+		if config.RedisTLSCAFile != "" {
+			pem, err := os.ReadFile(config.RedisTLSCAFile)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read Redis CA: %w", err)
+			}
+			pool := x509.NewCertPool()
+			if !pool.AppendCertsFromPEM(pem) {
+				return nil, fmt.Errorf("no certificate found in Redis CA %s", config.RedisTLSCAFile)
+			}
+			opts.TLSConfig.RootCAs = pool
+		}
+		opts.TLSConfig.InsecureSkipVerify = config.RedisTLSSkipVerify
+
+		// Nodes other than the first are verified against their own host name
+		if config.RedisMode == RedisSentinel || config.RedisMode == RedisCluster {
+			opts.TLSConfig.ServerName = ""
+		}
+	}
+
+	switch config.RedisMode {
+	case RedisStandalone, "":
+		if len(addrs) > 1 {
+			return nil, errors.New("standalone Redis takes a single address, use the cluster or sentinel mode for more")
+		}
+		return redis.NewClient(opts.Simple()), nil
+	case RedisSentinel:
+		if opts.MasterName == "" {
+			return nil, errors.New("the Sentinel master name is required in sentinel mode")
+		}
+		// Sentinels are authenticated separately from the master
+		opts.SentinelPassword = config.RedisSentinelPassword
+		return redis.NewFailoverClient(opts.Failover()), nil
+	case RedisCluster:
+		if opts.DB != 0 {
+			return nil, errors.New("only database 0 is available in Redis Cluster")
+		}
+		return redis.NewClusterClient(opts.Cluster()), nil
+	default:
+		return nil, fmt.Errorf("unknown Redis mode %q", config.RedisMode)
+	}
+}
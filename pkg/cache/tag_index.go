@@ -0,0 +1,36 @@
+package cache
+
+// tagIndex maps cache tags to the keys of the entries carrying them. It is not
+// safe for concurrent use, caches guard it with their own lock.
+type tagIndex map[string]map[string]struct{}
+
+func (idx tagIndex) add(key string, tags []string) {
+	for _, tag := range tags {
+		keys, ok := idx[tag]
+		if !ok {
+			keys = make(map[string]struct{})
+			idx[tag] = keys
+		}
+		keys[key] = struct{}{}
+	}
+}
+
+func (idx tagIndex) remove(key string, tags []string) {
+	for _, tag := range tags {
+		if keys, ok := idx[tag]; ok {
+			delete(keys, key)
+			if len(keys) == 0 {
+				delete(idx, tag)
+			}
+		}
+	}
+}
+
+// keys returns the keys tagged with tag
+func (idx tagIndex) keys(tag string) []string {
+	keys := make([]string, 0, len(idx[tag]))
+	for key := range idx[tag] {
+		keys = append(keys, key)
+	}
+	return keys
+}
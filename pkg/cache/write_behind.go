@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// pendingWrite is an entry waiting to be stored in the external cache
+type pendingWrite struct {
+	key   string
+	entry CacheEntry
+	ttl   time.Duration
+}
+
+// writeBehind queues writes and makes them one at a time in the background,
+// in order. A key queued again before being written is only written once,
+// with its latest entry.
+type writeBehind struct {
+	size  int
+	write func(pendingWrite)
+	ready chan struct{}
+
+	stopOnce sync.Once
+	stopping chan struct{} // Closed to end the background goroutine
+	stopped  chan struct{} // Closed once it ended
+
+	mu      sync.Mutex
+	order   []string
+	pending map[string]pendingWrite
+
+	writing sync.Mutex // Held while a write is made
+
+	written atomic.Int64
+	dropped atomic.Int64 // Writes that didn't fit in the queue
+}
+
+func newWriteBehind(size int, write func(pendingWrite)) *writeBehind {
+	w := &writeBehind{
+		size:     size,
+		write:    write,
+		ready:    make(chan struct{}, 1),
+		stopping: make(chan struct{}),
+		stopped:  make(chan struct{}),
+		pending:  make(map[string]pendingWrite),
+	}
+	go w.run()
+	return w
+}
+
+// enqueue queues a write, reporting false if the queue is full and the write was dropped
+func (w *writeBehind) enqueue(write pendingWrite) bool {
+	w.mu.Lock()
+	if _, queued := w.pending[write.key]; !queued {
+		if len(w.order) >= w.size {
+			w.mu.Unlock()
+			w.dropped.Add(1)
+			return false
+		}
+		w.order = append(w.order, write.key)
+	}
+	w.pending[write.key] = write
+	w.mu.Unlock()
+
+	select {
+	case w.ready <- struct{}{}:
+	default: // Already signalled
+	}
+	return true
+}
+
+func (w *writeBehind) run() {
+	defer close(w.stopped)
+	for {
+		select {
+		case <-w.stopping:
+			return
+		case <-w.ready:
+			for w.next() {
+			}
+		}
+	}
+}
+
+// stop ends the background goroutine once the write being made, if any, is
+// made. Writes queued afterwards are only made by drain.
+func (w *writeBehind) stop() {
+	w.stopOnce.Do(func() { close(w.stopping) })
+}
+
+// next makes the oldest queued write, reporting whether there was one
+func (w *writeBehind) next() bool {
+	w.writing.Lock()
+	defer w.writing.Unlock()
+
+	w.mu.Lock()
+	if len(w.order) == 0 {
+		w.mu.Unlock()
+		return false
+	}
+	key := w.order[0]
+	w.order = w.order[1:]
+	write := w.pending[key]
+	delete(w.pending, key)
+	w.mu.Unlock()
+
+	w.write(write)
+	w.written.Add(1)
+	return true
+}
+
+// purge drops the queued writes of the entries selected by inv, then calls
+// fn to purge them. The write being made lands before fn is called, so it is
+// purged too, and no other write is made until fn returns.
+func (w *writeBehind) purge(inv Invalidation, fn func()) {
+	w.mu.Lock()
+	w.order = slices.DeleteFunc(w.order, func(key string) bool {
+		if inv.matches(key, w.pending[key].entry.Tags) {
+			delete(w.pending, key)
+			return true
+		}
+		return false
+	})
+	w.mu.Unlock()
+
+	w.writing.Lock()
+	defer w.writing.Unlock()
+	fn()
+}
+
+// drain makes the queued writes until there are none left or ctx is done,
+// returning how many are still queued
+func (w *writeBehind) drain(ctx context.Context) int {
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil && w.next() {
+		}
+	}()
+	select {
+	case <-done:
+	case <-ctx.Done():
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return len(w.order)
+}
+
+func (w *writeBehind) metrics() map[string]interface{} {
+	w.mu.Lock()
+	queued := len(w.order)
+	w.mu.Unlock()
+	return map[string]interface{}{
+		"queued":   queued,
+		"capacity": w.size,
+		"written":  w.written.Load(),
+		"dropped":  w.dropped.Load(),
+	}
+}
+
+// matches reports whether inv selects the entry stored under key with tags
+func (inv Invalidation) matches(key string, tags []string) bool {
+	switch inv.Kind {
+	case InvalidateKey:
+		return key == inv.Value
+	case InvalidatePrefix:
+		return strings.HasPrefix(key, inv.Value)
+	case InvalidateTag:
+		return slices.Contains(tags, inv.Value)
+	default:
+		return false
+	}
+}
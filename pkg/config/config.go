@@ -2,6 +2,7 @@ package config
 
 import (
 	"errors"
+	"fmt"
 	"os"
 	"runtime"
 	"strconv"
@@ -9,6 +10,7 @@ import (
 	"time"
 
 	"github.com/devthefuture-org/blastra/pkg/cache"
+	"github.com/devthefuture-org/blastra/pkg/utils"
 	log "github.com/sirupsen/logrus"
 	"golang.org/x/time/rate"
 )
@@ -18,6 +20,7 @@ const (
 	DefaultHTTPSPort       = 8443
 	DefaultCacheTTL        = 5 * time.Minute
 	DefaultCacheSize       = 1000
+	DefaultCacheMaxBytes   = 256 << 20
 	DefaultRateLimit       = 100 // requests per second
 	DefaultBurst           = 200
 	DefaultStaticDir       = "./dist/client"
@@ -31,6 +34,19 @@ const (
 	DefaultTrustProxy      = false
 	DefaultWorkerCommand   = "node"
 	DefaultWorkerArgs      = "node_modules/.bin/blastra start"
+
+	DefaultRenderTimeout         = 30 * time.Second
+	DefaultWorkerDialTimeout     = 2 * time.Second
+	DefaultWorkerIdleConnTimeout = 90 * time.Second
+	DefaultWorkerMaxIdleConns    = 64
+	DefaultCoalesceTimeout       = 30 * time.Second
+
+	DefaultExternalCacheTimeout    = 200 * time.Millisecond
+	DefaultExternalCacheWriteQueue = 1000
+
+	DefaultForwardHeaders      = "Accept,Accept-Language,Cookie,User-Agent"
+	DefaultCacheKeyIgnoreQuery = "utm_*,fbclid,gclid"
+	DefaultCacheEncodings      = "br,gzip"
 )
 
 type Configuration struct {
@@ -54,21 +70,47 @@ type Configuration struct {
 	CacheControl      map[string]string // Custom cache control headers
 
 	// Cache settings
-	SSRCacheEnabled   bool // Whether to enable SSR in-memory caching
-	CacheTTL          time.Duration
-	CacheSize         int
-	NotFoundCacheTTL  time.Duration // Optional, defaults to CacheTTL/2 if not set
-	NotFoundCacheSize int           // Optional, defaults to CacheSize/4 if not set
-	ExternalCacheType cache.ExternalCacheType
+	SSRCacheEnabled           bool // Whether to enable SSR in-memory caching
+	CacheTTL                  time.Duration
+	CacheSize                 int
+	NotFoundCacheTTL          time.Duration // Optional, defaults to CacheTTL/2 if not set
+	NotFoundCacheSize         int           // Optional, defaults to CacheSize/4 if not set
+	CacheMaxBytes             int64         // Memory budget of the SSR in-memory cache, 0 for no limit, a page may take up to 1/16 of it
+	NotFoundCacheMaxBytes     int64         // Optional, defaults to CacheMaxBytes/4 if not set
+	CacheStaleWhileRevalidate time.Duration // How long past its TTL a page is served while it is re-rendered in the background
+	CacheStaleIfError         time.Duration // How long past its TTL a page is served when re-rendering it fails
+	ExternalCacheType         cache.ExternalCacheType
+
+	CacheEviction         cache.EvictionPolicy // Eviction policy of the in-memory caches (lru or fifo)
+	CacheStatuses         []int                // Worker response statuses cached in the SSR cache, nil for the defaults
+	NotFoundCacheStatuses []int                // Worker error statuses cached in the 404 cache, nil for the defaults
+	CacheEncodings        []string             // Content codings cached pages are stored in instead of raw, empty to store them raw
 
 	// Redis cache settings
-	RedisURL      string
-	RedisPassword string
-	RedisDB       int
+	RedisURL              string          // host:port or redis:// / rediss:// URL, comma separated for Sentinel and Cluster
+	RedisMode             cache.RedisMode // standalone, sentinel or cluster
+	RedisUsername         string
+	RedisPassword         string
+	RedisDB               int
+	RedisSentinelMaster   string
+	RedisSentinelPassword string
+	RedisTLS              bool
+	RedisTLSCAFile        string
+	RedisTLSSkipVerify    bool
+	RedisPoolSize         int           // Connections per Redis node, 0 for the default
+	RedisDialTimeout      time.Duration // Redis timeouts, 0 for the defaults
+	RedisReadTimeout      time.Duration
+	RedisWriteTimeout     time.Duration
 
 	// Filesystem cache settings
 	CacheDir string
 
+	// External cache resilience settings
+	ExternalCacheTimeout          time.Duration // Deadline of external cache calls made while serving a request (0 disables)
+	ExternalCacheBreakerThreshold int           // Consecutive failures after which the external cache is bypassed
+	ExternalCacheBreakerCooldown  time.Duration // How long the external cache is bypassed before it is probed again
+	ExternalCacheWriteQueue       int           // Writes to the external cache queued in the background (0 writes synchronously)
+
 	// Cache durations
 	MaxAgeStatic int
 	MaxAgeSSR    int
@@ -87,6 +129,36 @@ type Configuration struct {
 	WorkerCommand string   // Command to run worker process
 	WorkerArgs    []string // Arguments for worker command
 	WorkerURLs    []string // External worker URLs (if set, no local workers will be created)
+
+	// Worker proxy settings
+	RenderTimeout               time.Duration            // Default timeout for rendering a page through a worker
+	RouteRenderTimeouts         map[string]time.Duration // Render timeouts by path prefix, the longest prefix wins
+	WorkerDialTimeout           time.Duration            // Timeout for connecting to a worker
+	WorkerResponseHeaderTimeout time.Duration            // Timeout for a worker to send response headers (0 disables)
+	WorkerIdleConnTimeout       time.Duration            // How long idle keep-alive connections to a worker are kept
+	WorkerMaxIdleConns          int                      // Idle keep-alive connections kept per worker
+	WorkerMaxConns              int                      // Connections per worker, including active ones (0 means unlimited)
+	SSRStreaming                bool                     // Stream worker responses to clients as they are rendered
+	CoalesceTimeout             time.Duration            // How long concurrent cache misses wait for a shared render (0 disables coalescing)
+
+	// Request forwarding settings
+	ForwardQuery          bool     // Pass the query string to workers
+	ForwardHeaders        []string // Request headers passed to workers, "*" for all
+	ForwardDenyHeaders    []string // Request headers never passed to workers
+	ForwardUnkeyedHeaders []string // Forwarded headers left out of the cache key
+	ForwardedHeaders      bool     // Send X-Forwarded-For/Proto/Host to workers, keying pages by host and scheme
+	ForwardMethods        []string // Methods besides GET and HEAD proxied to workers uncached, e.g. POST
+
+	// SSR cache key settings (the key follows the forwarding settings when none is set)
+	CacheKeyHost        bool     // Key cached pages by host
+	CacheKeyQuery       []string // Query parameters to key by, "*" for all
+	CacheKeyIgnoreQuery []string // Query parameters never keyed by, a trailing "*" matches a prefix
+	CacheKeyHeaders     []string // Request headers to key by
+	CacheKeyCookies     []string // Cookie names to key by
+	CacheKeyDevice      bool     // Key by device class derived from the User-Agent
+
+	// Admin settings
+	AdminToken string // Bearer token for admin endpoints (disabled when empty)
 }
 
 // Helper function to get PreloadStaticFileList with default value
@@ -105,6 +177,12 @@ func (c *Configuration) ShouldPreloadContent() bool {
 	return *c.PreloadStaticContent
 }
 
+// HasCacheKeyRules reports whether SSR cache key rules are configured
+func (c *Configuration) HasCacheKeyRules() bool {
+	return c.CacheKeyHost || c.CacheKeyDevice || len(c.CacheKeyQuery) > 0 ||
+		len(c.CacheKeyHeaders) > 0 || len(c.CacheKeyCookies) > 0
+}
+
 // GetNotFoundCacheConfig returns the configuration for NotFoundCache,
 // deriving defaults from main cache settings if not explicitly configured
 func (c *Configuration) GetNotFoundCacheConfig() (time.Duration, int) {
@@ -128,6 +206,15 @@ func (c *Configuration) GetNotFoundCacheConfig() (time.Duration, int) {
 	return ttl, size
 }
 
+// GetNotFoundCacheMaxBytes returns the memory budget of the NotFoundCache,
+// deriving it from the SSR cache budget if not explicitly configured
+func (c *Configuration) GetNotFoundCacheMaxBytes() int64 {
+	if c.NotFoundCacheMaxBytes == 0 {
+		return c.CacheMaxBytes / 4
+	}
+	return c.NotFoundCacheMaxBytes
+}
+
 // GetSSRCacheConfig returns the configuration for SSR cache,
 // returning zero values if caching is disabled
 func (c *Configuration) GetSSRCacheConfig() (time.Duration, int) {
@@ -141,14 +228,31 @@ func (c *Configuration) GetSSRCacheConfig() (time.Duration, int) {
 func (c *Configuration) GetExternalCacheConfig() cache.ExternalCacheConfig {
 	return cache.ExternalCacheConfig{
 		CacheConfig: cache.CacheConfig{
-			TTL:     c.CacheTTL,
-			MaxSize: c.CacheSize,
+			TTL:                  c.CacheTTL,
+			MaxSize:              c.CacheSize,
+			StaleWhileRevalidate: c.CacheStaleWhileRevalidate,
+			StaleIfError:         c.CacheStaleIfError,
 		},
-		Type:          c.ExternalCacheType,
-		RedisURL:      c.RedisURL,
-		RedisPassword: c.RedisPassword,
-		RedisDB:       c.RedisDB,
-		CacheDir:      c.CacheDir,
+		Type:                  c.ExternalCacheType,
+		RedisURL:              c.RedisURL,
+		RedisMode:             c.RedisMode,
+		RedisUsername:         c.RedisUsername,
+		RedisPassword:         c.RedisPassword,
+		RedisDB:               c.RedisDB,
+		RedisSentinelMaster:   c.RedisSentinelMaster,
+		RedisSentinelPassword: c.RedisSentinelPassword,
+		RedisTLS:              c.RedisTLS,
+		RedisTLSCAFile:        c.RedisTLSCAFile,
+		RedisTLSSkipVerify:    c.RedisTLSSkipVerify,
+		RedisPoolSize:         c.RedisPoolSize,
+		RedisDialTimeout:      c.RedisDialTimeout,
+		RedisReadTimeout:      c.RedisReadTimeout,
+		RedisWriteTimeout:     c.RedisWriteTimeout,
+		CacheDir:              c.CacheDir,
+		Timeout:               c.ExternalCacheTimeout,
+		BreakerThreshold:      c.ExternalCacheBreakerThreshold,
+		BreakerCooldown:       c.ExternalCacheBreakerCooldown,
+		WriteQueueSize:        c.ExternalCacheWriteQueue,
 	}
 }
 
@@ -200,6 +304,21 @@ func LoadConfiguration() (*Configuration, error) {
 		return val, nil
 	}
 
+	getEnvBytes := func(key string, defaultVal int64) (int64, error) {
+		valStr := os.Getenv("BLASTRA_" + key)
+		if valStr == "" {
+			log.Debugf("Environment variable BLASTRA_%s not set, using default: %d", key, defaultVal)
+			return defaultVal, nil
+		}
+		val, err := utils.ParseByteSize(valStr)
+		if err != nil {
+			log.Errorf("Failed to parse BLASTRA_%s: %v", key, err)
+			return 0, err
+		}
+		log.Debugf("Loaded BLASTRA_%s: %d", key, val)
+		return int64(val), nil
+	}
+
 	var err error
 
 	// Load all configuration values
@@ -267,6 +386,87 @@ func LoadConfiguration() (*Configuration, error) {
 		log.Debugf("Using external worker URLs: %v", config.WorkerURLs)
 	}
 
+	// Load worker proxy settings
+	config.RenderTimeout, err = getEnvDuration("RENDER_TIMEOUT", DefaultRenderTimeout)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_RENDER_TIMEOUT")
+	}
+
+	config.RouteRenderTimeouts, err = parseRouteTimeouts(os.Getenv("BLASTRA_ROUTE_RENDER_TIMEOUTS"))
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_ROUTE_RENDER_TIMEOUTS")
+	}
+
+	config.WorkerDialTimeout, err = getEnvDuration("WORKER_DIAL_TIMEOUT", DefaultWorkerDialTimeout)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_WORKER_DIAL_TIMEOUT")
+	}
+
+	config.WorkerResponseHeaderTimeout, err = getEnvDuration("WORKER_RESPONSE_HEADER_TIMEOUT", 0)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_WORKER_RESPONSE_HEADER_TIMEOUT")
+	}
+
+	config.WorkerIdleConnTimeout, err = getEnvDuration("WORKER_IDLE_CONN_TIMEOUT", DefaultWorkerIdleConnTimeout)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_WORKER_IDLE_CONN_TIMEOUT")
+	}
+
+	config.WorkerMaxIdleConns, err = getEnvInt("WORKER_MAX_IDLE_CONNS", DefaultWorkerMaxIdleConns)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_WORKER_MAX_IDLE_CONNS")
+	}
+
+	config.WorkerMaxConns, err = getEnvInt("WORKER_MAX_CONNS", 0)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_WORKER_MAX_CONNS")
+	}
+
+	config.SSRStreaming = getEnvBool("SSR_STREAMING", false)
+
+	config.CoalesceTimeout, err = getEnvDuration("SSR_COALESCE_TIMEOUT", DefaultCoalesceTimeout)
+	if err != nil || config.CoalesceTimeout < 0 {
+		return nil, errors.New("invalid BLASTRA_SSR_COALESCE_TIMEOUT")
+	}
+
+	// Load request forwarding settings
+	config.ForwardQuery = getEnvBool("FORWARD_QUERY", true)
+	config.ForwardHeaders = parseList(os.Getenv("BLASTRA_FORWARD_HEADERS"))
+	if len(config.ForwardHeaders) == 0 {
+		config.ForwardHeaders = parseList(DefaultForwardHeaders)
+	}
+	config.ForwardDenyHeaders = parseList(os.Getenv("BLASTRA_FORWARD_DENY_HEADERS"))
+	unkeyedHeaders, found := os.LookupEnv("BLASTRA_FORWARD_UNKEYED_HEADERS")
+	if !found {
+		unkeyedHeaders = DefaultForwardHeaders
+	}
+	config.ForwardUnkeyedHeaders = parseList(unkeyedHeaders)
+	config.ForwardedHeaders = getEnvBool("FORWARDED_HEADERS", false)
+
+	config.ForwardMethods = parseList(os.Getenv("BLASTRA_FORWARD_METHODS"))
+	for i, method := range config.ForwardMethods {
+		method = strings.ToUpper(method)
+		if !isToken(method) {
+			return nil, errors.New("invalid BLASTRA_FORWARD_METHODS")
+		}
+		config.ForwardMethods[i] = method
+	}
+
+	// Load SSR cache key settings
+	config.CacheKeyHost = getEnvBool("CACHE_KEY_HOST", false)
+	config.CacheKeyQuery = parseList(os.Getenv("BLASTRA_CACHE_KEY_QUERY"))
+	ignoreQuery, found := os.LookupEnv("BLASTRA_CACHE_KEY_IGNORE_QUERY")
+	if !found {
+		ignoreQuery = DefaultCacheKeyIgnoreQuery
+	}
+	config.CacheKeyIgnoreQuery = parseList(ignoreQuery)
+	config.CacheKeyHeaders = parseList(os.Getenv("BLASTRA_CACHE_KEY_HEADERS"))
+	config.CacheKeyCookies = parseList(os.Getenv("BLASTRA_CACHE_KEY_COOKIES"))
+	config.CacheKeyDevice = getEnvBool("CACHE_KEY_DEVICE", false)
+
+	// Load admin settings
+	config.AdminToken = os.Getenv("BLASTRA_ADMIN_TOKEN")
+
 	// Load cache settings
 	config.SSRCacheEnabled = getEnvBool("SSR_CACHE_ENABLED", DefaultSSRCacheEnabled)
 
@@ -280,6 +480,38 @@ func LoadConfiguration() (*Configuration, error) {
 		return nil, errors.New("invalid BLASTRA_CACHE_SIZE")
 	}
 
+	config.CacheMaxBytes, err = getEnvBytes("CACHE_MAX_BYTES", DefaultCacheMaxBytes)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_CACHE_MAX_BYTES")
+	}
+
+	config.CacheEviction = cache.EvictionPolicy(strings.ToLower(os.Getenv("BLASTRA_CACHE_EVICTION")))
+	switch config.CacheEviction {
+	case "":
+		config.CacheEviction = cache.EvictionLRU
+	case cache.EvictionLRU, cache.EvictionFIFO:
+	default:
+		return nil, errors.New("invalid BLASTRA_CACHE_EVICTION")
+	}
+
+	encodings, found := os.LookupEnv("BLASTRA_CACHE_ENCODINGS")
+	if !found {
+		encodings = DefaultCacheEncodings
+	}
+	config.CacheEncodings = parseList(strings.ToLower(encodings))
+	for _, encoding := range config.CacheEncodings {
+		if encoding != cache.EncodingGzip && encoding != cache.EncodingBrotli {
+			return nil, errors.New("invalid BLASTRA_CACHE_ENCODINGS")
+		}
+	}
+
+	// Pages and redirects can be cached, errors only in the 404 cache. Only 200 is cached
+	// by default, permanent redirects are opt-in, e.g. BLASTRA_CACHE_STATUSES=200,301,308
+	config.CacheStatuses, err = parseStatuses(os.Getenv("BLASTRA_CACHE_STATUSES"), 200, 399)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_CACHE_STATUSES")
+	}
+
 	// Load external cache configuration
 	externalCacheType := os.Getenv("BLASTRA_EXTERNAL_CACHE_TYPE")
 	if externalCacheType == "" {
@@ -289,10 +521,73 @@ func LoadConfiguration() (*Configuration, error) {
 	}
 
 	config.RedisURL = os.Getenv("BLASTRA_REDIS_URL")
+	config.RedisUsername = os.Getenv("BLASTRA_REDIS_USERNAME")
 	config.RedisPassword = os.Getenv("BLASTRA_REDIS_PASSWORD")
 	config.RedisDB, _ = getEnvInt("REDIS_DB", 0)
+
+	config.RedisMode = cache.RedisMode(strings.ToLower(os.Getenv("BLASTRA_REDIS_MODE")))
+	switch config.RedisMode {
+	case "":
+		config.RedisMode = cache.RedisStandalone
+	case cache.RedisStandalone, cache.RedisSentinel, cache.RedisCluster:
+	default:
+		return nil, errors.New("invalid BLASTRA_REDIS_MODE")
+	}
+	config.RedisSentinelMaster = os.Getenv("BLASTRA_REDIS_SENTINEL_MASTER")
+	config.RedisSentinelPassword = os.Getenv("BLASTRA_REDIS_SENTINEL_PASSWORD")
+	if config.RedisMode == cache.RedisSentinel && config.RedisSentinelMaster == "" {
+		return nil, errors.New("BLASTRA_REDIS_SENTINEL_MASTER is required in sentinel mode")
+	}
+
+	config.RedisTLS = getEnvBool("REDIS_TLS", false)
+	config.RedisTLSCAFile = os.Getenv("BLASTRA_REDIS_TLS_CA_FILE")
+	config.RedisTLSSkipVerify = getEnvBool("REDIS_TLS_SKIP_VERIFY", false)
+
+	config.RedisPoolSize, err = getEnvInt("REDIS_POOL_SIZE", 0)
+	if err != nil || config.RedisPoolSize < 0 {
+		return nil, errors.New("invalid BLASTRA_REDIS_POOL_SIZE")
+	}
+	config.RedisDialTimeout, err = getEnvDuration("REDIS_DIAL_TIMEOUT", 0)
+	if err != nil || config.RedisDialTimeout < 0 {
+		return nil, errors.New("invalid BLASTRA_REDIS_DIAL_TIMEOUT")
+	}
+	config.RedisReadTimeout, err = getEnvDuration("REDIS_READ_TIMEOUT", 0)
+	if err != nil || config.RedisReadTimeout < 0 {
+		return nil, errors.New("invalid BLASTRA_REDIS_READ_TIMEOUT")
+	}
+	config.RedisWriteTimeout, err = getEnvDuration("REDIS_WRITE_TIMEOUT", 0)
+	if err != nil || config.RedisWriteTimeout < 0 {
+		return nil, errors.New("invalid BLASTRA_REDIS_WRITE_TIMEOUT")
+	}
 	config.CacheDir = os.Getenv("BLASTRA_CACHE_DIR")
 
+	config.ExternalCacheTimeout, err = getEnvDuration("EXTERNAL_CACHE_TIMEOUT", DefaultExternalCacheTimeout)
+	if err != nil || config.ExternalCacheTimeout < 0 {
+		return nil, errors.New("invalid BLASTRA_EXTERNAL_CACHE_TIMEOUT")
+	}
+	config.ExternalCacheBreakerThreshold, err = getEnvInt("EXTERNAL_CACHE_BREAKER_THRESHOLD", cache.DefaultBreakerThreshold)
+	if err != nil || config.ExternalCacheBreakerThreshold <= 0 {
+		return nil, errors.New("invalid BLASTRA_EXTERNAL_CACHE_BREAKER_THRESHOLD")
+	}
+	config.ExternalCacheBreakerCooldown, err = getEnvDuration("EXTERNAL_CACHE_BREAKER_COOLDOWN", cache.DefaultBreakerCooldown)
+	if err != nil || config.ExternalCacheBreakerCooldown <= 0 {
+		return nil, errors.New("invalid BLASTRA_EXTERNAL_CACHE_BREAKER_COOLDOWN")
+	}
+	config.ExternalCacheWriteQueue, err = getEnvInt("EXTERNAL_CACHE_WRITE_QUEUE", DefaultExternalCacheWriteQueue)
+	if err != nil || config.ExternalCacheWriteQueue < 0 {
+		return nil, errors.New("invalid BLASTRA_EXTERNAL_CACHE_WRITE_QUEUE")
+	}
+
+	config.CacheStaleWhileRevalidate, err = getEnvDuration("CACHE_STALE_WHILE_REVALIDATE", 0)
+	if err != nil || config.CacheStaleWhileRevalidate < 0 {
+		return nil, errors.New("invalid BLASTRA_CACHE_STALE_WHILE_REVALIDATE")
+	}
+
+	config.CacheStaleIfError, err = getEnvDuration("CACHE_STALE_IF_ERROR", 0)
+	if err != nil || config.CacheStaleIfError < 0 {
+		return nil, errors.New("invalid BLASTRA_CACHE_STALE_IF_ERROR")
+	}
+
 	// Load NotFoundCache settings
 	config.NotFoundCacheTTL, err = getEnvDuration("NOTFOUND_CACHE_TTL", 0)
 	if err != nil {
@@ -304,6 +599,16 @@ func LoadConfiguration() (*Configuration, error) {
 		return nil, errors.New("invalid BLASTRA_NOTFOUND_CACHE_SIZE")
 	}
 
+	config.NotFoundCacheMaxBytes, err = getEnvBytes("NOTFOUND_CACHE_MAX_BYTES", 0)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_NOTFOUND_CACHE_MAX_BYTES")
+	}
+
+	config.NotFoundCacheStatuses, err = parseStatuses(os.Getenv("BLASTRA_NOTFOUND_CACHE_STATUSES"), 400, 499)
+	if err != nil {
+		return nil, errors.New("invalid BLASTRA_NOTFOUND_CACHE_STATUSES")
+	}
+
 	// Load rate limiting settings
 	rateLimitStr := os.Getenv("BLASTRA_RATE_LIMIT")
 	if rateLimitStr == "" {
@@ -385,3 +690,64 @@ func LoadConfiguration() (*Configuration, error) {
 
 	return config, nil
 }
+
+// parseRouteTimeouts parses "prefix=duration" pairs separated by commas,
+// e.g. "/reports/=60s,/search=10s"
+func parseRouteTimeouts(value string) (map[string]time.Duration, error) {
+	timeouts := make(map[string]time.Duration)
+	for _, pair := range strings.Split(value, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		prefix, durationStr, found := strings.Cut(pair, "=")
+		prefix = strings.TrimSpace(prefix)
+		if !found || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("invalid route timeout %q", pair)
+		}
+		d, err := time.ParseDuration(strings.TrimSpace(durationStr))
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid route timeout %q", pair)
+		}
+		timeouts[prefix] = d
+	}
+	return timeouts, nil
+}
+
+// parseStatuses parses a comma separated list of HTTP statuses between lowest and highest,
+// returning nil for an empty list
+func parseStatuses(value string, lowest, highest int) ([]int, error) {
+	var statuses []int
+	for _, item := range parseList(value) {
+		status, err := strconv.Atoi(item)
+		if err != nil || status < lowest || status > highest {
+			return nil, fmt.Errorf("invalid status %q", item)
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// parseList splits a comma separated list, dropping empty items
+func parseList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// isToken reports whether s is a valid HTTP method name
+func isToken(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, c := range s {
+		if c < 'A' || c > 'Z' {
+			return false
+		}
+	}
+	return true
+}
@@ -1,6 +1,7 @@
 package config
 
 import (
+	"fmt"
 	"os"
 	"testing"
 	"time"
@@ -11,30 +12,57 @@ import (
 func TestLoadConfiguration(t *testing.T) {
 	// Save original env vars
 	originalEnv := map[string]string{
-		"BLASTRA_HTTP_PORT":           os.Getenv("BLASTRA_HTTP_PORT"),
-		"BLASTRA_HTTPS_PORT":          os.Getenv("BLASTRA_HTTPS_PORT"),
-		"BLASTRA_ENABLE_HTTPS":        os.Getenv("BLASTRA_ENABLE_HTTPS"),
-		"BLASTRA_TLS_CERT_PATH":       os.Getenv("BLASTRA_TLS_CERT_PATH"),
-		"BLASTRA_TLS_KEY_PATH":        os.Getenv("BLASTRA_TLS_KEY_PATH"),
-		"BLASTRA_STATIC_DIR":          os.Getenv("BLASTRA_STATIC_DIR"),
-		"BLASTRA_SSR_SCRIPT":          os.Getenv("BLASTRA_SSR_SCRIPT"),
-		"BLASTRA_SSR_CACHE_ENABLED":   os.Getenv("BLASTRA_SSR_CACHE_ENABLED"),
-		"BLASTRA_CACHE_TTL":           os.Getenv("BLASTRA_CACHE_TTL"),
-		"BLASTRA_CACHE_SIZE":          os.Getenv("BLASTRA_CACHE_SIZE"),
-		"BLASTRA_EXTERNAL_CACHE_TYPE": os.Getenv("BLASTRA_EXTERNAL_CACHE_TYPE"),
-		"BLASTRA_REDIS_URL":           os.Getenv("BLASTRA_REDIS_URL"),
-		"BLASTRA_REDIS_PASSWORD":      os.Getenv("BLASTRA_REDIS_PASSWORD"),
-		"BLASTRA_REDIS_DB":            os.Getenv("BLASTRA_REDIS_DB"),
-		"BLASTRA_CACHE_DIR":           os.Getenv("BLASTRA_CACHE_DIR"),
-		"BLASTRA_RATE_LIMIT":          os.Getenv("BLASTRA_RATE_LIMIT"),
-		"BLASTRA_BURST":               os.Getenv("BLASTRA_BURST"),
-		"BLASTRA_MAX_AGE_STATIC":      os.Getenv("BLASTRA_MAX_AGE_STATIC"),
-		"BLASTRA_MAX_AGE_SSR":         os.Getenv("BLASTRA_MAX_AGE_SSR"),
-		"BLASTRA_SHUTDOWN_TIMEOUT":    os.Getenv("BLASTRA_SHUTDOWN_TIMEOUT"),
-		"BLASTRA_CWD":                 os.Getenv("BLASTRA_CWD"),
-		"BLASTRA_GZIP_ENABLED":        os.Getenv("BLASTRA_GZIP_ENABLED"),
-		"BLASTRA_CPU_LIMIT":           os.Getenv("BLASTRA_CPU_LIMIT"),
-		"BLASTRA_SSR_WORKERS":         os.Getenv("BLASTRA_SSR_WORKERS"),
+		"BLASTRA_HTTP_PORT":                    os.Getenv("BLASTRA_HTTP_PORT"),
+		"BLASTRA_CACHE_MAX_BYTES":              os.Getenv("BLASTRA_CACHE_MAX_BYTES"),
+		"BLASTRA_CACHE_EVICTION":               os.Getenv("BLASTRA_CACHE_EVICTION"),
+		"BLASTRA_CACHE_ENCODINGS":              os.Getenv("BLASTRA_CACHE_ENCODINGS"),
+		"BLASTRA_CACHE_STATUSES":               os.Getenv("BLASTRA_CACHE_STATUSES"),
+		"BLASTRA_NOTFOUND_CACHE_STATUSES":      os.Getenv("BLASTRA_NOTFOUND_CACHE_STATUSES"),
+		"BLASTRA_NOTFOUND_CACHE_MAX_BYTES":     os.Getenv("BLASTRA_NOTFOUND_CACHE_MAX_BYTES"),
+		"BLASTRA_HTTPS_PORT":                   os.Getenv("BLASTRA_HTTPS_PORT"),
+		"BLASTRA_ENABLE_HTTPS":                 os.Getenv("BLASTRA_ENABLE_HTTPS"),
+		"BLASTRA_TLS_CERT_PATH":                os.Getenv("BLASTRA_TLS_CERT_PATH"),
+		"BLASTRA_TLS_KEY_PATH":                 os.Getenv("BLASTRA_TLS_KEY_PATH"),
+		"BLASTRA_STATIC_DIR":                   os.Getenv("BLASTRA_STATIC_DIR"),
+		"BLASTRA_SSR_SCRIPT":                   os.Getenv("BLASTRA_SSR_SCRIPT"),
+		"BLASTRA_SSR_CACHE_ENABLED":            os.Getenv("BLASTRA_SSR_CACHE_ENABLED"),
+		"BLASTRA_CACHE_TTL":                    os.Getenv("BLASTRA_CACHE_TTL"),
+		"BLASTRA_CACHE_SIZE":                   os.Getenv("BLASTRA_CACHE_SIZE"),
+		"BLASTRA_EXTERNAL_CACHE_TYPE":          os.Getenv("BLASTRA_EXTERNAL_CACHE_TYPE"),
+		"BLASTRA_REDIS_URL":                    os.Getenv("BLASTRA_REDIS_URL"),
+		"BLASTRA_REDIS_PASSWORD":               os.Getenv("BLASTRA_REDIS_PASSWORD"),
+		"BLASTRA_REDIS_DB":                     os.Getenv("BLASTRA_REDIS_DB"),
+		"BLASTRA_REDIS_MODE":                   os.Getenv("BLASTRA_REDIS_MODE"),
+		"BLASTRA_REDIS_SENTINEL_MASTER":        os.Getenv("BLASTRA_REDIS_SENTINEL_MASTER"),
+		"BLASTRA_REDIS_TLS":                    os.Getenv("BLASTRA_REDIS_TLS"),
+		"BLASTRA_REDIS_POOL_SIZE":              os.Getenv("BLASTRA_REDIS_POOL_SIZE"),
+		"BLASTRA_REDIS_READ_TIMEOUT":           os.Getenv("BLASTRA_REDIS_READ_TIMEOUT"),
+		"BLASTRA_EXTERNAL_CACHE_TIMEOUT":       os.Getenv("BLASTRA_EXTERNAL_CACHE_TIMEOUT"),
+		"BLASTRA_EXTERNAL_CACHE_WRITE_QUEUE":   os.Getenv("BLASTRA_EXTERNAL_CACHE_WRITE_QUEUE"),
+		"BLASTRA_CACHE_DIR":                    os.Getenv("BLASTRA_CACHE_DIR"),
+		"BLASTRA_RATE_LIMIT":                   os.Getenv("BLASTRA_RATE_LIMIT"),
+		"BLASTRA_BURST":                        os.Getenv("BLASTRA_BURST"),
+		"BLASTRA_MAX_AGE_STATIC":               os.Getenv("BLASTRA_MAX_AGE_STATIC"),
+		"BLASTRA_MAX_AGE_SSR":                  os.Getenv("BLASTRA_MAX_AGE_SSR"),
+		"BLASTRA_SHUTDOWN_TIMEOUT":             os.Getenv("BLASTRA_SHUTDOWN_TIMEOUT"),
+		"BLASTRA_CWD":                          os.Getenv("BLASTRA_CWD"),
+		"BLASTRA_GZIP_ENABLED":                 os.Getenv("BLASTRA_GZIP_ENABLED"),
+		"BLASTRA_CPU_LIMIT":                    os.Getenv("BLASTRA_CPU_LIMIT"),
+		"BLASTRA_SSR_WORKERS":                  os.Getenv("BLASTRA_SSR_WORKERS"),
+		"BLASTRA_RENDER_TIMEOUT":               os.Getenv("BLASTRA_RENDER_TIMEOUT"),
+		"BLASTRA_ROUTE_RENDER_TIMEOUTS":        os.Getenv("BLASTRA_ROUTE_RENDER_TIMEOUTS"),
+		"BLASTRA_WORKER_MAX_IDLE_CONNS":        os.Getenv("BLASTRA_WORKER_MAX_IDLE_CONNS"),
+		"BLASTRA_SSR_COALESCE_TIMEOUT":         os.Getenv("BLASTRA_SSR_COALESCE_TIMEOUT"),
+		"BLASTRA_CACHE_STALE_WHILE_REVALIDATE": os.Getenv("BLASTRA_CACHE_STALE_WHILE_REVALIDATE"),
+		"BLASTRA_CACHE_STALE_IF_ERROR":         os.Getenv("BLASTRA_CACHE_STALE_IF_ERROR"),
+		"BLASTRA_FORWARD_QUERY":                os.Getenv("BLASTRA_FORWARD_QUERY"),
+		"BLASTRA_FORWARD_HEADERS":              os.Getenv("BLASTRA_FORWARD_HEADERS"),
+		"BLASTRA_FORWARD_METHODS":              os.Getenv("BLASTRA_FORWARD_METHODS"),
+		"BLASTRA_CACHE_KEY_QUERY":              os.Getenv("BLASTRA_CACHE_KEY_QUERY"),
+		"BLASTRA_CACHE_KEY_COOKIES":            os.Getenv("BLASTRA_CACHE_KEY_COOKIES"),
+		"BLASTRA_CACHE_KEY_DEVICE":             os.Getenv("BLASTRA_CACHE_KEY_DEVICE"),
+
+		"BLASTRA_EXTERNAL_CACHE_BREAKER_COOLDOWN": os.Getenv("BLASTRA_EXTERNAL_CACHE_BREAKER_COOLDOWN"),
 	}
 
 	// Cleanup function to restore original env vars
@@ -150,6 +178,66 @@ func TestLoadConfiguration(t *testing.T) {
 					"BLASTRA_CACHE_SIZE": "invalid",
 				},
 			},
+			{
+				name: "invalid cache encoding",
+				envVars: map[string]string{
+					"BLASTRA_CACHE_ENCODINGS": "gzip,zip",
+				},
+			},
+			{
+				name: "invalid redis mode",
+				envVars: map[string]string{
+					"BLASTRA_REDIS_MODE": "ring",
+				},
+			},
+			{
+				name: "sentinel without master",
+				envVars: map[string]string{
+					"BLASTRA_REDIS_MODE": "sentinel",
+				},
+			},
+			{
+				name: "invalid redis timeout",
+				envVars: map[string]string{
+					"BLASTRA_REDIS_READ_TIMEOUT": "soon",
+				},
+			},
+			{
+				name: "negative write queue",
+				envVars: map[string]string{
+					"BLASTRA_EXTERNAL_CACHE_WRITE_QUEUE": "-1",
+				},
+			},
+			{
+				name: "invalid breaker cooldown",
+				envVars: map[string]string{
+					"BLASTRA_EXTERNAL_CACHE_BREAKER_COOLDOWN": "0s",
+				},
+			},
+			{
+				name: "invalid cache eviction",
+				envVars: map[string]string{
+					"BLASTRA_CACHE_EVICTION": "random",
+				},
+			},
+			{
+				name: "error status in SSR cache",
+				envVars: map[string]string{
+					"BLASTRA_CACHE_STATUSES": "200,500",
+				},
+			},
+			{
+				name: "page status in 404 cache",
+				envVars: map[string]string{
+					"BLASTRA_NOTFOUND_CACHE_STATUSES": "200",
+				},
+			},
+			{
+				name: "invalid cache max bytes",
+				envVars: map[string]string{
+					"BLASTRA_CACHE_MAX_BYTES": "lots",
+				},
+			},
 			{
 				name: "HTTPS enabled without cert/key",
 				envVars: map[string]string{
@@ -189,10 +277,24 @@ func TestLoadConfiguration(t *testing.T) {
 		os.Setenv("BLASTRA_CACHE_SIZE", "1000")
 		os.Setenv("BLASTRA_NOTFOUND_CACHE_TTL", "2m")
 		os.Setenv("BLASTRA_NOTFOUND_CACHE_SIZE", "500")
+		os.Setenv("BLASTRA_CACHE_MAX_BYTES", "64MB")
+		os.Setenv("BLASTRA_CACHE_EVICTION", "FIFO")
+		os.Setenv("BLASTRA_CACHE_ENCODINGS", "GZIP")
+		os.Setenv("BLASTRA_CACHE_STATUSES", "200, 301")
+		os.Setenv("BLASTRA_NOTFOUND_CACHE_STATUSES", "404,410")
 		os.Setenv("BLASTRA_EXTERNAL_CACHE_TYPE", "redis")
 		os.Setenv("BLASTRA_REDIS_URL", "localhost:6379")
 		os.Setenv("BLASTRA_REDIS_PASSWORD", "secret")
 		os.Setenv("BLASTRA_REDIS_DB", "1")
+		os.Setenv("BLASTRA_REDIS_MODE", "Sentinel")
+		os.Setenv("BLASTRA_REDIS_SENTINEL_MASTER", "blastra")
+		os.Setenv("BLASTRA_REDIS_TLS", "true")
+		os.Setenv("BLASTRA_REDIS_POOL_SIZE", "20")
+		os.Setenv("BLASTRA_REDIS_READ_TIMEOUT", "250ms")
+		os.Setenv("BLASTRA_EXTERNAL_CACHE_TIMEOUT", "50ms")
+		os.Setenv("BLASTRA_EXTERNAL_CACHE_WRITE_QUEUE", "0")
+		os.Setenv("BLASTRA_CACHE_STALE_WHILE_REVALIDATE", "1m")
+		os.Setenv("BLASTRA_CACHE_STALE_IF_ERROR", "1h")
 
 		cfg, err := LoadConfiguration()
 		if err != nil {
@@ -216,6 +318,18 @@ func TestLoadConfiguration(t *testing.T) {
 		if notFoundSize != 500 {
 			t.Errorf("Expected NotFound cache size 500, got %d", notFoundSize)
 		}
+		if cfg.CacheMaxBytes != 64<<20 || cfg.GetNotFoundCacheMaxBytes() != 16<<20 {
+			t.Errorf("Expected 64MB SSR and 16MB NotFound cache budgets, got %d and %d", cfg.CacheMaxBytes, cfg.GetNotFoundCacheMaxBytes())
+		}
+		if len(cfg.CacheEncodings) != 1 || cfg.CacheEncodings[0] != cache.EncodingGzip {
+			t.Errorf("Expected gzip cache encoding, got %v", cfg.CacheEncodings)
+		}
+		if cfg.CacheEviction != cache.EvictionFIFO {
+			t.Errorf("Expected fifo eviction, got %q", cfg.CacheEviction)
+		}
+		if fmt.Sprint(cfg.CacheStatuses, cfg.NotFoundCacheStatuses) != "[200 301] [404 410]" {
+			t.Errorf("Unexpected cached statuses %v and %v", cfg.CacheStatuses, cfg.NotFoundCacheStatuses)
+		}
 
 		// Test external cache config
 		extConfig := cfg.GetExternalCacheConfig()
@@ -231,5 +345,140 @@ func TestLoadConfiguration(t *testing.T) {
 		if extConfig.RedisDB != 1 {
 			t.Errorf("Expected Redis DB 1, got %d", extConfig.RedisDB)
 		}
+		if extConfig.RedisMode != cache.RedisSentinel || extConfig.RedisSentinelMaster != "blastra" || !extConfig.RedisTLS {
+			t.Errorf("Expected Sentinel over TLS, got %q %q %v", extConfig.RedisMode, extConfig.RedisSentinelMaster, extConfig.RedisTLS)
+		}
+		if extConfig.RedisPoolSize != 20 || extConfig.RedisReadTimeout != 250*time.Millisecond {
+			t.Errorf("Expected Redis pool size 20 and read timeout 250ms, got %d and %v", extConfig.RedisPoolSize, extConfig.RedisReadTimeout)
+		}
+		if extConfig.Timeout != 50*time.Millisecond || extConfig.WriteQueueSize != 0 {
+			t.Errorf("Expected 50ms timeout and synchronous writes, got %v and %d", extConfig.Timeout, extConfig.WriteQueueSize)
+		}
+		if extConfig.BreakerThreshold != cache.DefaultBreakerThreshold || extConfig.BreakerCooldown != cache.DefaultBreakerCooldown {
+			t.Errorf("Expected default circuit breaker, got %d and %v", extConfig.BreakerThreshold, extConfig.BreakerCooldown)
+		}
+		if extConfig.StaleWhileRevalidate != time.Minute || extConfig.StaleIfError != time.Hour {
+			t.Errorf("Expected stale windows 1m and 1h, got %v and %v", extConfig.StaleWhileRevalidate, extConfig.StaleIfError)
+		}
+	})
+
+	t.Run("worker proxy configuration", func(t *testing.T) {
+		for key := range originalEnv {
+			os.Unsetenv(key)
+		}
+
+		cfg, err := LoadConfiguration()
+		if err != nil {
+			t.Fatalf("Failed to load default configuration: %v", err)
+		}
+		if cfg.RenderTimeout != DefaultRenderTimeout {
+			t.Errorf("Expected render timeout %v, got %v", DefaultRenderTimeout, cfg.RenderTimeout)
+		}
+		if cfg.WorkerMaxIdleConns != DefaultWorkerMaxIdleConns {
+			t.Errorf("Expected %d idle connections per worker, got %d", DefaultWorkerMaxIdleConns, cfg.WorkerMaxIdleConns)
+		}
+		if cfg.CoalesceTimeout != DefaultCoalesceTimeout {
+			t.Errorf("Expected coalesce timeout %v, got %v", DefaultCoalesceTimeout, cfg.CoalesceTimeout)
+		}
+
+		os.Setenv("BLASTRA_RENDER_TIMEOUT", "10s")
+		os.Setenv("BLASTRA_ROUTE_RENDER_TIMEOUTS", "/reports/=1m, /search=3s")
+		os.Setenv("BLASTRA_WORKER_MAX_IDLE_CONNS", "8")
+
+		cfg, err = LoadConfiguration()
+		if err != nil {
+			t.Fatalf("Failed to load worker proxy configuration: %v", err)
+		}
+		if cfg.RenderTimeout != 10*time.Second {
+			t.Errorf("Expected render timeout 10s, got %v", cfg.RenderTimeout)
+		}
+		if cfg.RouteRenderTimeouts["/reports/"] != time.Minute || cfg.RouteRenderTimeouts["/search"] != 3*time.Second {
+			t.Errorf("Unexpected route render timeouts %v", cfg.RouteRenderTimeouts)
+		}
+		if cfg.WorkerMaxIdleConns != 8 {
+			t.Errorf("Expected 8 idle connections per worker, got %d", cfg.WorkerMaxIdleConns)
+		}
+
+		for _, invalid := range []string{"reports=1m", "/reports/", "/reports/=soon"} {
+			os.Setenv("BLASTRA_ROUTE_RENDER_TIMEOUTS", invalid)
+			if _, err := LoadConfiguration(); err == nil {
+				t.Errorf("Expected error for route timeouts %q", invalid)
+			}
+		}
+	})
+
+	t.Run("request forwarding configuration", func(t *testing.T) {
+		for key := range originalEnv {
+			os.Unsetenv(key)
+		}
+
+		cfg, err := LoadConfiguration()
+		if err != nil {
+			t.Fatalf("Failed to load default configuration: %v", err)
+		}
+		if !cfg.ForwardQuery {
+			t.Error("Expected the query to be forwarded by default")
+		}
+		if cfg.ForwardedHeaders {
+			t.Error("Expected X-Forwarded headers to be opt-in")
+		}
+		if len(cfg.ForwardHeaders) != 4 || len(cfg.ForwardUnkeyedHeaders) != 4 {
+			t.Errorf("Expected default forwarded headers, got %v (unkeyed %v)", cfg.ForwardHeaders, cfg.ForwardUnkeyedHeaders)
+		}
+
+		os.Setenv("BLASTRA_FORWARD_QUERY", "false")
+		os.Setenv("BLASTRA_FORWARD_HEADERS", "Accept, X-Tenant")
+		os.Setenv("BLASTRA_FORWARD_METHODS", "post")
+
+		cfg, err = LoadConfiguration()
+		if err != nil {
+			t.Fatalf("Failed to load forwarding configuration: %v", err)
+		}
+		if cfg.ForwardQuery {
+			t.Error("Expected query forwarding to be disabled")
+		}
+		if len(cfg.ForwardHeaders) != 2 || cfg.ForwardHeaders[1] != "X-Tenant" {
+			t.Errorf("Unexpected forwarded headers %v", cfg.ForwardHeaders)
+		}
+		if len(cfg.ForwardMethods) != 1 || cfg.ForwardMethods[0] != "POST" {
+			t.Errorf("Expected POST to be proxied, got %v", cfg.ForwardMethods)
+		}
+
+		os.Setenv("BLASTRA_FORWARD_METHODS", "PO ST")
+		if _, err := LoadConfiguration(); err == nil {
+			t.Error("Expected error for invalid forward method")
+		}
+	})
+
+	t.Run("cache key configuration", func(t *testing.T) {
+		for key := range originalEnv {
+			os.Unsetenv(key)
+		}
+
+		cfg, err := LoadConfiguration()
+		if err != nil {
+			t.Fatalf("Failed to load default configuration: %v", err)
+		}
+		if cfg.HasCacheKeyRules() {
+			t.Error("Expected no cache key rules by default")
+		}
+
+		os.Setenv("BLASTRA_CACHE_KEY_QUERY", "page, sort")
+		os.Setenv("BLASTRA_CACHE_KEY_COOKIES", "currency")
+		os.Setenv("BLASTRA_CACHE_KEY_DEVICE", "true")
+
+		cfg, err = LoadConfiguration()
+		if err != nil {
+			t.Fatalf("Failed to load cache key configuration: %v", err)
+		}
+		if !cfg.HasCacheKeyRules() || !cfg.CacheKeyDevice {
+			t.Error("Expected cache key rules to be configured")
+		}
+		if len(cfg.CacheKeyQuery) != 2 || cfg.CacheKeyQuery[1] != "sort" {
+			t.Errorf("Unexpected cache key query params %v", cfg.CacheKeyQuery)
+		}
+		if len(cfg.CacheKeyIgnoreQuery) == 0 {
+			t.Error("Expected tracking params to be ignored by default")
+		}
 	})
 }
@@ -4,7 +4,10 @@ import (
 	"fmt"
 	"net"
 	"net/http"
+	"os"
+	"os/signal"
 	"runtime"
+	"syscall"
 	"time"
 
 	log "github.com/sirupsen/logrus"
@@ -30,6 +33,21 @@ func waitForServer(port int, maxRetries int) {
 	}
 }
 
+// handleReloadSignal triggers a rolling restart of the workers on SIGHUP,
+// e.g. after deploying a new server bundle in place
+func handleReloadSignal(wp worker.IWorkerPool) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	go func() {
+		for range hup {
+			log.Info("SIGHUP received, reloading workers")
+			if err := wp.RollingRestart("SIGHUP"); err != nil {
+				log.Errorf("Rolling restart failed: %v", err)
+			}
+		}
+	}()
+}
+
 func main() {
 	// Configure logging
 	logging.ConfigureLogging()
@@ -51,28 +69,48 @@ func main() {
 	if cfg.SSRCacheEnabled {
 		// Initialize SSR cache if enabled
 		cacheTTL, cacheSize := cfg.GetSSRCacheConfig()
-		ssrMemoryCache := cache.NewSSRInMemoryCache(cache.CacheConfig{
-			TTL:     cacheTTL,
-			MaxSize: cacheSize,
+		ssrMemoryCache := cache.NewInMemoryCache(cache.InMemoryCacheConfig{
+			CacheConfig: cache.CacheConfig{
+				TTL:                  cacheTTL,
+				MaxSize:              cacheSize,
+				MaxBytes:             cfg.CacheMaxBytes,
+				StaleWhileRevalidate: cfg.CacheStaleWhileRevalidate,
+				StaleIfError:         cfg.CacheStaleIfError,
+			},
+			Name:     "SSR",
+			Eviction: cfg.CacheEviction,
 		})
 
 		// Initialize NotFoundCache with configuration from config package
 		notFoundTTL, notFoundSize := cfg.GetNotFoundCacheConfig()
-		notFoundMemoryCache := cache.NewNotFoundInMemoryCache(cache.CacheConfig{
-			TTL:     notFoundTTL,
-			MaxSize: notFoundSize,
+		notFoundMemoryCache := cache.NewInMemoryCache(cache.InMemoryCacheConfig{
+			CacheConfig: cache.CacheConfig{
+				TTL:      notFoundTTL,
+				MaxSize:  notFoundSize,
+				MaxBytes: cfg.GetNotFoundCacheMaxBytes(),
+			},
+			Name:     "404",
+			Eviction: cfg.CacheEviction,
+			Negative: true,
 		})
 
 		// Create cache providers with external caches if configured
 		externalConfig := cfg.GetExternalCacheConfig()
 
+		// Both caches share the invalidations of the external cache
+		cacheFactory := cache.NewProviderFactory()
+
 		var err error
-		ssrCacheProvider, err = cache.CreateCacheProvider(ssrMemoryCache, externalConfig)
+		ssrCacheProvider, err = cacheFactory.CreateCacheProvider("ssr", ssrMemoryCache, externalConfig)
 		if err != nil {
 			log.Fatalf("Failed to create SSR cache provider: %v", err)
 		}
 
-		notFoundCacheProvider, err = cache.CreateCacheProvider(notFoundMemoryCache, externalConfig)
+		// 404s are never served stale
+		notFoundExternalConfig := externalConfig
+		notFoundExternalConfig.StaleWhileRevalidate = 0
+		notFoundExternalConfig.StaleIfError = 0
+		notFoundCacheProvider, err = cacheFactory.CreateCacheProvider("404", notFoundMemoryCache, notFoundExternalConfig)
 		if err != nil {
 			log.Fatalf("Failed to create NotFound cache provider: %v", err)
 		}
@@ -93,12 +131,54 @@ func main() {
 	}
 
 	// Initialize server
-	ssrHandler := server.SSRHandler(ssrCacheProvider, notFoundCacheProvider, cfg.SSRScript, cfg.MaxAgeSSR, cfg.BlastraCWD, wp)
+	workerProxy := server.NewWorkerProxy(server.WorkerProxyConfig{
+		RenderTimeout:         cfg.RenderTimeout,
+		RouteTimeouts:         cfg.RouteRenderTimeouts,
+		DialTimeout:           cfg.WorkerDialTimeout,
+		ResponseHeaderTimeout: cfg.WorkerResponseHeaderTimeout,
+		IdleConnTimeout:       cfg.WorkerIdleConnTimeout,
+		MaxIdleConnsPerWorker: cfg.WorkerMaxIdleConns,
+		MaxConnsPerWorker:     cfg.WorkerMaxConns,
+		Streaming:             cfg.SSRStreaming,
+		Forwarding: &server.ForwardingPolicy{
+			ForwardQuery:     cfg.ForwardQuery,
+			AllowHeaders:     cfg.ForwardHeaders,
+			DenyHeaders:      cfg.ForwardDenyHeaders,
+			UnkeyedHeaders:   cfg.ForwardUnkeyedHeaders,
+			ForwardedHeaders: cfg.ForwardedHeaders,
+			TrustProxy:       cfg.TrustProxy,
+			ProxyMethods:     cfg.ForwardMethods,
+		},
+		CacheStatuses:         cfg.CacheStatuses,
+		NegativeCacheStatuses: cfg.NotFoundCacheStatuses,
+		CacheEncodings:        cfg.CacheEncodings,
+	})
+	var cacheKeys *server.CacheKeyBuilder
+	if cfg.HasCacheKeyRules() {
+		cacheKeys = server.NewCacheKeyBuilder(server.CacheKeyRules{
+			Host:              cfg.CacheKeyHost,
+			QueryParams:       cfg.CacheKeyQuery,
+			IgnoreQueryParams: cfg.CacheKeyIgnoreQuery,
+			Headers:           cfg.CacheKeyHeaders,
+			Cookies:           cfg.CacheKeyCookies,
+			DeviceClass:       cfg.CacheKeyDevice,
+		})
+	}
+	var coalescer *server.RenderCoalescer
+	if cfg.CoalesceTimeout > 0 {
+		coalescer = server.NewRenderCoalescer(cfg.CoalesceTimeout)
+	}
+	ssrHandler := server.SSRHandler(ssrCacheProvider, notFoundCacheProvider, cfg.SSRScript, cfg.MaxAgeSSR, cfg.BlastraCWD, wp, workerProxy, cacheKeys, coalescer)
 	serverConfig := &server.Config{
 		BlastraCWD:    cfg.BlastraCWD,
 		StaticDir:     cfg.StaticDir,
 		SSRHandler:    ssrHandler,
 		HealthChecker: healthChecker,
+		WorkerPool:    wp,
+		AdminToken:    cfg.AdminToken,
+		Coalescer:     coalescer,
+		SSRCache:      ssrCacheProvider,
+		NotFoundCache: notFoundCacheProvider,
 	}
 
 	serverInitConfig := &server.ServerInitConfig{
@@ -122,7 +202,11 @@ func main() {
 		WorkerPool:      wp,
 		ShutdownTimeout: cfg.ShutdownTimeout,
 	}
+	if cfg.SSRCacheEnabled {
+		shutdownConfig.Caches = []*cache.CacheProvider{ssrCacheProvider, notFoundCacheProvider}
+	}
 	serverErrors := shutdown.HandleGracefulShutdown(shutdownConfig)
+	handleReloadSignal(wp)
 
 	// Start HTTP server
 	go func() {
@@ -17,15 +17,70 @@ var gzipWriterPool = sync.Pool{
 	},
 }
 
+// GzipResponseWriter compresses the response unless the handler already
+// encoded it, which is decided when the response starts
 type GzipResponseWriter struct {
-	io.Writer
+	io.Writer // The gzip writer, or the response writer for responses passed through, nil until the response starts
 	http.ResponseWriter
+	gz *gzip.Writer
+}
+
+func (w *GzipResponseWriter) WriteHeader(status int) {
+	w.start()
+	w.ResponseWriter.WriteHeader(status)
 }
 
 func (w *GzipResponseWriter) Write(b []byte) (int, error) {
+	w.start()
 	return w.Writer.Write(b)
 }
 
+// start picks the writer for the response: responses the handler already
+// encoded (e.g. pre-compressed cached pages) are passed through as is
+func (w *GzipResponseWriter) start() {
+	if w.Writer != nil {
+		return
+	}
+	if w.Header().Get("Content-Encoding") != "" {
+		w.Writer = w.ResponseWriter
+		return
+	}
+
+	w.gz = gzipWriterPool.Get().(*gzip.Writer)
+	w.gz.Reset(w.ResponseWriter)
+	w.Writer = w.gz
+	w.Header().Set("Content-Encoding", "gzip")
+	w.Header().Del("Content-Length")
+	w.Header().Add("Vary", "Accept-Encoding")
+}
+
+// close flushes the compressed response and returns the gzip writer to the pool
+func (w *GzipResponseWriter) close() {
+	if w.gz != nil {
+		w.gz.Close()
+		gzipWriterPool.Put(w.gz)
+	}
+}
+
+// Flush sends the compressed bytes written so far to the client, so streamed
+// responses are not held back by the gzip buffer
+func (w *GzipResponseWriter) Flush() {
+	w.start()
+	if w.gz != nil {
+		if err := w.gz.Flush(); err != nil {
+			return
+		}
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (w *GzipResponseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
 func GzipMiddleware(enabled bool) func(http.Handler) http.Handler {
 	if !enabled {
 		log.Debug("Gzip disabled")
@@ -43,21 +98,8 @@ func GzipMiddleware(enabled bool) func(http.Handler) http.Handler {
 				return
 			}
 
-			gz := gzipWriterPool.Get().(*gzip.Writer)
-			gz.Reset(w)
-			defer func() {
-				gz.Close()
-				gzipWriterPool.Put(gz)
-			}()
-
-			w.Header().Set("Content-Encoding", "gzip")
-			w.Header().Del("Content-Length")
-			w.Header().Add("Vary", "Accept-Encoding")
-
-			gzw := &GzipResponseWriter{
-				Writer:         gz,
-				ResponseWriter: w,
-			}
+			gzw := &GzipResponseWriter{ResponseWriter: w}
+			defer gzw.close()
 			next.ServeHTTP(gzw, r)
 		})
 	}
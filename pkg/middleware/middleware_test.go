@@ -158,4 +158,63 @@ func TestGzipMiddleware(t *testing.T) {
 			t.Error(err)
 		}
 	})
+
+	t.Run("flush streams compressed bytes", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		middleware := GzipMiddleware(true)
+		handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("first"))
+			if err := http.NewResponseController(w).Flush(); err != nil {
+				t.Fatalf("Expected gzip writer to support flushing: %v", err)
+			}
+
+			// The first chunk must be readable before the handler completes
+			if !rec.Flushed {
+				t.Error("Expected underlying writer to be flushed")
+			}
+			reader, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+			if err != nil {
+				t.Fatalf("Failed to create gzip reader: %v", err)
+			}
+			chunk := make([]byte, 5)
+			if _, err := io.ReadFull(reader, chunk); err != nil || string(chunk) != "first" {
+				t.Errorf("Expected flushed chunk 'first', got '%s' (%v)", chunk, err)
+			}
+
+			w.Write([]byte(" second"))
+		}))
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Accept-Encoding", "gzip")
+		handler.ServeHTTP(rec, req)
+
+		reader, err := gzip.NewReader(rec.Body)
+		if err != nil {
+			t.Fatalf("Failed to create gzip reader: %v", err)
+		}
+		content, _ := io.ReadAll(reader)
+		if string(content) != "first second" {
+			t.Errorf("Expected content 'first second', got '%s'", content)
+		}
+	})
+
+	t.Run("pre-encoded responses pass through", func(t *testing.T) {
+		middleware := GzipMiddleware(true)
+		handler := middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Encoding", "br")
+			w.Write([]byte("already compressed"))
+		}))
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		req.Header.Set("Accept-Encoding", "gzip, br")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Header().Get("Content-Encoding") != "br" {
+			t.Errorf("Expected Content-Encoding br, got %q", rec.Header().Get("Content-Encoding"))
+		}
+		if rec.Body.String() != "already compressed" {
+			t.Errorf("Expected body to be written as is, got %q", rec.Body.String())
+		}
+	})
 }
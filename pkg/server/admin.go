@@ -0,0 +1,169 @@
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+	"github.com/devthefuture-org/blastra/pkg/worker"
+)
+
+// AdminPathPrefix is the URL prefix of the authenticated admin endpoints
+const AdminPathPrefix = "/__blastra/admin/"
+
+// requireAdminToken only lets requests through that carry the admin token as a bearer token
+func requireAdminToken(token string, next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+			log.Warnf("Rejected unauthenticated admin request for %s", r.URL.Path)
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next(w, r)
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Errorf("Failed to encode admin response: %v", err)
+	}
+}
+
+// SetupAdminRoutes registers the admin endpoints. They are only enabled when an admin token is configured.
+func SetupAdminRoutes(mux *http.ServeMux, config *Config) {
+	if config.AdminToken == "" {
+		log.Debug("Admin endpoints disabled (no admin token configured)")
+		return
+	}
+
+	mux.HandleFunc(AdminPathPrefix+"workers", requireAdminToken(config.AdminToken, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		var statuses []worker.WorkerStatus
+		if config.WorkerPool != nil {
+			statuses = config.WorkerPool.GetWorkerStatuses()
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{"workers": statuses})
+	}))
+
+	mux.HandleFunc(AdminPathPrefix+"workers/restart", requireAdminToken(config.AdminToken, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if config.WorkerPool == nil {
+			http.Error(w, worker.ErrRestartUnsupported.Error(), http.StatusConflict)
+			return
+		}
+
+		// Rolling restarts take a while, run it in the background and report progress in the logs
+		go func() {
+			if err := config.WorkerPool.RollingRestart("admin endpoint"); err != nil {
+				log.Errorf("Rolling restart failed: %v", err)
+			}
+		}()
+		writeJSON(w, http.StatusAccepted, map[string]string{"status": "rolling restart started"})
+	}))
+
+	mux.HandleFunc(AdminPathPrefix+"metrics", requireAdminToken(config.AdminToken, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		metrics := make(map[string]interface{})
+		if config.Coalescer != nil {
+			metrics["coalescing"] = config.Coalescer.GetMetrics()
+		}
+		if config.SSRCache != nil {
+			metrics["ssr_cache"] = config.SSRCache.GetMetrics()
+		}
+		if config.NotFoundCache != nil {
+			metrics["not_found_cache"] = config.NotFoundCache.GetMetrics()
+		}
+		if config.WorkerPool != nil {
+			metrics["workers"] = workerMetrics(config.WorkerPool.GetWorkerStatuses())
+		}
+		writeJSON(w, http.StatusOK, metrics)
+	}))
+
+	mux.HandleFunc(AdminPathPrefix+"cache/purge", requireAdminToken(config.AdminToken, func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "Method Not Allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if config.SSRCache == nil && config.NotFoundCache == nil {
+			http.Error(w, "SSR caching is disabled", http.StatusConflict)
+			return
+		}
+
+		var req purgeRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			http.Error(w, "Invalid purge request: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		if len(req.Keys)+len(req.Prefixes)+len(req.Tags) == 0 {
+			http.Error(w, "Nothing to purge: expected keys, prefixes or tags", http.StatusBadRequest)
+			return
+		}
+
+		purged := req.purge(config.SSRCache, config.NotFoundCache)
+		log.Infof("Purged %d cache entries (keys: %v, prefixes: %v, tags: %v)", purged, req.Keys, req.Prefixes, req.Tags)
+		writeJSON(w, http.StatusOK, map[string]int{"purged": purged})
+	}))
+
+	log.Debugf("Admin endpoints enabled under %s", AdminPathPrefix)
+}
+
+// workerMetrics returns the last sampled resource usage of each worker, by slot
+func workerMetrics(statuses []worker.WorkerStatus) map[string]interface{} {
+	metrics := make(map[string]interface{}, len(statuses))
+	for _, status := range statuses {
+		metrics[strconv.Itoa(status.Slot)] = map[string]interface{}{
+			"pid":         status.PID,
+			"state":       status.State,
+			"rss_bytes":   status.RSSBytes,
+			"cpu_seconds": status.CPUSeconds,
+			"cpu_percent": status.CPUPercent,
+		}
+	}
+	return metrics
+}
+
+// purgeRequest selects the cache entries to purge, by exact key, key prefix or tag
+type purgeRequest struct {
+	Keys     []string `json:"keys"`
+	Prefixes []string `json:"prefixes"`
+	Tags     []string `json:"tags"`
+}
+
+// purge removes the selected entries from all providers, returning how many were removed across all cache tiers
+func (req purgeRequest) purge(providers ...*cache.CacheProvider) int {
+	var purged int
+	for _, provider := range providers {
+		if provider == nil {
+			continue
+		}
+		for _, key := range req.Keys {
+			purged += provider.PurgeKey(key)
+		}
+		for _, prefix := range req.Prefixes {
+			purged += provider.PurgePrefix(prefix)
+		}
+		for _, tag := range req.Tags {
+			purged += provider.PurgeTag(tag)
+		}
+	}
+	return purged
+}
@@ -0,0 +1,168 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+	"github.com/devthefuture-org/blastra/pkg/worker"
+)
+
+func TestAdminRoutes(t *testing.T) {
+	newMux := func(token string) *http.ServeMux {
+		mux := http.NewServeMux()
+		SetupAdminRoutes(mux, &Config{
+			WorkerPool: &testWorkerPool{endpoint: "http://localhost", enabled: true, statuses: []worker.WorkerStatus{
+				{Slot: 0, State: "ready", RSSBytes: 64 << 20, CPUPercent: 12.5},
+			}},
+			AdminToken: token,
+			Coalescer:  NewRenderCoalescer(time.Second),
+			SSRCache:   cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil),
+		})
+		return mux
+	}
+
+	t.Run("disabled without token", func(t *testing.T) {
+		req := httptest.NewRequest("GET", AdminPathPrefix+"workers", nil)
+		w := httptest.NewRecorder()
+		newMux("").ServeHTTP(w, req)
+
+		if w.Code != http.StatusNotFound {
+			t.Errorf("Expected status 404, got %d", w.Code)
+		}
+	})
+
+	t.Run("rejects missing or wrong token", func(t *testing.T) {
+		mux := newMux("secret")
+		for _, auth := range []string{"", "Bearer wrong", "secret"} {
+			req := httptest.NewRequest("GET", AdminPathPrefix+"workers", nil)
+			if auth != "" {
+				req.Header.Set("Authorization", auth)
+			}
+			w := httptest.NewRecorder()
+			mux.ServeHTTP(w, req)
+
+			if w.Code != http.StatusUnauthorized {
+				t.Errorf("Authorization %q: expected status 401, got %d", auth, w.Code)
+			}
+		}
+	})
+
+	t.Run("lists workers", func(t *testing.T) {
+		req := httptest.NewRequest("GET", AdminPathPrefix+"workers", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		newMux("secret").ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var body map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if _, ok := body["workers"]; !ok {
+			t.Error("Expected workers key in response")
+		}
+	})
+
+	t.Run("starts rolling restart", func(t *testing.T) {
+		mux := newMux("secret")
+
+		req := httptest.NewRequest("GET", AdminPathPrefix+"workers/restart", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusMethodNotAllowed {
+			t.Errorf("Expected status 405 for GET, got %d", w.Code)
+		}
+
+		req = httptest.NewRequest("POST", AdminPathPrefix+"workers/restart", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w = httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		if w.Code != http.StatusAccepted {
+			t.Errorf("Expected status 202, got %d", w.Code)
+		}
+	})
+
+	t.Run("reports metrics", func(t *testing.T) {
+		req := httptest.NewRequest("GET", AdminPathPrefix+"metrics", nil)
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		newMux("secret").ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Fatalf("Expected status 200, got %d", w.Code)
+		}
+		var body map[string]map[string]interface{}
+		if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+			t.Fatalf("Failed to decode response: %v", err)
+		}
+		if _, ok := body["coalescing"]["coalesced"]; !ok {
+			t.Errorf("Expected coalescing metrics, got %v", body)
+		}
+		if _, ok := body["ssr_cache"]["memory"]; !ok {
+			t.Errorf("Expected SSR cache metrics, got %v", body)
+		}
+		if usage, ok := body["workers"]["0"].(map[string]interface{}); !ok || usage["rss_bytes"] != float64(64<<20) || usage["cpu_percent"] != 12.5 {
+			t.Errorf("Expected worker resource usage, got %v", body["workers"])
+		}
+		if _, ok := body["not_found_cache"]; ok {
+			t.Errorf("Expected no metrics for the disabled 404 cache, got %v", body)
+		}
+	})
+}
+
+func TestAdminCachePurge(t *testing.T) {
+	ssrCache := cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+	notFoundCache := cache.NewCacheProvider(cache.NewNotFoundInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+	mux := http.NewServeMux()
+	SetupAdminRoutes(mux, &Config{AdminToken: "secret", SSRCache: ssrCache, NotFoundCache: notFoundCache})
+
+	purge := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("POST", AdminPathPrefix+"cache/purge", strings.NewReader(body))
+		req.Header.Set("Authorization", "Bearer secret")
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, req)
+		return w
+	}
+
+	tagged := cache.NewCacheEntry([]byte("product"))
+	tagged.Tags = []string{"product-42"}
+	ssrCache.SetEntry("/products/42", tagged, 0)
+	ssrCache.Set("/blog/a", []byte("a"))
+	ssrCache.Set("/blog/b", []byte("b"))
+	ssrCache.Set("/about", []byte("about"))
+	notFoundCache.Set("/blog/missing", []byte("missing"))
+
+	w := purge(`{"keys": ["/about"], "prefixes": ["/blog/"], "tags": ["product-42"]}`)
+	if w.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", w.Code, w.Body.String())
+	}
+	var body map[string]int
+	if err := json.NewDecoder(w.Body).Decode(&body); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if body["purged"] != 5 {
+		t.Errorf("Expected 5 purged entries, got %v", body)
+	}
+	for _, key := range []string{"/products/42", "/blog/a", "/about"} {
+		if _, found := ssrCache.Get(key); found {
+			t.Errorf("Expected %s to be purged", key)
+		}
+	}
+	if _, found := notFoundCache.Get("/blog/missing"); found {
+		t.Error("Expected 404 cache to be purged")
+	}
+
+	for _, body := range []string{"", "{}", "not json"} {
+		if w := purge(body); w.Code != http.StatusBadRequest {
+			t.Errorf("Body %q: expected status 400, got %d", body, w.Code)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package server
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cacheControl holds the directives of a Cache-Control header, lowercased, with their values
+type cacheControl map[string]string
+
+func parseCacheControl(value string) cacheControl {
+	cc := make(cacheControl)
+	for _, directive := range strings.Split(value, ",") {
+		name, arg, _ := strings.Cut(strings.TrimSpace(directive), "=")
+		if name == "" {
+			continue
+		}
+		cc[strings.ToLower(name)] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return cc
+}
+
+// has reports whether the directive is present
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+// duration returns the value of a delta-seconds directive like max-age
+func (cc cacheControl) duration(name string) (time.Duration, bool) {
+	arg, ok := cc[name]
+	if !ok {
+		return 0, false
+	}
+	seconds, err := strconv.Atoi(arg)
+	if err != nil || seconds < 0 {
+		return 0, false
+	}
+	return time.Duration(seconds) * time.Second, true
+}
@@ -0,0 +1,195 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"slices"
+	"sort"
+	"strings"
+)
+
+// Device classes derived from the User-Agent
+const (
+	DeviceMobile  = "mobile"
+	DeviceTablet  = "tablet"
+	DeviceDesktop = "desktop"
+)
+
+// CacheKeyRules select the request dimensions a cached SSR page varies by.
+// The path is always part of the key.
+type CacheKeyRules struct {
+	Host              bool     // Key by host, for multi-tenant sites
+	QueryParams       []string // Query parameters to key by, "*" for all
+	IgnoreQueryParams []string // Query parameters never keyed by, a trailing "*" matches a prefix (e.g. "utm_*")
+	Headers           []string // Request headers to key by
+	Cookies           []string // Cookie names to key by
+	DeviceClass       bool     // Key by device class (mobile, tablet or desktop) derived from the User-Agent
+}
+
+// CacheKeyBuilder derives SSR cache keys and the matching Vary header from CacheKeyRules
+type CacheKeyBuilder struct {
+	rules     CacheKeyRules
+	allQuery  bool
+	query     map[string]bool
+	headers   []string
+	cookies   []string
+	varyNames []string
+}
+
+// NewCacheKeyBuilder creates a cache key builder for rules
+func NewCacheKeyBuilder(rules CacheKeyRules) *CacheKeyBuilder {
+	b := &CacheKeyBuilder{rules: rules, query: make(map[string]bool)}
+	for _, name := range rules.QueryParams {
+		if name == "*" {
+			b.allQuery = true
+		}
+		b.query[name] = true
+	}
+
+	seen := make(map[string]bool)
+	addVary := func(name string) {
+		if !seen[name] {
+			seen[name] = true
+			b.varyNames = append(b.varyNames, name)
+		}
+	}
+	for _, name := range rules.Headers {
+		name = http.CanonicalHeaderKey(strings.TrimSpace(name))
+		b.headers = append(b.headers, name)
+		addVary(name)
+	}
+	sort.Strings(b.headers)
+	if len(rules.Cookies) > 0 {
+		b.cookies = append(b.cookies, rules.Cookies...)
+		sort.Strings(b.cookies)
+		addVary("Cookie")
+	}
+	if rules.DeviceClass {
+		addVary("User-Agent")
+	}
+	return b
+}
+
+// Key returns the cache key for r. Without any rule it is the bare path.
+func (b *CacheKeyBuilder) Key(r *http.Request) string {
+	var key strings.Builder
+	if b.rules.Host {
+		key.WriteString(normalizeHost(r.Host))
+	}
+	key.WriteString(r.URL.Path)
+
+	if query := b.normalizedQuery(r.URL.Query()); query != "" {
+		key.WriteString("?")
+		key.WriteString(query)
+	}
+	for _, name := range b.headers {
+		if values := r.Header.Values(name); len(values) > 0 {
+			key.WriteString("|h:" + strings.ToLower(name) + "=" + strings.Join(values, ","))
+		}
+	}
+	for _, name := range b.cookies {
+		if cookie, err := r.Cookie(name); err == nil {
+			key.WriteString("|c:" + name + "=" + cookie.Value)
+		}
+	}
+	if b.rules.DeviceClass {
+		key.WriteString("|d:" + DeviceClass(r.UserAgent()))
+	}
+	return key.String()
+}
+
+// forwardedKey returns the key for r extended with the dimensions forwarded to
+// workers that the rules leave out, as whatever is forwarded can change the
+// page. Query parameters the rules ignore are assumed not to.
+func (b *CacheKeyBuilder) forwardedKey(r *http.Request, f *compiledForwarding) string {
+	var key strings.Builder
+	key.WriteString(b.Key(r))
+
+	if f.policy.ForwardQuery && !b.allQuery {
+		unkeyed := make(url.Values)
+		for name, values := range r.URL.Query() {
+			if !b.query[name] && !b.ignoresQueryParam(name) {
+				unkeyed[name] = values
+			}
+		}
+		if query := unkeyed.Encode(); query != "" {
+			key.WriteString("|q:" + query)
+		}
+	}
+	for _, name := range f.keyedHeaders(r) {
+		if !slices.Contains(b.headers, name) {
+			key.WriteString("|f:" + strings.ToLower(name) + "=" + strings.Join(r.Header.Values(name), ","))
+		}
+	}
+	if f.policy.ForwardedHeaders {
+		proto, host := f.forwardedOrigin(r)
+		key.WriteString("|f:x-forwarded-proto=" + proto + "|f:x-forwarded-host=" + normalizeHost(host))
+	}
+	return key.String()
+}
+
+// Vary returns the request headers responses vary by under these rules
+func (b *CacheKeyBuilder) Vary() []string {
+	return b.varyNames
+}
+
+// SetVary adds the rules' Vary headers to h, keeping those already present
+func (b *CacheKeyBuilder) SetVary(h http.Header) {
+	present := make(map[string]bool)
+	for _, value := range h.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			present[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+		}
+	}
+	for _, name := range b.varyNames {
+		if !present[name] {
+			h.Add("Vary", name)
+		}
+	}
+}
+
+// normalizedQuery keeps the keyed parameters, sorted by name so parameter
+// order doesn't split the cache
+func (b *CacheKeyBuilder) normalizedQuery(query url.Values) string {
+	kept := make(url.Values)
+	for name, values := range query {
+		if (b.allQuery || b.query[name]) && !b.ignoresQueryParam(name) {
+			kept[name] = values
+		}
+	}
+	return kept.Encode()
+}
+
+func (b *CacheKeyBuilder) ignoresQueryParam(name string) bool {
+	for _, pattern := range b.rules.IgnoreQueryParams {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok {
+			if strings.HasPrefix(name, prefix) {
+				return true
+			}
+		} else if name == pattern {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHost lowercases a host and strips default ports
+func normalizeHost(host string) string {
+	host = strings.ToLower(host)
+	host = strings.TrimSuffix(host, ":80")
+	return strings.TrimSuffix(host, ":443")
+}
+
+// DeviceClass classifies a User-Agent as mobile, tablet or desktop
+func DeviceClass(userAgent string) string {
+	ua := strings.ToLower(userAgent)
+	switch {
+	case strings.Contains(ua, "ipad") || strings.Contains(ua, "tablet") ||
+		(strings.Contains(ua, "android") && !strings.Contains(ua, "mobile")):
+		return DeviceTablet
+	case strings.Contains(ua, "mobi") || strings.Contains(ua, "iphone") || strings.Contains(ua, "android"):
+		return DeviceMobile
+	default:
+		return DeviceDesktop
+	}
+}
@@ -0,0 +1,156 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+)
+
+const (
+	iPhoneUA  = "Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 Mobile/15E148"
+	iPadUA    = "Mozilla/5.0 (iPad; CPU OS 17_0 like Mac OS X) AppleWebKit/605.1.15"
+	androidUA = "Mozilla/5.0 (Linux; Android 14; Pixel 8) AppleWebKit/537.36 Chrome/120.0 Mobile Safari/537.36"
+	desktopUA = "Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36"
+)
+
+func TestCacheKeyBuilder(t *testing.T) {
+	keys := NewCacheKeyBuilder(CacheKeyRules{
+		Host:              true,
+		QueryParams:       []string{"*"},
+		IgnoreQueryParams: []string{"utm_*", "fbclid"},
+		Headers:           []string{"accept-language"},
+		Cookies:           []string{"currency"},
+		DeviceClass:       true,
+	})
+
+	r := httptest.NewRequest("GET", "http://Example.com:80/shop?sort=price&utm_source=mail&fbclid=x&cat=shoes", nil)
+	r.Header.Set("Accept-Language", "fr")
+	r.Header.Set("User-Agent", iPhoneUA)
+	r.AddCookie(&http.Cookie{Name: "currency", Value: "EUR"})
+	r.AddCookie(&http.Cookie{Name: "session", Value: "abc"})
+
+	want := "example.com/shop?cat=shoes&sort=price|h:accept-language=fr|c:currency=EUR|d:mobile"
+	if got := keys.Key(r); got != want {
+		t.Errorf("Key() = %q, want %q", got, want)
+	}
+
+	if vary := strings.Join(keys.Vary(), ","); vary != "Accept-Language,Cookie,User-Agent" {
+		t.Errorf("Unexpected Vary %q", vary)
+	}
+
+	t.Run("selected query params", func(t *testing.T) {
+		keys := NewCacheKeyBuilder(CacheKeyRules{QueryParams: []string{"page"}})
+		r := httptest.NewRequest("GET", "/list?ref=home&page=2", nil)
+		if got := keys.Key(r); got != "/list?page=2" {
+			t.Errorf("Key() = %q, want /list?page=2", got)
+		}
+		if got := keys.Key(httptest.NewRequest("GET", "/list?ref=home", nil)); got != "/list" {
+			t.Errorf("Key() = %q, want /list", got)
+		}
+	})
+
+	t.Run("vary keeps existing values", func(t *testing.T) {
+		h := http.Header{}
+		h.Set("Vary", "Accept-Encoding, cookie")
+		keys.SetVary(h)
+		if got := strings.Join(h.Values("Vary"), ","); got != "Accept-Encoding, cookie,Accept-Language,User-Agent" {
+			t.Errorf("Unexpected Vary %q", got)
+		}
+	})
+}
+
+func TestDeviceClass(t *testing.T) {
+	tests := map[string]string{
+		iPhoneUA:  DeviceMobile,
+		androidUA: DeviceMobile,
+		iPadUA:    DeviceTablet,
+		desktopUA: DeviceDesktop,
+		"":        DeviceDesktop,
+	}
+	for ua, want := range tests {
+		if got := DeviceClass(ua); got != want {
+			t.Errorf("DeviceClass(%q) = %q, want %q", ua, got, want)
+		}
+	}
+}
+
+func TestSSRHandlerCacheKeys(t *testing.T) {
+	var renders int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		w.Write([]byte("lang=" + r.Header.Get("Accept-Language")))
+	}))
+	defer ts.Close()
+
+	memCache := cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute, MaxSize: 10})
+	keys := NewCacheKeyBuilder(CacheKeyRules{Headers: []string{"Accept-Language"}})
+	handler := SSRHandler(cache.NewCacheProvider(memCache, nil), nil, []string{"false"}, 60, ".",
+		newTestWorkerPool(ts.URL, true), nil, keys, nil)
+
+	get := func(lang string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept-Language", lang)
+		w := httptest.NewRecorder()
+		handler(w, req)
+		return w
+	}
+
+	for _, lang := range []string{"en", "fr", "en", "fr"} {
+		w := get(lang)
+		if w.Body.String() != "lang="+lang {
+			t.Errorf("Expected page for %s, got %q", lang, w.Body.String())
+		}
+		if w.Header().Get("Vary") != "Accept-Language" {
+			t.Errorf("Expected Vary: Accept-Language, got %q", w.Header().Get("Vary"))
+		}
+	}
+	if renders != 2 {
+		t.Errorf("Expected one render per language, got %d", renders)
+	}
+}
+
+func TestCacheKeyIncludesForwardedDimensions(t *testing.T) {
+	keys := NewCacheKeyBuilder(CacheKeyRules{
+		QueryParams:       []string{"lang"},
+		IgnoreQueryParams: []string{"utm_*"},
+		Cookies:           []string{"theme"},
+	})
+	f := compileForwarding(ForwardingPolicy{
+		ForwardQuery:   true,
+		AllowHeaders:   []string{"Cookie", "X-Tenant"},
+		UnkeyedHeaders: []string{"Cookie"},
+	})
+	key := func(target string, header http.Header) string {
+		req := httptest.NewRequest("GET", target, nil)
+		for name, values := range header {
+			req.Header[name] = values
+		}
+		return keys.forwardedKey(req, f)
+	}
+
+	if key("/list?page=2", nil) == key("/list?page=3", nil) {
+		t.Error("Expected forwarded query parameters to be keyed")
+	}
+	if key("/list?lang=fr&utm_source=mail", nil) != key("/list?lang=fr", nil) {
+		t.Error("Expected ignored query parameters not to be keyed")
+	}
+	if key("/", http.Header{"X-Tenant": {"a"}}) == key("/", http.Header{"X-Tenant": {"b"}}) {
+		t.Error("Expected forwarded headers to be keyed")
+	}
+	if key("/", http.Header{"Cookie": {"session=1"}}) != key("/", http.Header{"Cookie": {"session=2"}}) {
+		t.Error("Expected unkeyed headers not to be keyed")
+	}
+
+	f.policy.ForwardedHeaders = true
+	f.policy.TrustProxy = true
+	if key("/", nil) == key("/", http.Header{"X-Forwarded-Proto": {"https"}}) {
+		t.Error("Expected the forwarded scheme to be keyed")
+	}
+	if key("/", http.Header{"X-Forwarded-Host": {"a.com"}}) == key("/", http.Header{"X-Forwarded-Host": {"b.com"}}) {
+		t.Error("Expected the forwarded host to be keyed")
+	}
+}
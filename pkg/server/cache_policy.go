@@ -0,0 +1,119 @@
+package server
+
+import (
+	"net/http"
+	"strings"
+	"time"
+)
+
+// surrogateHeaders are worker response headers addressed to Blastra's cache, never sent to clients
+var surrogateHeaders = map[string]bool{
+	"Surrogate-Control": true,
+	"Surrogate-Key":     true,
+	"Cache-Tag":         true,
+}
+
+// cachePolicy is what a worker response allows the SSR cache to do with it
+type cachePolicy struct {
+	cacheable            bool
+	ttl                  time.Duration // 0 uses the cache's TTL
+	staleWhileRevalidate time.Duration // 0 uses the cache's default
+	staleIfError         time.Duration // 0 uses the cache's default
+}
+
+// workerCachePolicy reads the caching headers of a worker response.
+// Surrogate-Control is addressed to Blastra and wins over Cache-Control, in
+// which s-maxage wins over max-age, which wins over Expires. Responses that set
+// cookies, or that are private, no-store or no-cache, are never cached.
+func workerCachePolicy(resp *http.Response) cachePolicy {
+	if len(resp.Header.Values("Set-Cookie")) > 0 {
+		return cachePolicy{}
+	}
+
+	surrogate := parseCacheControl(resp.Header.Get("Surrogate-Control"))
+	cc := parseCacheControl(resp.Header.Get("Cache-Control"))
+	if surrogate.has("no-store") {
+		return cachePolicy{}
+	}
+	if cc.has("private") || cc.has("no-store") || cc.has("no-cache") {
+		// Surrogate-Control may still allow caching by Blastra itself
+		if _, ok := surrogate.duration("max-age"); !ok {
+			return cachePolicy{}
+		}
+	}
+
+	policy := cachePolicy{cacheable: true}
+	explicit := true
+	if d, ok := surrogate.duration("max-age"); ok {
+		policy.ttl = d
+	} else if d, ok := cc.duration("s-maxage"); ok {
+		policy.ttl = d
+	} else if d, ok := cc.duration("max-age"); ok {
+		policy.ttl = d
+	} else if expires := resp.Header.Get("Expires"); expires != "" {
+		policy.ttl = expiresTTL(expires, resp.Header.Get("Date"))
+	} else {
+		explicit = false
+	}
+	if explicit && policy.ttl <= 0 {
+		return cachePolicy{} // Already stale
+	}
+
+	for _, directives := range []cacheControl{cc, surrogate} {
+		if d, ok := directives.duration("stale-while-revalidate"); ok {
+			policy.staleWhileRevalidate = d
+		}
+		if d, ok := directives.duration("stale-if-error"); ok {
+			policy.staleIfError = d
+		}
+	}
+
+	// Temporary redirects are only cached when the worker says for how long
+	if isCacheableWithFreshness(resp.StatusCode) && !explicit {
+		return cachePolicy{}
+	}
+	return policy
+}
+
+// cacheTags returns the tags a worker response can be purged by, from the
+// comma separated Cache-Tag and the space separated Surrogate-Key headers
+func cacheTags(h http.Header) []string {
+	var tags []string
+	seen := make(map[string]bool)
+	add := func(tag string) {
+		if tag = strings.TrimSpace(tag); tag != "" && !seen[tag] {
+			seen[tag] = true
+			tags = append(tags, tag)
+		}
+	}
+	for _, value := range h.Values("Cache-Tag") {
+		for _, tag := range strings.Split(value, ",") {
+			add(tag)
+		}
+	}
+	for _, value := range h.Values("Surrogate-Key") {
+		for _, tag := range strings.Fields(value) {
+			add(tag)
+		}
+	}
+	return tags
+}
+
+// expiresTTL returns how long a response with an Expires header stays fresh,
+// relative to its Date header when present. Invalid dates mean already expired.
+func expiresTTL(expires, date string) time.Duration {
+	expiresAt, err := http.ParseTime(expires)
+	if err != nil {
+		return 0
+	}
+	now := time.Now()
+	if t, err := http.ParseTime(date); err == nil {
+		now = t
+	}
+	return expiresAt.Sub(now)
+}
+
+// isCacheableWithFreshness reports whether a status may be cached when the worker gives it an explicit lifetime
+func isCacheableWithFreshness(status int) bool {
+	return status == http.StatusFound || status == http.StatusTemporaryRedirect
+}
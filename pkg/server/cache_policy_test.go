@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+)
+
+func TestWorkerCachePolicy(t *testing.T) {
+	now := time.Now().UTC()
+	tests := []struct {
+		name      string
+		status    int
+		header    map[string]string
+		cacheable bool
+		ttl       time.Duration
+	}{
+		{"no headers", 200, nil, true, 0},
+		{"max-age", 200, map[string]string{"Cache-Control": "public, max-age=60"}, true, time.Minute},
+		{"s-maxage wins", 200, map[string]string{"Cache-Control": "max-age=10, s-maxage=120"}, true, 2 * time.Minute},
+		{"surrogate wins", 200, map[string]string{"Cache-Control": "s-maxage=120", "Surrogate-Control": "max-age=300"}, true, 5 * time.Minute},
+		{"surrogate overrides private", 200, map[string]string{"Cache-Control": "private", "Surrogate-Control": "max-age=30"}, true, 30 * time.Second},
+		{"surrogate no-store", 200, map[string]string{"Surrogate-Control": "no-store"}, false, 0},
+		{"private", 200, map[string]string{"Cache-Control": "private, max-age=60"}, false, 0},
+		{"no-store", 200, map[string]string{"Cache-Control": "no-store"}, false, 0},
+		{"no-cache", 200, map[string]string{"Cache-Control": "no-cache"}, false, 0},
+		{"max-age zero", 200, map[string]string{"Cache-Control": "max-age=0"}, false, 0},
+		{"set-cookie", 200, map[string]string{"Set-Cookie": "session=abc", "Cache-Control": "max-age=60"}, false, 0},
+		{"expires", 200, map[string]string{
+			"Date":    now.Format(http.TimeFormat),
+			"Expires": now.Add(time.Hour).Format(http.TimeFormat),
+		}, true, time.Hour},
+		{"expired", 200, map[string]string{"Expires": "0"}, false, 0},
+		{"temporary redirect", 302, nil, false, 0},
+		{"temporary redirect with max-age", 307, map[string]string{"Cache-Control": "max-age=60"}, true, time.Minute},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := &http.Response{StatusCode: tt.status, Header: make(http.Header)}
+			for key, value := range tt.header {
+				resp.Header.Set(key, value)
+			}
+			policy := workerCachePolicy(resp)
+			if policy.cacheable != tt.cacheable || policy.ttl != tt.ttl {
+				t.Errorf("workerCachePolicy() = (%v, %v), want (%v, %v)", policy.cacheable, policy.ttl, tt.cacheable, tt.ttl)
+			}
+		})
+	}
+}
+
+func TestSSRHandlerHonoursWorkerCaching(t *testing.T) {
+	var renders int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		switch r.URL.Path {
+		case "/account":
+			w.Header().Set("Cache-Control", "private, no-store")
+		case "/login":
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "abc"})
+		case "/products/42":
+			w.Header().Set("Cache-Tag", "product-42")
+			w.Header().Set("Surrogate-Key", "products")
+		case "/news":
+			w.Header().Set("Cache-Control", "max-age=5")
+			w.Header().Set("Surrogate-Control", "max-age=600")
+		}
+		w.Write([]byte("page"))
+	}))
+	defer ts.Close()
+
+	ssrCache := cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute, MaxSize: 10}), nil)
+	handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), nil, nil, nil)
+
+	for _, path := range []string{"/account", "/login", "/news", "/products/42"} {
+		renders = 0
+		for i := 0; i < 2; i++ {
+			w := httptest.NewRecorder()
+			handler(w, httptest.NewRequest("GET", path, nil))
+			for name := range surrogateHeaders {
+				if w.Header().Get(name) != "" {
+					t.Errorf("%s leaked to the client for %s", name, path)
+				}
+			}
+		}
+		_, cached := ssrCache.Get(path)
+		if path == "/products/42" {
+			if entry, _ := ssrCache.Get(path); strings.Join(entry.Tags, ",") != "product-42,products" {
+				t.Errorf("Expected cache tags to be stored, got %v", entry.Tags)
+			}
+			if n := ssrCache.PurgeTag("products"); n != 1 {
+				t.Errorf("Expected tagged page to be purged, got %d", n)
+			}
+		} else if path == "/news" {
+			if !cached || renders != 1 {
+				t.Errorf("Expected %s to be cached after one render, got %d renders", path, renders)
+			}
+			entry, _ := ssrCache.Get(path)
+			if ttl := entry.ExpiresAt.Sub(entry.LastUpdated); ttl != 10*time.Minute {
+				t.Errorf("Expected Surrogate-Control TTL of 10m, got %v", ttl)
+			}
+		} else if cached || renders != 2 {
+			t.Errorf("Expected %s not to be cached, got %d renders", path, renders)
+		}
+	}
+}
+
+func TestCacheTags(t *testing.T) {
+	h := http.Header{}
+	h.Add("Cache-Tag", "product-42, products")
+	h.Add("Surrogate-Key", "products  home")
+	if got := strings.Join(cacheTags(h), ","); got != "product-42,products,home" {
+		t.Errorf("cacheTags() = %q", got)
+	}
+	if tags := cacheTags(http.Header{}); tags != nil {
+		t.Errorf("Expected no tags, got %v", tags)
+	}
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// Response headers that are never stored with cached pages: they are specific
+// to one response or client, or regenerated when the page is served
+var uncachedHeaders = map[string]bool{
+	"Set-Cookie":        true,
+	"Date":              true,
+	"Age":               true,
+	"Etag":              true,
+	"Last-Modified":     true,
+	"Content-Length":    true,
+	"Content-Encoding":  true,
+	"Connection":        true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Trailer":           true,
+	"Transfer-Encoding": true,
+	"Upgrade":           true,
+}
+
+// cachedHeaders returns the response headers worth storing with a cached page
+func cachedHeaders(h http.Header) http.Header {
+	kept := make(http.Header)
+	for key, values := range h {
+		if key := http.CanonicalHeaderKey(key); !uncachedHeaders[key] && !surrogateHeaders[key] {
+			kept[key] = slices.Clone(values)
+		}
+	}
+	if len(kept) == 0 {
+		return nil
+	}
+	return kept
+}
+
+// replayCachedHeaders sets the stored headers of a cached page on dst. Vary is
+// merged with the values already set by the handler.
+func replayCachedHeaders(dst http.Header, stored http.Header) {
+	for key, values := range stored {
+		if key != "Vary" {
+			dst[key] = slices.Clone(values)
+			continue
+		}
+		present := make(map[string]bool)
+		for _, value := range dst.Values("Vary") {
+			for _, name := range strings.Split(value, ",") {
+				present[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+			}
+		}
+		for _, value := range values {
+			for _, name := range strings.Split(value, ",") {
+				if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); name != "" && !present[name] {
+					present[name] = true
+					dst.Add("Vary", name)
+				}
+			}
+		}
+	}
+}
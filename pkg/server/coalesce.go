@@ -0,0 +1,215 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// RenderCoalescer runs a single render per cache key at a time. Requests that
+// miss the cache while a render for their key is in flight wait for it and are
+// answered with its response instead of starting their own render. Responses
+// the cache policy doesn't allow to be cached, like private pages or pages
+// setting cookies, are never shared: waiting requests render their own.
+type RenderCoalescer struct {
+	timeout time.Duration
+
+	mu    sync.Mutex
+	calls map[string]*renderCall
+
+	renders   atomic.Int64 // Renders started, each possibly shared
+	coalesced atomic.Int64 // Requests answered with another request's render
+	timeouts  atomic.Int64 // Requests that gave up waiting and rendered themselves
+	failures  atomic.Int64 // Requests whose shared render could not be replayed
+	private   atomic.Int64 // Requests that rendered themselves as the render was not shareable
+}
+
+type renderCall struct {
+	done    chan struct{}
+	private chan struct{}     // Closed as soon as the render turns out not to be shareable
+	resp    *capturedResponse // nil when the render can't be shared
+}
+
+type capturedResponse struct {
+	status int
+	header http.Header
+	body   []byte
+}
+
+// NewRenderCoalescer creates a coalescer whose followers wait at most timeout for the shared render
+func NewRenderCoalescer(timeout time.Duration) *RenderCoalescer {
+	return &RenderCoalescer{
+		timeout: timeout,
+		calls:   make(map[string]*renderCall),
+	}
+}
+
+// Do renders the response for key with render, unless a render for key is
+// already in flight, in which case its response is replayed to w
+func (c *RenderCoalescer) Do(w http.ResponseWriter, r *http.Request, key string, render func(http.ResponseWriter)) {
+	c.mu.Lock()
+	if call, ok := c.calls[key]; ok {
+		c.mu.Unlock()
+		c.wait(w, r, key, call, render)
+		return
+	}
+	call := &renderCall{done: make(chan struct{}), private: make(chan struct{})}
+	c.calls[key] = call
+	c.mu.Unlock()
+
+	c.renders.Add(1)
+	cw := newCaptureWriter(w)
+	// Followers don't wait for a render they can't be answered with, and
+	// later requests for key no longer join it
+	cw.onPrivate = func() {
+		c.release(key, call)
+		close(call.private)
+	}
+	defer func() {
+		// Also runs when render panics, so followers are never left hanging
+		c.release(key, call)
+		call.resp = cw.result()
+		close(call.done)
+	}()
+	render(cw)
+}
+
+// release stops requests for key from joining call
+func (c *RenderCoalescer) release(key string, call *renderCall) {
+	c.mu.Lock()
+	if c.calls[key] == call {
+		delete(c.calls, key)
+	}
+	c.mu.Unlock()
+}
+
+func (c *RenderCoalescer) wait(w http.ResponseWriter, r *http.Request, key string, call *renderCall, render func(http.ResponseWriter)) {
+	timer := time.NewTimer(c.timeout)
+	defer timer.Stop()
+
+	select {
+	case <-call.private:
+		c.private.Add(1)
+		log.Debugf("Render of %s can't be shared, rendering again", key)
+	case <-call.done:
+		if resp := call.resp; resp != nil {
+			c.coalesced.Add(1)
+			resp.replay(w)
+			return
+		}
+		c.failures.Add(1)
+		log.Debugf("Shared render for %s failed, rendering again", key)
+	case <-timer.C:
+		c.timeouts.Add(1)
+		log.Debugf("Timed out waiting %v for shared render of %s", c.timeout, key)
+	case <-r.Context().Done():
+		return // The client went away
+	}
+	render(w)
+}
+
+// GetMetrics returns coalescing counters
+func (c *RenderCoalescer) GetMetrics() map[string]interface{} {
+	c.mu.Lock()
+	inFlight := len(c.calls)
+	c.mu.Unlock()
+	return map[string]interface{}{
+		"renders":   c.renders.Load(),
+		"coalesced": c.coalesced.Load(),
+		"timeouts":  c.timeouts.Load(),
+		"failures":  c.failures.Load(),
+		"private":   c.private.Load(),
+		"in_flight": inFlight,
+	}
+}
+
+func (resp *capturedResponse) replay(w http.ResponseWriter) {
+	for key, values := range resp.header {
+		w.Header()[key] = slices.Clone(values)
+	}
+	w.WriteHeader(resp.status)
+	w.Write(resp.body)
+}
+
+// captureWriter passes a response through to the client while recording it.
+// Only headers set by the render are recorded, not those set by middleware
+// for this particular client (like Content-Encoding), and never Set-Cookie,
+// which belongs to the client that triggered the render.
+//
+// A response is only shared when its headers, as sent to the client, allow it
+// to be cached. Pages Blastra may only cache through Surrogate-Control are
+// judged by their Cache-Control, as Surrogate-Control isn't sent to clients.
+type captureWriter struct {
+	http.ResponseWriter
+	initial   http.Header
+	status    int
+	header    http.Header
+	body      bytes.Buffer
+	failed    bool
+	private   bool
+	onPrivate func() // Called once the response turns out not to be shareable
+}
+
+func newCaptureWriter(w http.ResponseWriter) *captureWriter {
+	return &captureWriter{ResponseWriter: w, initial: w.Header().Clone()}
+}
+
+func (cw *captureWriter) WriteHeader(status int) {
+	if cw.status == 0 {
+		cw.status = status
+		cw.private = !workerCachePolicy(&http.Response{StatusCode: status, Header: cw.Header()}).cacheable
+		if cw.private && cw.onPrivate != nil {
+			cw.onPrivate()
+		}
+		cw.header = make(http.Header)
+		for key, values := range cw.Header() {
+			if key != "Set-Cookie" && !slices.Equal(cw.initial[key], values) {
+				cw.header[key] = slices.Clone(values)
+			}
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *captureWriter) Write(b []byte) (int, error) {
+	if cw.status == 0 {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if !cw.private {
+		cw.body.Write(b)
+	}
+	n, err := cw.ResponseWriter.Write(b)
+	if err != nil {
+		cw.failed = true
+	}
+	return n, err
+}
+
+func (cw *captureWriter) renderFailed() {
+	cw.failed = true // Don't share the truncated response with coalesced requests
+}
+
+// Flush keeps streamed renders flowing to the client
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// result returns the recorded response, or nil if it is incomplete or not shareable
+func (cw *captureWriter) result() *capturedResponse {
+	if cw.status == 0 || cw.failed || cw.private {
+		return nil
+	}
+	return &capturedResponse{status: cw.status, header: cw.header, body: cw.body.Bytes()}
+}
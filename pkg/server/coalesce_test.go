@@ -0,0 +1,172 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRenderCoalescer(t *testing.T) {
+	t.Run("concurrent misses share one render", func(t *testing.T) {
+		var renders atomic.Int32
+		release := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			renders.Add(1)
+			<-release
+			w.Header().Set("X-Rendered", "1")
+			w.Write([]byte("page"))
+		}))
+		defer ts.Close()
+
+		coalescer := NewRenderCoalescer(5 * time.Second)
+		handler := SSRHandler(nil, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), nil, nil, coalescer)
+
+		const clients = 5
+		recorders := make([]*httptest.ResponseRecorder, clients)
+		var wg sync.WaitGroup
+		for i := range recorders {
+			recorders[i] = httptest.NewRecorder()
+			wg.Add(1)
+			go func(w *httptest.ResponseRecorder) {
+				defer wg.Done()
+				handler(w, httptest.NewRequest("GET", "/popular", nil))
+			}(recorders[i])
+		}
+
+		// Let every request join the in-flight render before it completes
+		deadline := time.Now().Add(2 * time.Second)
+		for coalescer.GetMetrics()["renders"].(int64) < 1 && time.Now().Before(deadline) {
+			time.Sleep(5 * time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if renders.Load() != 1 {
+			t.Errorf("Expected a single render, got %d", renders.Load())
+		}
+		for _, w := range recorders {
+			if w.Code != http.StatusOK || w.Body.String() != "page" || w.Header().Get("X-Rendered") != "1" {
+				t.Errorf("Expected shared page, got %d %q", w.Code, w.Body.String())
+			}
+		}
+		if got := coalescer.GetMetrics()["coalesced"].(int64); got != clients-1 {
+			t.Errorf("Expected %d coalesced requests, got %d", clients-1, got)
+		}
+	})
+
+	t.Run("private renders are not shared", func(t *testing.T) {
+		var renders atomic.Int32
+		release := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			renders.Add(1)
+			w.Header().Set("Cache-Control", "private, no-store")
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-release
+			w.Write([]byte("hello " + r.Header.Get("Cookie")))
+		}))
+		defer ts.Close()
+
+		coalescer := NewRenderCoalescer(5 * time.Second)
+		handler := SSRHandler(nil, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), nil, nil, coalescer)
+
+		alice, bob := httptest.NewRecorder(), httptest.NewRecorder()
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest("GET", "/account", nil)
+			r.Header.Set("Cookie", "user=alice")
+			handler(alice, r)
+		}()
+		for coalescer.GetMetrics()["renders"].(int64) < 1 {
+			time.Sleep(time.Millisecond)
+		}
+		go func() {
+			defer wg.Done()
+			r := httptest.NewRequest("GET", "/account", nil)
+			r.Header.Set("Cookie", "user=bob")
+			handler(bob, r)
+		}()
+		for renders.Load() < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		close(release)
+		wg.Wait()
+
+		if alice.Body.String() != "hello user=alice" {
+			t.Errorf("Expected alice's page, got %q", alice.Body.String())
+		}
+		if bob.Body.String() != "hello user=bob" {
+			t.Errorf("Expected bob's own page, got %q", bob.Body.String())
+		}
+		if got := coalescer.GetMetrics()["coalesced"].(int64); got != 0 {
+			t.Errorf("Expected no coalesced request, got %d", got)
+		}
+	})
+
+	t.Run("renders setting cookies are not shared", func(t *testing.T) {
+		coalescer := NewRenderCoalescer(5 * time.Second)
+		release := make(chan struct{})
+		go coalescer.Do(httptest.NewRecorder(), httptest.NewRequest("GET", "/login", nil), "/login", func(w http.ResponseWriter) {
+			w.Header().Set("Set-Cookie", "session=leader")
+			w.WriteHeader(http.StatusOK)
+			<-release
+		})
+		defer close(release)
+		for coalescer.GetMetrics()["renders"].(int64) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		w := httptest.NewRecorder()
+		coalescer.Do(w, httptest.NewRequest("GET", "/login", nil), "/login", func(w http.ResponseWriter) {
+			w.Write([]byte("own"))
+		})
+		if w.Body.String() != "own" || w.Header().Get("Set-Cookie") != "" {
+			t.Errorf("Expected the request's own render, got %q with cookie %q", w.Body.String(), w.Header().Get("Set-Cookie"))
+		}
+	})
+
+	t.Run("waiting times out", func(t *testing.T) {
+		coalescer := NewRenderCoalescer(20 * time.Millisecond)
+		release := make(chan struct{})
+		go coalescer.Do(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil), "/slow", func(w http.ResponseWriter) {
+			<-release
+		})
+		defer close(release)
+		for coalescer.GetMetrics()["in_flight"].(int) == 0 {
+			time.Sleep(time.Millisecond)
+		}
+
+		w := httptest.NewRecorder()
+		coalescer.Do(w, httptest.NewRequest("GET", "/slow", nil), "/slow", func(w http.ResponseWriter) {
+			w.Write([]byte("own render"))
+		})
+		if w.Body.String() != "own render" {
+			t.Errorf("Expected follower to render itself, got %q", w.Body.String())
+		}
+		if coalescer.GetMetrics()["timeouts"].(int64) != 1 {
+			t.Error("Expected timeout to be counted")
+		}
+	})
+
+	t.Run("middleware headers are not shared", func(t *testing.T) {
+		w := httptest.NewRecorder()
+		w.Header().Set("Content-Encoding", "gzip")
+		cw := newCaptureWriter(w)
+		cw.Header().Set("Content-Type", "text/html")
+		cw.Write([]byte("page"))
+
+		resp := cw.result()
+		if resp == nil {
+			t.Fatal("Expected complete response")
+		}
+		if resp.header.Get("Content-Encoding") != "" || resp.header.Get("Content-Type") != "text/html" {
+			t.Errorf("Unexpected captured headers %v", resp.header)
+		}
+	})
+}
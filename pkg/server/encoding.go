@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+)
+
+// encodingPreference breaks ties between content codings the client accepts equally
+var encodingPreference = []string{cache.EncodingBrotli, cache.EncodingGzip}
+
+// negotiateEncoding picks the content coding of the available variants that
+// the Accept-Encoding header prefers, or "" if the client needs identity
+func negotiateEncoding(acceptEncoding string, available map[string][]byte) string {
+	if acceptEncoding == "" || len(available) == 0 {
+		return ""
+	}
+
+	accepted := make(map[string]float64)
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if parsed, err := strconv.ParseFloat(value, 64); err == nil {
+				q = parsed
+			}
+		}
+		accepted[strings.ToLower(strings.TrimSpace(coding))] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range encodingPreference {
+		if _, ok := available[coding]; !ok {
+			continue
+		}
+		q, ok := accepted[coding]
+		if !ok {
+			q, ok = accepted["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// encodedETag derives the ETag of a compressed variant from the ETag of the
+// raw body, as each representation needs its own
+func encodedETag(etag, encoding string) string {
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// addVary adds name to the Vary header unless it is already listed
+func addVary(h http.Header, name string) {
+	for _, value := range h.Values("Vary") {
+		for _, listed := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(listed), name) {
+				return
+			}
+		}
+	}
+	h.Add("Vary", name)
+}
@@ -0,0 +1,84 @@
+package server
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	both := map[string][]byte{cache.EncodingGzip: nil, cache.EncodingBrotli: nil}
+	gzipOnly := map[string][]byte{cache.EncodingGzip: nil}
+
+	tests := []struct {
+		accept    string
+		available map[string][]byte
+		want      string
+	}{
+		{"", both, ""},
+		{"gzip, deflate, br", both, "br"},
+		{"gzip", both, "gzip"},
+		{"br", gzipOnly, ""},
+		{"br;q=0.5, gzip", both, "gzip"},
+		{"br;q=0, gzip;q=0", both, ""},
+		{"*", both, "br"},
+		{"identity", both, ""},
+		{"GZIP", gzipOnly, "gzip"},
+		{"gzip", nil, ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.accept, tt.available); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+}
+
+func TestServeCompressedCachedSSR(t *testing.T) {
+	content := []byte(strings.Repeat("<p>bonjour</p>", 100))
+	entry, err := cache.Compress(cache.NewCacheEntry(content), []string{cache.EncodingGzip, cache.EncodingBrotli})
+	if err != nil {
+		t.Fatalf("Failed to compress entry: %v", err)
+	}
+	provider := cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+	provider.SetEntry("/page", entry, 0)
+	handler := SSRHandler(provider, nil, []string{"false"}, 60, ".", nil, nil, nil, nil)
+
+	etags := make(map[string]bool)
+	for _, tt := range []struct{ accept, encoding string }{{"br, gzip", "br"}, {"gzip", "gzip"}, {"", ""}} {
+		req := httptest.NewRequest("GET", "/page", nil)
+		req.Header.Set("Accept-Encoding", tt.accept)
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		if w.Header().Get("Content-Encoding") != tt.encoding {
+			t.Errorf("Expected Content-Encoding %q for %q, got %q", tt.encoding, tt.accept, w.Header().Get("Content-Encoding"))
+		}
+		if w.Header().Get("Vary") != "Accept-Encoding" {
+			t.Errorf("Expected Vary: Accept-Encoding, got %q", w.Header().Get("Vary"))
+		}
+		want := content
+		if tt.encoding != "" {
+			want = entry.Encodings[tt.encoding]
+		}
+		if !bytes.Equal(w.Body.Bytes(), want) {
+			t.Errorf("Expected %q body for %q, got %d bytes", tt.encoding, tt.accept, w.Body.Len())
+		}
+		etags[w.Header().Get("ETag")] = true
+
+		// Each representation revalidates against its own ETag
+		req.Header.Set("If-None-Match", w.Header().Get("ETag"))
+		w = httptest.NewRecorder()
+		handler(w, req)
+		if w.Code != http.StatusNotModified {
+			t.Errorf("Expected 304 for %q, got %d", tt.accept, w.Code)
+		}
+	}
+	if len(etags) != 3 {
+		t.Errorf("Expected a distinct ETag per encoding, got %v", etags)
+	}
+}
@@ -0,0 +1,221 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+// DefaultForwardHeaders are the request headers passed to workers unless configured otherwise
+var DefaultForwardHeaders = []string{"Accept", "Accept-Language", "Cookie", "User-Agent"}
+
+// Hop-by-hop headers only apply to a single connection and are never forwarded
+var hopByHopHeaders = map[string]bool{
+	"Connection":          true,
+	"Keep-Alive":          true,
+	"Proxy-Authenticate":  true,
+	"Proxy-Authorization": true,
+	"Proxy-Connection":    true,
+	"Te":                  true,
+	"Trailer":             true,
+	"Transfer-Encoding":   true,
+	"Upgrade":             true,
+}
+
+// ForwardingPolicy controls which parts of the client request reach the SSR
+// workers. Whatever is forwarded can change the rendered page, so forwarded
+// dimensions are part of the cache key unless listed in UnkeyedHeaders.
+type ForwardingPolicy struct {
+	ForwardQuery     bool     // Append the query string to the worker URL
+	AllowHeaders     []string // Request headers passed to workers, "*" for all
+	DenyHeaders      []string // Request headers never passed, even when allowed
+	UnkeyedHeaders   []string // Forwarded headers left out of the cache key
+	ForwardedHeaders bool     // Send X-Forwarded-For, X-Forwarded-Proto and X-Forwarded-Host, keying by the latter two
+	TrustProxy       bool     // Extend incoming X-Forwarded-* headers instead of replacing them
+	ProxyMethods     []string // Methods besides GET and HEAD proxied as-is (with body), e.g. POST for form actions
+}
+
+// DefaultForwardingPolicy returns the policy used when none is configured.
+// X-Forwarded-* headers are opt-in, as they split the cache by host and scheme.
+func DefaultForwardingPolicy() ForwardingPolicy {
+	return ForwardingPolicy{
+		ForwardQuery:   true,
+		AllowHeaders:   DefaultForwardHeaders,
+		UnkeyedHeaders: DefaultForwardHeaders,
+	}
+}
+
+func headerSet(names []string) map[string]bool {
+	set := make(map[string]bool, len(names))
+	for _, name := range names {
+		set[http.CanonicalHeaderKey(strings.TrimSpace(name))] = true
+	}
+	return set
+}
+
+// compiledForwarding is a ForwardingPolicy prepared for per-request use
+type compiledForwarding struct {
+	policy   ForwardingPolicy
+	allowAll bool
+	allow    map[string]bool
+	deny     map[string]bool
+	unkeyed  map[string]bool
+	methods  map[string]bool
+}
+
+func compileForwarding(policy ForwardingPolicy) *compiledForwarding {
+	f := &compiledForwarding{
+		policy:  policy,
+		allow:   headerSet(policy.AllowHeaders),
+		deny:    headerSet(policy.DenyHeaders),
+		unkeyed: headerSet(policy.UnkeyedHeaders),
+		methods: make(map[string]bool),
+	}
+	f.allowAll = f.allow["*"]
+	for _, method := range policy.ProxyMethods {
+		f.methods[strings.ToUpper(strings.TrimSpace(method))] = true
+	}
+	return f
+}
+
+// forwardsHeader reports whether a request header is copied to workers.
+// X-Forwarded-* headers are rebuilt by applyForwardedHeaders instead, and
+// Accept-Encoding is left to the transport, which decompresses worker
+// responses so cached pages are stored uncompressed.
+func (f *compiledForwarding) forwardsHeader(name string) bool {
+	name = http.CanonicalHeaderKey(name)
+	if hopByHopHeaders[name] || f.deny[name] || strings.HasPrefix(name, "X-Forwarded-") || name == "Accept-Encoding" {
+		return false
+	}
+	return f.allowAll || f.allow[name]
+}
+
+// proxiesMethod reports whether the request is proxied with its own method and
+// body. Such requests have side effects and bypass the cache.
+func (f *compiledForwarding) proxiesMethod(r *http.Request) bool {
+	return r.Method != http.MethodGet && r.Method != http.MethodHead && f.methods[r.Method]
+}
+
+// workerPath returns the path and query requested from the worker
+func (f *compiledForwarding) workerPath(r *http.Request) string {
+	if f.policy.ForwardQuery && r.URL.RawQuery != "" {
+		return r.URL.Path + "?" + r.URL.RawQuery
+	}
+	return r.URL.Path
+}
+
+// cacheKey derives the cache key from the forwarded dimensions of the request.
+// Requests without query, keyed headers or X-Forwarded-* headers use the bare path.
+func (f *compiledForwarding) cacheKey(r *http.Request) string {
+	var b strings.Builder
+	b.WriteString(r.URL.Path)
+
+	if f.policy.ForwardQuery && r.URL.RawQuery != "" {
+		// Sorted so parameter order doesn't split the cache
+		b.WriteString("?")
+		b.WriteString(r.URL.Query().Encode())
+	}
+
+	for _, name := range f.keyedHeaders(r) {
+		b.WriteString("|")
+		b.WriteString(strings.ToLower(name))
+		b.WriteString("=")
+		b.WriteString(strings.Join(r.Header.Values(name), ","))
+	}
+	if f.policy.ForwardedHeaders {
+		proto, host := f.forwardedOrigin(r)
+		b.WriteString("|x-forwarded-proto=" + proto + "|x-forwarded-host=" + normalizeHost(host))
+	}
+	return b.String()
+}
+
+// keyedHeaders returns the sorted names of the headers of r that are forwarded and keyed
+func (f *compiledForwarding) keyedHeaders(r *http.Request) []string {
+	names := make([]string, 0, len(r.Header))
+	for name := range r.Header {
+		if f.forwardsHeader(name) && !f.unkeyed[name] {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// setVary adds the keyed headers to the Vary header of the response to r, so
+// shared caches in front don't mix up pages the cache key tells apart. With
+// "*" allowed, only the keyed headers present on r can be named.
+func (f *compiledForwarding) setVary(h http.Header, r *http.Request) {
+	if f.allowAll {
+		for _, name := range f.keyedHeaders(r) {
+			addVary(h, name)
+		}
+	} else {
+		for _, name := range f.policy.AllowHeaders {
+			if name = http.CanonicalHeaderKey(strings.TrimSpace(name)); f.forwardsHeader(name) && !f.unkeyed[name] {
+				addVary(h, name)
+			}
+		}
+	}
+	if f.policy.ForwardedHeaders && f.policy.TrustProxy {
+		addVary(h, "X-Forwarded-Proto")
+		addVary(h, "X-Forwarded-Host")
+	}
+}
+
+// applyHeaders copies the forwarded headers of the client request onto the worker request
+func (f *compiledForwarding) applyHeaders(req *http.Request, r *http.Request) {
+	for name, values := range r.Header {
+		if !f.forwardsHeader(name) {
+			continue
+		}
+		for _, value := range values {
+			req.Header.Add(name, value)
+		}
+	}
+	if f.proxiesMethod(r) {
+		if contentType := r.Header.Get("Content-Type"); contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+	}
+	if f.policy.ForwardedHeaders {
+		f.applyForwardedHeaders(req, r)
+	}
+}
+
+func (f *compiledForwarding) applyForwardedHeaders(req *http.Request, r *http.Request) {
+	clientIP, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		clientIP = r.RemoteAddr
+	}
+	forwardedFor := clientIP
+	if prior := r.Header.Get("X-Forwarded-For"); f.policy.TrustProxy && prior != "" {
+		forwardedFor = prior + ", " + clientIP
+	}
+	proto, host := f.forwardedOrigin(r)
+
+	req.Header.Set("X-Forwarded-For", forwardedFor)
+	req.Header.Set("X-Forwarded-Proto", proto)
+	req.Header.Set("X-Forwarded-Host", host)
+}
+
+// forwardedOrigin returns the scheme and host sent to workers as X-Forwarded-Proto
+// and X-Forwarded-Host. Workers may render absolute URLs from them, so they are
+// keyed like any other forwarded dimension. The client IP is not, as keying by
+// it would leave nothing to share.
+func (f *compiledForwarding) forwardedOrigin(r *http.Request) (proto, host string) {
+	proto = "http"
+	if r.TLS != nil {
+		proto = "https"
+	}
+	host = r.Host
+	if f.policy.TrustProxy {
+		if prior := r.Header.Get("X-Forwarded-Proto"); prior != "" {
+			proto = prior
+		}
+		if prior := r.Header.Get("X-Forwarded-Host"); prior != "" {
+			host = prior
+		}
+	}
+	return proto, host
+}
@@ -0,0 +1,194 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+)
+
+func TestForwardingCacheKey(t *testing.T) {
+	f := compileForwarding(ForwardingPolicy{
+		ForwardQuery:   true,
+		AllowHeaders:   []string{"Accept", "X-Tenant", "X-Forwarded-For"},
+		UnkeyedHeaders: []string{"Accept"},
+	})
+
+	tests := []struct {
+		name    string
+		target  string
+		headers map[string]string
+		want    string
+	}{
+		{"bare path", "/test", nil, "/test"},
+		{"sorted query", "/search?q=go&page=2", nil, "/search?page=2&q=go"},
+		{"unkeyed header", "/test", map[string]string{"Accept": "text/html"}, "/test"},
+		{"keyed header", "/test", map[string]string{"X-Tenant": "acme"}, "/test|x-tenant=acme"},
+		{"not forwarded", "/test", map[string]string{"X-Other": "1", "X-Forwarded-For": "10.0.0.1"}, "/test"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", tt.target, nil)
+			for k, v := range tt.headers {
+				r.Header.Set(k, v)
+			}
+			if got := f.cacheKey(r); got != tt.want {
+				t.Errorf("cacheKey() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+
+	noQuery := compileForwarding(ForwardingPolicy{})
+	if got := noQuery.cacheKey(httptest.NewRequest("GET", "/search?q=go", nil)); got != "/search" {
+		t.Errorf("Expected query to stay out of the key when not forwarded, got %q", got)
+	}
+
+	forwarded := compileForwarding(ForwardingPolicy{ForwardedHeaders: true})
+	r := httptest.NewRequest("GET", "http://Example.com:80/test", nil)
+	r.Header.Set("X-Forwarded-For", "10.0.0.1")
+	if got, want := forwarded.cacheKey(r), "/test|x-forwarded-proto=http|x-forwarded-host=example.com"; got != want {
+		t.Errorf("cacheKey() = %q, want %q", got, want)
+	}
+	if forwarded.cacheKey(r) == forwarded.cacheKey(httptest.NewRequest("GET", "http://other.com/test", nil)) {
+		t.Error("Expected the forwarded host to be keyed")
+	}
+}
+
+func TestForwardingVary(t *testing.T) {
+	var renders int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		w.Write([]byte("tenant " + r.Header.Get("X-Tenant")))
+	}))
+	defer ts.Close()
+
+	proxy := NewWorkerProxy(WorkerProxyConfig{RenderTimeout: 5 * time.Second, Forwarding: &ForwardingPolicy{
+		AllowHeaders:   []string{"Accept", "x-tenant"},
+		UnkeyedHeaders: []string{"Accept"},
+	}})
+	ssrCache := cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+	handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), proxy, nil, nil)
+
+	for _, tenant := range []string{"acme", "acme", ""} {
+		req := httptest.NewRequest("GET", "/page", nil)
+		if tenant != "" {
+			req.Header.Set("X-Tenant", tenant)
+		}
+		w := httptest.NewRecorder()
+		handler(w, req)
+		if vary := w.Header().Values("Vary"); !slices.Contains(vary, "X-Tenant") || slices.Contains(vary, "Accept") {
+			t.Errorf("Expected Vary on the keyed header only for tenant %q, got %v", tenant, vary)
+		}
+	}
+	if renders != 2 {
+		t.Errorf("Expected one render per tenant, got %d", renders)
+	}
+}
+
+func TestForwardingToWorker(t *testing.T) {
+	var got *http.Request
+	var gotBody string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r
+		body, _ := io.ReadAll(r.Body)
+		gotBody = string(body)
+		w.Write([]byte("rendered"))
+	}))
+	defer ts.Close()
+
+	newProxy := func(policy ForwardingPolicy) *WorkerProxy {
+		return NewWorkerProxy(WorkerProxyConfig{RenderTimeout: 5 * time.Second, Forwarding: &policy})
+	}
+
+	t.Run("query and headers", func(t *testing.T) {
+		proxy := newProxy(ForwardingPolicy{
+			ForwardQuery:     true,
+			AllowHeaders:     []string{"*"},
+			DenyHeaders:      []string{"Authorization"},
+			ForwardedHeaders: true,
+		})
+		req := httptest.NewRequest("GET", "http://example.com/search?q=go", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("X-Tenant", "acme")
+		req.Header.Set("Authorization", "Bearer secret")
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		req.Header.Set("Accept-Encoding", "br")
+
+		if !handleWorkerSSR(httptest.NewRecorder(), req, newTestWorkerPool(ts.URL, true), proxy, nil, nil, "/search") {
+			t.Fatal("Expected request to be handled")
+		}
+		if got.URL.RawQuery != "q=go" {
+			t.Errorf("Expected query to be forwarded, got %q", got.URL.RawQuery)
+		}
+		if got.Header.Get("X-Tenant") != "acme" {
+			t.Error("Expected allowed header to be forwarded")
+		}
+		if got.Header.Get("Authorization") != "" {
+			t.Error("Expected denied header to be dropped")
+		}
+		if got.Header.Get("Accept-Encoding") == "br" {
+			t.Error("Expected Accept-Encoding to be left to the transport")
+		}
+		// Untrusted X-Forwarded-For is replaced by the peer address
+		if xff := got.Header.Get("X-Forwarded-For"); xff != "192.0.2.1" {
+			t.Errorf("Expected X-Forwarded-For 192.0.2.1, got %q", xff)
+		}
+		if got.Header.Get("X-Forwarded-Host") != "example.com" || got.Header.Get("X-Forwarded-Proto") != "http" {
+			t.Errorf("Unexpected X-Forwarded-Host/Proto %q/%q", got.Header.Get("X-Forwarded-Host"), got.Header.Get("X-Forwarded-Proto"))
+		}
+	})
+
+	t.Run("trusted proxy chain", func(t *testing.T) {
+		proxy := newProxy(ForwardingPolicy{ForwardedHeaders: true, TrustProxy: true})
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "10.0.0.2:1234"
+		req.Header.Set("X-Forwarded-For", "203.0.113.9")
+		req.Header.Set("X-Forwarded-Proto", "https")
+
+		handleWorkerSSR(httptest.NewRecorder(), req, newTestWorkerPool(ts.URL, true), proxy, nil, nil, "/")
+		if xff := got.Header.Get("X-Forwarded-For"); xff != "203.0.113.9, 10.0.0.2" {
+			t.Errorf("Expected extended X-Forwarded-For, got %q", xff)
+		}
+		if got.Header.Get("X-Forwarded-Proto") != "https" {
+			t.Errorf("Expected trusted X-Forwarded-Proto, got %q", got.Header.Get("X-Forwarded-Proto"))
+		}
+	})
+
+	t.Run("proxied POST is not cached", func(t *testing.T) {
+		proxy := newProxy(ForwardingPolicy{ProxyMethods: []string{"POST"}})
+		ssrCache := cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute, MaxSize: 10}), nil)
+		handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), proxy, nil, nil)
+
+		req := httptest.NewRequest("POST", "/contact", strings.NewReader("name=ada"))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		w := httptest.NewRecorder()
+		handler(w, req)
+
+		if w.Body.String() != "rendered" {
+			t.Errorf("Expected worker response, got %q", w.Body.String())
+		}
+		if got.Method != "POST" || gotBody != "name=ada" {
+			t.Errorf("Expected POST with body, got %s %q", got.Method, gotBody)
+		}
+		if got.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			t.Error("Expected Content-Type to be forwarded with the body")
+		}
+		if _, found := ssrCache.Get("/contact"); found {
+			t.Error("Expected POST response not to be cached")
+		}
+	})
+
+	t.Run("other methods render as GET", func(t *testing.T) {
+		proxy := newProxy(ForwardingPolicy{})
+		req := httptest.NewRequest("PUT", "/contact", strings.NewReader("ignored"))
+		handleWorkerSSR(httptest.NewRecorder(), req, newTestWorkerPool(ts.URL, true), proxy, nil, nil, "/contact")
+		if got.Method != "GET" || gotBody != "" {
+			t.Errorf("Expected bodyless GET, got %s %q", got.Method, gotBody)
+		}
+	})
+}
@@ -154,4 +154,7 @@ func SetupRoutes(mux *http.ServeMux, config *RouteConfig, rateLimiter *rate.Limi
 	// Health check endpoints (no rate limiting)
 	mux.HandleFunc("/live", config.HealthChecker.LivenessProbeHandler)
 	mux.HandleFunc("/ready", config.HealthChecker.ReadinessProbeHandler)
+
+	// Admin endpoints (no rate limiting, token protected)
+	SetupAdminRoutes(mux, config.Config)
 }
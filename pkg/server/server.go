@@ -22,11 +22,16 @@ type Config struct {
 	StaticDir             string
 	SSRHandler            http.HandlerFunc
 	HealthChecker         *health.HealthChecker
-	PreloadStaticFileList *bool             // Whether to preload static file list for routing (default: true)
-	PreloadStaticContent  *bool             // Whether to preload static files into memory (default: true)
-	StaticMaxAge          int               // Cache duration for static files in seconds
-	ExcludePatterns       []string          // Patterns to exclude from preloading
-	CacheControl          map[string]string // Custom cache control headers for different file types
+	PreloadStaticFileList *bool                // Whether to preload static file list for routing (default: true)
+	PreloadStaticContent  *bool                // Whether to preload static files into memory (default: true)
+	StaticMaxAge          int                  // Cache duration for static files in seconds
+	ExcludePatterns       []string             // Patterns to exclude from preloading
+	CacheControl          map[string]string    // Custom cache control headers for different file types
+	WorkerPool            worker.IWorkerPool   // Worker pool managed through the admin endpoints
+	AdminToken            string               // Bearer token for admin endpoints (disabled when empty)
+	Coalescer             *RenderCoalescer     // Render coalescer whose metrics the admin endpoints expose
+	SSRCache              *cache.CacheProvider // SSR cache the admin endpoints purge
+	NotFoundCache         *cache.CacheProvider // 404 cache the admin endpoints purge
 }
 
 // Helper function to get PreloadStaticFileList with default value
@@ -97,71 +102,153 @@ func preloadStaticContent(staticDir string, excludePatterns []string) (map[strin
 	return contentCache, err
 }
 
-func SSRHandler(ssrCache *cache.CacheProvider, notFoundCache *cache.CacheProvider, ssrCommand []string, maxAge int, cwd string, wp worker.IWorkerPool) http.HandlerFunc {
+func SSRHandler(ssrCache *cache.CacheProvider, notFoundCache *cache.CacheProvider, ssrCommand []string, maxAge int, cwd string, wp worker.IWorkerPool, proxy *WorkerProxy, keys *CacheKeyBuilder, coalescer *RenderCoalescer) http.HandlerFunc {
+	if proxy == nil {
+		proxy = defaultWorkerProxy
+	}
 	return func(w http.ResponseWriter, r *http.Request) {
 		log.Debugf("Received SSR request: %s", r.URL.Path)
-		cacheKey := r.URL.Path
+
+		// Configured key rules refine the key derived from the forwarding policy
+		proxy.forwarding.setVary(w.Header(), r)
+		var cacheKey string
+		if keys != nil {
+			cacheKey = keys.forwardedKey(r, proxy.forwarding)
+			keys.SetVary(w.Header())
+		} else {
+			cacheKey = proxy.forwarding.cacheKey(r)
+		}
+
+		// Proxied methods are rendered fresh and never cached
+		ssrCache, notFoundCache := ssrCache, notFoundCache
+		if proxy.forwarding.proxiesMethod(r) {
+			ssrCache, notFoundCache = nil, nil
+		}
+
+		render := func(w http.ResponseWriter, r *http.Request) {
+			// Try worker-based SSR first
+			if handled := handleWorkerSSR(w, r, wp, proxy, ssrCache, notFoundCache, cacheKey); handled {
+				return
+			}
+
+			// Fall back to direct command execution
+			handleDirectSSR(w, r, ssrCommand, cwd, ssrCache, notFoundCache, cacheKey, maxAge)
+		}
 
 		// Try to serve from cache first if caching is enabled
+		var stale *cache.CacheEntry
 		if ssrCache != nil {
 			if entry, found := ssrCache.Get(cacheKey); found {
-				// Add ETag support
-				w.Header().Set("ETag", entry.ETag)
-
-				// Check If-None-Match
-				if match := r.Header.Get("If-None-Match"); match != "" && match == entry.ETag {
-					w.WriteHeader(http.StatusNotModified)
+				now := time.Now()
+				switch {
+				case entry.Fresh(now):
+					serveCachedSSR(w, r, entry, http.StatusOK, maxAge)
 					return
+				case entry.StaleWhileRevalidating(now):
+					log.Debugf("Serving stale SSR response while revalidating: %s", r.URL.Path)
+					revalidator.refresh(cacheKey, r, render)
+					serveCachedSSR(w, r, entry, http.StatusOK, maxAge)
+					return
+				case entry.StaleOnError(now):
+					stale = &entry
 				}
-
-				// Check If-Modified-Since
-				ifModifiedSince := r.Header.Get("If-Modified-Since")
-				if ifModifiedSince != "" {
-					t, err := time.Parse(http.TimeFormat, ifModifiedSince)
-					if err == nil && !entry.LastUpdated.After(t) {
-						log.Debugf("Returning 304 Not Modified for: %s", r.URL.Path)
-						w.WriteHeader(http.StatusNotModified)
-						return
-					}
-				}
-
-				log.Debugf("Serving cached SSR response for: %s", r.URL.Path)
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
-				w.Header().Set("Last-Modified", entry.LastUpdated.UTC().Format(http.TimeFormat))
-				w.Write(entry.Content)
-				return
 			}
 		}
 
 		// Check NotFoundCache for 404 responses if caching is enabled
 		if notFoundCache != nil {
-			if entry, found := notFoundCache.Get(cacheKey); found {
-				// Check If-None-Match for 404 response
-				if match := r.Header.Get("If-None-Match"); match != "" && match == entry.ETag {
-					w.WriteHeader(http.StatusNotModified)
+			if entry, found := notFoundCache.Get(cacheKey); found && entry.Fresh(time.Now()) {
+				serveCachedSSR(w, r, entry, http.StatusNotFound, maxAge)
+				return
+			}
+		}
+
+		// A stale entry is kept as a fallback in case rendering fails
+		if stale != nil {
+			fresh := render
+			render = func(w http.ResponseWriter, r *http.Request) {
+				buffered := newBufferedResponse()
+				fresh(buffered, r)
+				if buffered.failed() {
+					log.Warnf("Render failed with status %d, serving stale SSR response for: %s", buffered.status, r.URL.Path)
+					serveCachedSSR(w, r, *stale, http.StatusOK, maxAge)
 					return
 				}
-
-				log.Debugf("Serving cached 404 response for: %s", r.URL.Path)
-				w.Header().Set("Content-Type", "text/html; charset=utf-8")
-				w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
-				w.Header().Set("ETag", entry.ETag)
-				w.Header().Set("Last-Modified", entry.LastUpdated.UTC().Format(http.TimeFormat))
-				w.WriteHeader(http.StatusNotFound)
-				w.Write(entry.Content)
-				return
+				buffered.writeTo(w)
 			}
 		}
 
-		// Try worker-based SSR first
-		if handled := handleWorkerSSR(w, r, wp, ssrCache, notFoundCache, cacheKey); handled {
+		// Concurrent misses for the same page share a single render
+		if coalescer != nil && !proxy.forwarding.proxiesMethod(r) {
+			coalescer.Do(w, r, cacheKey, func(w http.ResponseWriter) { render(w, r) })
+			return
+		}
+		render(w, r)
+	}
+}
+
+// serveCachedSSR writes a cached SSR page with the status and headers it was
+// rendered with, answering conditional requests with 304 Not Modified. Pages
+// stored compressed are served in the encoding the client prefers.
+func serveCachedSSR(w http.ResponseWriter, r *http.Request, entry cache.CacheEntry, defaultStatus int, maxAge int) {
+	status := entry.Status
+	if status == 0 {
+		status = defaultStatus
+	}
+
+	etag, body := entry.ETag, entry.Content
+	encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"), entry.Encodings)
+	if encoding != "" {
+		etag, body = encodedETag(entry.ETag, encoding), entry.Encodings[encoding]
+	}
+	if len(entry.Encodings) > 0 {
+		addVary(w.Header(), "Accept-Encoding")
+	}
+
+	// Add ETag support
+	w.Header().Set("ETag", etag)
+
+	// Check If-None-Match
+	if match := r.Header.Get("If-None-Match"); match != "" && match == etag {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	// Check If-Modified-Since
+	ifModifiedSince := r.Header.Get("If-Modified-Since")
+	if ifModifiedSince != "" && status == http.StatusOK {
+		t, err := time.Parse(http.TimeFormat, ifModifiedSince)
+		if err == nil && !entry.LastUpdated.After(t) {
+			log.Debugf("Returning 304 Not Modified for: %s", r.URL.Path)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+	}
+
+	// Clients that don't accept any stored encoding get the page decompressed
+	if encoding == "" && body == nil {
+		var err error
+		if body, err = entry.Body(); err != nil {
+			log.Errorf("Failed to decompress cached SSR response for %s: %v", r.URL.Path, err)
+			http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			return
 		}
+	}
 
-		// Fall back to direct command execution
-		handleDirectSSR(w, r, ssrCommand, cwd, ssrCache, notFoundCache, cacheKey, maxAge)
+	log.Debugf("Serving cached SSR response (%d) for: %s", status, r.URL.Path)
+	replayCachedHeaders(w.Header(), entry.Header)
+	if w.Header().Get("Content-Type") == "" {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	}
+	if w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", "public, max-age="+strconv.Itoa(maxAge))
+	}
+	if encoding != "" {
+		w.Header().Set("Content-Encoding", encoding)
 	}
+	w.Header().Set("Last-Modified", entry.LastUpdated.UTC().Format(http.TimeFormat))
+	w.WriteHeader(status)
+	w.Write(body)
 }
 
 func StaticHandler(staticDir string, maxAge int, preloadContent bool, excludePatterns []string) http.HandlerFunc {
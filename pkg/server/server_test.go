@@ -48,7 +48,7 @@ func TestSSRHandler(t *testing.T) {
 		provider.Set("/test", testContent)
 
 		// Create handler
-		handler := SSRHandler(provider, nil, mockSSRCommand(t, "test content", http.StatusOK), 60, ".", nil)
+		handler := SSRHandler(provider, nil, mockSSRCommand(t, "test content", http.StatusOK), 60, ".", nil, nil, nil, nil)
 
 		// Create test request
 		req := httptest.NewRequest("GET", "/test", nil)
@@ -87,7 +87,7 @@ func TestSSRHandler(t *testing.T) {
 		provider.Set("/notfound", testContent)
 
 		// Create handler
-		handler := SSRHandler(nil, provider, mockSSRCommand(t, "404 not found", http.StatusNotFound), 60, ".", nil)
+		handler := SSRHandler(nil, provider, mockSSRCommand(t, "404 not found", http.StatusNotFound), 60, ".", nil, nil, nil, nil)
 
 		// Create test request
 		req := httptest.NewRequest("GET", "/notfound", nil)
@@ -130,7 +130,7 @@ func TestSSRHandler(t *testing.T) {
 		etag := entry.ETag
 
 		// Create handler
-		handler := SSRHandler(provider, nil, mockSSRCommand(t, "test content", http.StatusOK), 60, ".", nil)
+		handler := SSRHandler(provider, nil, mockSSRCommand(t, "test content", http.StatusOK), 60, ".", nil, nil, nil, nil)
 
 		// Create test request with If-None-Match header
 		req := httptest.NewRequest("GET", "/test", nil)
@@ -151,7 +151,7 @@ func TestSSRHandler(t *testing.T) {
 
 	t.Run("worker fallback", func(t *testing.T) {
 		// Create handler with mock command
-		handler := SSRHandler(nil, nil, mockSSRCommand(t, "test content", http.StatusOK), 60, ".", nil)
+		handler := SSRHandler(nil, nil, mockSSRCommand(t, "test content", http.StatusOK), 60, ".", nil, nil, nil, nil)
 
 		// Create test request
 		req := httptest.NewRequest("GET", "/test", nil)
@@ -174,7 +174,7 @@ func TestSSRHandler(t *testing.T) {
 
 	t.Run("caching disabled", func(t *testing.T) {
 		// Create handler with no caches
-		handler := SSRHandler(nil, nil, mockSSRCommand(t, "test content", http.StatusOK), 60, ".", nil)
+		handler := SSRHandler(nil, nil, mockSSRCommand(t, "test content", http.StatusOK), 60, ".", nil, nil, nil, nil)
 
 		// Create test request
 		req := httptest.NewRequest("GET", "/test", nil)
@@ -195,3 +195,49 @@ func TestSSRHandler(t *testing.T) {
 		}
 	})
 }
+
+func TestSSRHandlerReplaysCachedResponses(t *testing.T) {
+	var renders int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		renders++
+		switch r.URL.Path {
+		case "/old":
+			w.Header().Set("Location", "/new")
+			w.WriteHeader(http.StatusMovedPermanently)
+		default:
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			w.Header().Set("Content-Language", "fr")
+			w.Header().Set("Content-Security-Policy", "default-src 'self'")
+			w.Header().Add("Link", "</app.css>; rel=preload; as=style")
+			w.Header().Set("Date", "Mon, 02 Jan 2006 15:04:05 GMT")
+			w.Write([]byte("bonjour"))
+		}
+	}))
+	defer ts.Close()
+
+	ssrCache := cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+	proxy := NewWorkerProxy(WorkerProxyConfig{RenderTimeout: 5 * time.Second, CacheStatuses: []int{http.StatusOK, http.StatusMovedPermanently}})
+	handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), proxy, nil, nil)
+
+	for _, path := range []string{"/page", "/page", "/old", "/old"} {
+		handler(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+	if renders != 2 {
+		t.Fatalf("Expected second requests to be served from cache, got %d renders", renders)
+	}
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/page", nil))
+	if w.Header().Get("Content-Language") != "fr" || w.Header().Get("Link") == "" || w.Header().Get("Content-Security-Policy") == "" {
+		t.Errorf("Expected worker headers on cache hit, got %v", w.Header())
+	}
+	if w.Header().Get("Date") == "Mon, 02 Jan 2006 15:04:05 GMT" {
+		t.Error("Expected Date not to be replayed from cache")
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest("GET", "/old", nil))
+	if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != "/new" {
+		t.Errorf("Expected cached redirect, got %d to %q", w.Code, w.Header().Get("Location"))
+	}
+}
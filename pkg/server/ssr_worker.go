@@ -1,64 +1,192 @@
 package server
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"io"
+	"math"
 	"net/http"
-	"time"
+	"strconv"
 
 	"github.com/devthefuture-org/blastra/pkg/cache"
 	"github.com/devthefuture-org/blastra/pkg/worker"
 	log "github.com/sirupsen/logrus"
 )
 
-func handleWorkerSSR(w http.ResponseWriter, r *http.Request, wp worker.IWorkerPool, ssrCache *cache.CacheProvider, notFoundCache *cache.CacheProvider, cacheKey string) bool {
+func handleWorkerSSR(w http.ResponseWriter, r *http.Request, wp worker.IWorkerPool, proxy *WorkerProxy, ssrCache *cache.CacheProvider, notFoundCache *cache.CacheProvider, cacheKey string) bool {
 	// Handle nil worker pool
 	if wp == nil {
 		return false
 	}
+	if proxy == nil {
+		proxy = defaultWorkerProxy
+	}
 
-	endpoint := wp.GetWorkerEndpoint()
-	if endpoint == "" {
+	lease, err := wp.Acquire(r.Context(), r.URL.Path)
+	if err != nil {
+		var busy *worker.BusyError
+		if errors.As(err, &busy) {
+			// Workers are saturated, shed load instead of piling up direct SSR processes
+			retryAfter := int(math.Ceil(busy.RetryAfter.Seconds()))
+			if retryAfter < 1 {
+				retryAfter = 1
+			}
+			w.Header().Set("Retry-After", strconv.Itoa(retryAfter))
+			http.Error(w, "Service Unavailable", http.StatusServiceUnavailable)
+			return true
+		}
 		return false
 	}
 
 	log.Debugf("Attempting SSR via worker pool for: %s", r.URL.Path)
-	ssrURL := endpoint + r.URL.Path
+	baseURL, _ := worker.ResolveEndpoint(lease.Endpoint())
+	ssrURL := baseURL + proxy.forwarding.workerPath(r)
+
+	// Proxied methods like form POSTs carry a body and side effects: they are
+	// neither cached nor retried through direct SSR
+	method, body := http.MethodGet, io.Reader(nil)
+	proxied := proxy.forwarding.proxiesMethod(r)
+	if proxied {
+		method, body = r.Method, r.Body
+		ssrCache, notFoundCache = nil, nil
+	}
+
+	// Bound the render by the route's timeout, and stop it when the client goes away
+	ctx := r.Context()
+	if timeout := proxy.renderTimeout(r.URL.Path); timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
 
-	req, err := http.NewRequest("GET", ssrURL, nil)
+	req, err := http.NewRequestWithContext(ctx, method, ssrURL, body)
 	if err != nil {
 		log.Errorf("Failed to create worker request: %v", err)
+		lease.Release(nil) // Not the worker's fault, just release it
 		return false
 	}
-
-	// Copy relevant headers from original request
-	for _, header := range []string{"Accept", "Accept-Language", "Cookie", "User-Agent"} {
-		if value := r.Header.Get(header); value != "" {
-			req.Header.Set(header, value)
-		}
+	if proxied {
+		req.ContentLength = r.ContentLength
 	}
+	proxy.forwarding.applyHeaders(req, r)
 
-	client := &http.Client{
-		Timeout: 5 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse // Don't follow redirects
-		},
+	resp, err := proxy.client.Do(req)
+	if err != nil && r.Context().Err() != nil {
+		clientGone(r, lease, err)
+		return true
 	}
-
-	resp, err := client.Do(req)
 	if err != nil {
 		log.Errorf("Worker request failed: %v", err)
+		lease.Release(err)
+		if proxied {
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return true
+		}
 		return false // Fall back to direct SSR
 	}
 	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	if proxy.config.Streaming {
+		streamWorkerResponse(w, r, resp, lease, proxy.responseCache(resp, ssrCache, notFoundCache), cacheKey, proxy.config.CacheEncodings)
+		return true
+	}
+
+	content, err := io.ReadAll(resp.Body)
+	if err != nil && r.Context().Err() != nil {
+		clientGone(r, lease, err)
+		return true
+	}
 	if err != nil {
 		log.Errorf("Failed to read worker response: %v", err)
+		lease.Release(err)
+		if proxied {
+			http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			return true
+		}
 		return false // Fall back to direct SSR
 	}
+	lease.Release(nil)
+
+	copyWorkerHeaders(w, resp)
+	w.WriteHeader(resp.StatusCode)
+	w.Write(content)
+
+	// The client gets the page before it is compressed for the cache
+	_ = http.NewResponseController(w).Flush()
+	cacheWorkerResponse(resp, content, proxy.responseCache(resp, ssrCache, notFoundCache), cacheKey, proxy.config.CacheEncodings)
+	return true
+}
+
+// renderFailer is implemented by writers that need to know a render broke off
+// after its headers were written, as its status then looks successful
+type renderFailer interface {
+	renderFailed()
+}
+
+// streamWorkerResponse relays the worker response to the client as it is
+// rendered, flushing every chunk. The body is tee'd into a buffer that is only
+// committed to target, the cache its status belongs in, once the stream completes.
+func streamWorkerResponse(w http.ResponseWriter, r *http.Request, resp *http.Response, lease *worker.Lease, target *cache.CacheProvider, cacheKey string, encodings []string) {
+	copyWorkerHeaders(w, resp)
+	w.WriteHeader(resp.StatusCode)
+
+	rc := http.NewResponseController(w)
+	cacheable := target != nil && workerCachePolicy(resp).cacheable
+
+	var body bytes.Buffer
+	chunk := make([]byte, 32*1024)
+	for {
+		n, err := resp.Body.Read(chunk)
+		if n > 0 {
+			if cacheable {
+				body.Write(chunk[:n])
+			}
+			if _, werr := w.Write(chunk[:n]); werr != nil {
+				// The client went away, which says nothing about the worker
+				log.Debugf("Client disconnected while streaming %s: %v", cacheKey, werr)
+				lease.Release(nil)
+				return
+			}
+			_ = rc.Flush() // Best effort, not every writer can flush
+		}
+		if err == io.EOF {
+			break
+		}
+		if err != nil && r.Context().Err() != nil {
+			clientGone(r, lease, err)
+			return
+		}
+		if err != nil {
+			// Headers are already sent, so the truncated response can't fall back to direct SSR
+			log.Errorf("Worker stream failed for %s: %v", cacheKey, err)
+			lease.Release(err)
+			if f, ok := w.(renderFailer); ok {
+				f.renderFailed()
+			}
+			return
+		}
+	}
+	lease.Release(nil)
+
+	if cacheable {
+		cacheWorkerResponse(resp, body.Bytes(), target, cacheKey, encodings)
+	}
+}
 
-	// Copy response headers
+// clientGone releases the lease of a render the client went away from. The
+// worker isn't at fault, and there is nobody left to render for through direct SSR.
+func clientGone(r *http.Request, lease *worker.Lease, err error) {
+	log.Debugf("Client disconnected while rendering %s: %v", r.URL.Path, err)
+	lease.Release(nil)
+}
+
+func copyWorkerHeaders(w http.ResponseWriter, resp *http.Response) {
+	// Copy response headers, except those meant for Blastra's cache only
 	for key, values := range resp.Header {
+		if surrogateHeaders[key] {
+			continue
+		}
 		for _, value := range values {
 			w.Header().Add(key, value)
 		}
@@ -68,15 +196,41 @@ func handleWorkerSSR(w http.ResponseWriter, r *http.Request, wp worker.IWorkerPo
 	if w.Header().Get("Content-Type") == "" {
 		w.Header().Set("Content-Type", "text/html; charset=utf-8")
 	}
+}
 
-	// Cache responses only if caching is enabled and response is cacheable
-	if resp.StatusCode == http.StatusNotFound && notFoundCache != nil {
-		notFoundCache.Set(cacheKey, body)
-	} else if resp.StatusCode == http.StatusOK && ssrCache != nil {
-		ssrCache.Set(cacheKey, body)
+// cacheWorkerResponse caches responses in target only if caching is enabled and the worker allows it,
+// compressed with encodings
+func cacheWorkerResponse(resp *http.Response, body []byte, target *cache.CacheProvider, cacheKey string, encodings []string) {
+	if target == nil {
+		return
+	}
+	policy := workerCachePolicy(resp)
+	if !policy.cacheable {
+		log.Debugf("Worker response for %s is not cacheable (Cache-Control: %q)", cacheKey, resp.Header.Get("Cache-Control"))
+		return
 	}
 
-	w.WriteHeader(resp.StatusCode)
-	w.Write(body)
-	return true
+	entry := cache.NewCacheEntry(body)
+	entry.Status = resp.StatusCode
+	entry.Header = cachedHeaders(resp.Header)
+	entry.Tags = cacheTags(resp.Header)
+	entry.StaleWhileRevalidate = policy.staleWhileRevalidate
+	entry.StaleIfError = policy.staleIfError
+	entry, err := cache.Compress(entry, encodings)
+	if err != nil {
+		log.Errorf("Failed to compress cached SSR response for %s: %v", cacheKey, err)
+	}
+	target.SetEntry(cacheKey, entry, policy.ttl)
+}
+
+// responseCache returns the cache a worker response belongs in, or nil if it isn't cached
+func (p *WorkerProxy) responseCache(resp *http.Response, ssrCache *cache.CacheProvider, notFoundCache *cache.CacheProvider) *cache.CacheProvider {
+	switch {
+	case p.negativeStatuses[resp.StatusCode]:
+		return notFoundCache
+	case p.cacheStatuses[resp.StatusCode] || isCacheableWithFreshness(resp.StatusCode):
+		return ssrCache
+	default:
+		return nil
+	}
 }
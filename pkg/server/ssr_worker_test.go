@@ -1,8 +1,14 @@
 package server
 
 import (
+	"context"
+	"io"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"path/filepath"
+	"slices"
+	"sync"
 	"testing"
 	"time"
 
@@ -14,6 +20,11 @@ import (
 type testWorkerPool struct {
 	endpoint string
 	enabled  bool
+	busy     error
+	statuses []worker.WorkerStatus
+
+	mu       sync.Mutex // Leases may be released concurrently
+	reported []error
 }
 
 func newTestWorkerPool(endpoint string, enabled bool) worker.IWorkerPool {
@@ -30,6 +41,29 @@ func (t *testWorkerPool) GetWorkerEndpoint() string {
 	return t.endpoint
 }
 
+func (t *testWorkerPool) Acquire(ctx context.Context, key string) (*worker.Lease, error) {
+	if t.busy != nil {
+		return nil, t.busy
+	}
+	endpoint := t.GetWorkerEndpoint()
+	if endpoint == "" {
+		return nil, worker.ErrNoWorkers
+	}
+	return worker.NewLease(endpoint, func(err error) {
+		t.mu.Lock()
+		t.reported = append(t.reported, err)
+		t.mu.Unlock()
+	}), nil
+}
+
+func (t *testWorkerPool) GetWorkerStatuses() []worker.WorkerStatus {
+	return t.statuses
+}
+
+func (t *testWorkerPool) RollingRestart(reason string) error {
+	return worker.ErrRestartUnsupported
+}
+
 func (t *testWorkerPool) Shutdown() {
 	// No-op for testing
 }
@@ -61,7 +95,7 @@ func TestHandleWorkerSSR(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		// Execute request
-		handled := handleWorkerSSR(w, req, wp, ssrCache, notFoundCache, "/test")
+		handled := handleWorkerSSR(w, req, wp, nil, ssrCache, notFoundCache, "/test")
 
 		// Verify response
 		if !handled {
@@ -114,7 +148,7 @@ func TestHandleWorkerSSR(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		// Execute request
-		handled := handleWorkerSSR(w, req, wp, ssrCache, notFoundCache, "/test")
+		handled := handleWorkerSSR(w, req, wp, nil, ssrCache, notFoundCache, "/test")
 
 		// Verify response
 		if !handled {
@@ -149,7 +183,7 @@ func TestHandleWorkerSSR(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		// Execute request
-		handled := handleWorkerSSR(w, req, wp, nil, nil, "/test")
+		handled := handleWorkerSSR(w, req, wp, nil, nil, nil, "/test")
 
 		// Verify request was not handled
 		if handled {
@@ -172,7 +206,7 @@ func TestHandleWorkerSSR(t *testing.T) {
 		w := httptest.NewRecorder()
 
 		// Execute request
-		handled := handleWorkerSSR(w, req, wp, nil, nil, "/test")
+		handled := handleWorkerSSR(w, req, wp, nil, nil, nil, "/test")
 
 		// Verify response
 		if !handled {
@@ -182,4 +216,200 @@ func TestHandleWorkerSSR(t *testing.T) {
 			t.Errorf("Expected status %d, got %d", http.StatusInternalServerError, w.Code)
 		}
 	})
+	t.Run("unix socket worker", func(t *testing.T) {
+		socketPath := filepath.Join(t.TempDir(), "worker.sock")
+		ln, err := net.Listen("unix", socketPath)
+		if err != nil {
+			t.Fatalf("Failed to listen on socket: %v", err)
+		}
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("socket response " + r.URL.Path))
+		}))
+		ts.Listener = ln
+		ts.Start()
+		defer ts.Close()
+
+		wp := newTestWorkerPool(worker.UnixEndpointPrefix+socketPath, true)
+		req := httptest.NewRequest("GET", "/page", nil)
+		w := httptest.NewRecorder()
+
+		if handled := handleWorkerSSR(w, req, wp, nil, nil, nil, "/page"); !handled {
+			t.Fatal("Expected request to be handled")
+		}
+		if w.Body.String() != "socket response /page" {
+			t.Errorf("Expected body from socket worker, got %s", w.Body.String())
+		}
+	})
+
+	t.Run("unreachable worker reports failure", func(t *testing.T) {
+		// Start and immediately close a server to get an unreachable endpoint
+		ts := httptest.NewServer(http.NotFoundHandler())
+		ts.Close()
+
+		wp := &testWorkerPool{endpoint: ts.URL, enabled: true}
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+
+		if handled := handleWorkerSSR(w, req, wp, nil, nil, nil, "/test"); handled {
+			t.Error("Expected request not to be handled")
+		}
+		if len(wp.reported) != 1 || wp.reported[0] == nil {
+			t.Errorf("Expected one failure to be reported, got %v", wp.reported)
+		}
+	})
+	t.Run("saturated workers return 503", func(t *testing.T) {
+		wp := &testWorkerPool{
+			endpoint: "http://localhost",
+			enabled:  true,
+			busy:     &worker.BusyError{Err: worker.ErrQueueFull, RetryAfter: 2 * time.Second},
+		}
+
+		req := httptest.NewRequest("GET", "/test", nil)
+		w := httptest.NewRecorder()
+
+		if handled := handleWorkerSSR(w, req, wp, nil, nil, nil, "/test"); !handled {
+			t.Error("Expected request to be handled")
+		}
+		if w.Code != http.StatusServiceUnavailable {
+			t.Errorf("Expected status %d, got %d", http.StatusServiceUnavailable, w.Code)
+		}
+		if w.Header().Get("Retry-After") != "2" {
+			t.Errorf("Expected Retry-After 2, got %q", w.Header().Get("Retry-After"))
+		}
+	})
+}
+
+func TestStreamWorkerResponse(t *testing.T) {
+	newCache := func() *cache.CacheProvider {
+		return cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+	}
+	streamingProxy := func() *WorkerProxy {
+		config := DefaultWorkerProxyConfig()
+		config.Streaming = true
+		return NewWorkerProxy(config)
+	}
+
+	t.Run("flushes chunks before the render completes", func(t *testing.T) {
+		release := make(chan struct{})
+		workerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>"))
+			w.(http.Flusher).Flush()
+			<-release
+			w.Write([]byte("</html>"))
+		}))
+		defer workerServer.Close()
+
+		ssrCache := newCache()
+		wp := &testWorkerPool{endpoint: workerServer.URL, enabled: true}
+		proxy := streamingProxy()
+		front := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			handleWorkerSSR(w, r, wp, proxy, ssrCache, nil, r.URL.Path)
+		}))
+		defer front.Close()
+
+		resp, err := http.Get(front.URL + "/page")
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		defer resp.Body.Close()
+
+		first := make([]byte, len("<html>"))
+		if _, err := io.ReadFull(resp.Body, first); err != nil || string(first) != "<html>" {
+			t.Fatalf("Expected first chunk before render completes, got %q (%v)", first, err)
+		}
+		if _, found := ssrCache.Get("/page"); found {
+			t.Error("Expected incomplete stream not to be cached")
+		}
+
+		close(release)
+		rest, _ := io.ReadAll(resp.Body)
+		if string(rest) != "</html>" {
+			t.Errorf("Expected rest of the stream, got %q", rest)
+		}
+
+		entry, found := ssrCache.Get("/page")
+		if !found || string(entry.Content) != "<html></html>" {
+			t.Errorf("Expected complete stream to be cached, got %v", entry)
+		}
+	})
+
+	t.Run("broken stream is not cached", func(t *testing.T) {
+		workerServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>"))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler) // Worker dies mid-render
+		}))
+		defer workerServer.Close()
+
+		ssrCache := newCache()
+		wp := &testWorkerPool{endpoint: workerServer.URL, enabled: true}
+		req := httptest.NewRequest("GET", "/page", nil)
+		w := httptest.NewRecorder()
+
+		if handled := handleWorkerSSR(w, req, wp, streamingProxy(), ssrCache, nil, "/page"); !handled {
+			t.Error("Expected streamed response to be handled")
+		}
+		if w.Body.String() != "<html>" {
+			t.Errorf("Expected partial body, got %q", w.Body.String())
+		}
+		if _, found := ssrCache.Get("/page"); found {
+			t.Error("Expected broken stream not to be cached")
+		}
+		if len(wp.reported) != 1 || wp.reported[0] == nil {
+			t.Errorf("Expected stream failure to be reported, got %v", wp.reported)
+		}
+	})
+}
+
+func TestWorkerResponseCacheStatuses(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/gone":
+			w.WriteHeader(http.StatusGone)
+		case "/moved":
+			w.Header().Set("Location", "/new")
+			w.WriteHeader(http.StatusMovedPermanently)
+		case "/missing":
+			w.WriteHeader(http.StatusNotFound)
+		}
+		w.Write([]byte(r.URL.Path))
+	}))
+	defer ts.Close()
+	wp := newTestWorkerPool(ts.URL, true)
+
+	tests := []struct {
+		name          string
+		config        WorkerProxyConfig
+		ssrCached     []string
+		negativeCache []string
+	}{
+		{"defaults", WorkerProxyConfig{}, nil, []string{"/missing"}},
+		{"configured", WorkerProxyConfig{
+			CacheStatuses:         []int{http.StatusOK, http.StatusMovedPermanently, http.StatusPermanentRedirect},
+			NegativeCacheStatuses: []int{http.StatusNotFound, http.StatusGone},
+		}, []string{"/moved"}, []string{"/missing", "/gone"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ssrCache := cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+			notFoundCache := cache.NewCacheProvider(cache.NewNotFoundInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+			proxy := NewWorkerProxy(tt.config)
+
+			for _, path := range []string{"/gone", "/moved", "/missing"} {
+				handleWorkerSSR(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil), wp, proxy, ssrCache, notFoundCache, path)
+				_, inSSR := ssrCache.Get(path)
+				entry, inNegative := notFoundCache.Get(path)
+				if inSSR != slices.Contains(tt.ssrCached, path) {
+					t.Errorf("%s: expected in SSR cache to be %v", path, !inSSR)
+				}
+				if inNegative != slices.Contains(tt.negativeCache, path) {
+					t.Errorf("%s: expected in 404 cache to be %v", path, !inNegative)
+				}
+				if path == "/gone" && inNegative && entry.Status != http.StatusGone {
+					t.Errorf("Expected 410 to be cached with its status, got %d", entry.Status)
+				}
+			}
+		})
+	}
 }
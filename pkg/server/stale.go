@@ -0,0 +1,86 @@
+package server
+
+import (
+	"bytes"
+	"context"
+	"net/http"
+	"sync"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// staleRevalidator refreshes stale cache entries in the background, running at
+// most one refresh per cache key at a time
+type staleRevalidator struct {
+	inFlight sync.Map
+}
+
+var revalidator = &staleRevalidator{}
+
+// refresh re-renders the page of r in the background. The render stores the
+// result in the cache; when it fails, the stale entry stays in place.
+func (v *staleRevalidator) refresh(key string, r *http.Request, render func(http.ResponseWriter, *http.Request)) {
+	if _, busy := v.inFlight.LoadOrStore(key, struct{}{}); busy {
+		return
+	}
+
+	// The refresh outlives the request that triggered it
+	req := r.Clone(context.WithoutCancel(r.Context()))
+	go func() {
+		defer v.inFlight.Delete(key)
+		buffered := newBufferedResponse()
+		render(buffered, req)
+		if buffered.failed() {
+			log.Warnf("Background revalidation of %s failed with status %d", key, buffered.status)
+		}
+	}()
+}
+
+// bufferedResponse holds a rendered response back from the client, so it can
+// be discarded in favour of a stale cache entry when rendering fails
+type bufferedResponse struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+	broken bool // The render broke off after writing its headers
+}
+
+func newBufferedResponse() *bufferedResponse {
+	return &bufferedResponse{header: make(http.Header)}
+}
+
+func (b *bufferedResponse) Header() http.Header {
+	return b.header
+}
+
+func (b *bufferedResponse) WriteHeader(status int) {
+	if b.status == 0 {
+		b.status = status
+	}
+}
+
+func (b *bufferedResponse) Write(p []byte) (int, error) {
+	if b.status == 0 {
+		b.status = http.StatusOK
+	}
+	return b.body.Write(p)
+}
+
+func (b *bufferedResponse) renderFailed() {
+	b.broken = true
+}
+
+// failed reports whether the render produced no response, a server error or a truncated body
+func (b *bufferedResponse) failed() bool {
+	return b.status == 0 || b.status >= http.StatusInternalServerError || b.broken
+}
+
+func (b *bufferedResponse) writeTo(w http.ResponseWriter) {
+	for key, values := range b.header {
+		for _, value := range values {
+			w.Header().Add(key, value)
+		}
+	}
+	w.WriteHeader(b.status)
+	w.Write(b.body.Bytes())
+}
@@ -0,0 +1,164 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+)
+
+// staleEntry returns an entry for content that expired a minute ago
+func staleEntry(content string, staleWhileRevalidate, staleIfError time.Duration) cache.CacheEntry {
+	entry := cache.NewCacheEntry([]byte(content))
+	entry.LastUpdated = time.Now().Add(-2 * time.Minute)
+	entry.ExpiresAt = time.Now().Add(-time.Minute)
+	entry.StaleWhileRevalidate = staleWhileRevalidate
+	entry.StaleIfError = staleIfError
+	return entry
+}
+
+func TestStaleServing(t *testing.T) {
+	newCache := func() *cache.CacheProvider {
+		return cache.NewCacheProvider(cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute}), nil)
+	}
+
+	t.Run("stale while revalidate", func(t *testing.T) {
+		var renders atomic.Int32
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			renders.Add(1)
+			w.Write([]byte("fresh"))
+		}))
+		defer ts.Close()
+
+		ssrCache := newCache()
+		ssrCache.SetEntry("/page", staleEntry("stale", time.Hour, 0), 0)
+		handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), nil, nil, nil)
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/page", nil))
+		if w.Body.String() != "stale" {
+			t.Errorf("Expected stale content to be served immediately, got %q", w.Body.String())
+		}
+
+		// The background refresh replaces the entry
+		deadline := time.Now().Add(2 * time.Second)
+		for time.Now().Before(deadline) {
+			if entry, _ := ssrCache.Get("/page"); string(entry.Content) == "fresh" {
+				break
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		entry, _ := ssrCache.Get("/page")
+		if string(entry.Content) != "fresh" || !entry.Fresh(time.Now()) {
+			t.Errorf("Expected entry to be refreshed, got %q", entry.Content)
+		}
+		if renders.Load() != 1 {
+			t.Errorf("Expected one background render, got %d", renders.Load())
+		}
+	})
+
+	t.Run("stale if error", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}))
+		defer ts.Close()
+
+		ssrCache := newCache()
+		ssrCache.SetEntry("/page", staleEntry("stale", 0, time.Hour), 0)
+		handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), nil, nil, nil)
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/page", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "stale" {
+			t.Errorf("Expected stale content when rendering fails, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("stale if error on a broken stream", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("<html>"))
+			w.(http.Flusher).Flush()
+			panic(http.ErrAbortHandler) // Worker dies mid-render
+		}))
+		defer ts.Close()
+
+		config := DefaultWorkerProxyConfig()
+		config.Streaming = true
+		ssrCache := newCache()
+		ssrCache.SetEntry("/page", staleEntry("stale", 0, time.Hour), 0)
+		handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), NewWorkerProxy(config), nil, nil)
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/page", nil))
+		if w.Code != http.StatusOK || w.Body.String() != "stale" {
+			t.Errorf("Expected stale content instead of a truncated page, got %d %q", w.Code, w.Body.String())
+		}
+	})
+
+	t.Run("stale if error renders are coalesced", func(t *testing.T) {
+		var renders atomic.Int32
+		release := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			renders.Add(1)
+			<-release
+			http.Error(w, "boom", http.StatusInternalServerError)
+		}))
+		defer ts.Close()
+
+		ssrCache := newCache()
+		ssrCache.SetEntry("/page", staleEntry("stale", 0, time.Hour), 0)
+		coalescer := NewRenderCoalescer(5 * time.Second)
+		handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), nil, nil, coalescer)
+
+		recorders := []*httptest.ResponseRecorder{httptest.NewRecorder(), httptest.NewRecorder(), httptest.NewRecorder()}
+		var wg sync.WaitGroup
+		for _, w := range recorders {
+			wg.Add(1)
+			go func(w *httptest.ResponseRecorder) {
+				defer wg.Done()
+				handler(w, httptest.NewRequest("GET", "/page", nil))
+			}(w)
+		}
+		for renders.Load() < 1 {
+			time.Sleep(time.Millisecond)
+		}
+		time.Sleep(100 * time.Millisecond)
+		close(release)
+		wg.Wait()
+
+		if renders.Load() != 1 {
+			t.Errorf("Expected a single render, got %d", renders.Load())
+		}
+		for _, w := range recorders {
+			if w.Code != http.StatusOK || w.Body.String() != "stale" {
+				t.Errorf("Expected stale content, got %d %q", w.Code, w.Body.String())
+			}
+		}
+	})
+
+	t.Run("successful render replaces stale entry", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Cache-Control", "max-age=60, stale-while-revalidate=30, stale-if-error=600")
+			w.Write([]byte("fresh"))
+		}))
+		defer ts.Close()
+
+		ssrCache := newCache()
+		ssrCache.SetEntry("/page", staleEntry("stale", 0, time.Hour), 0)
+		handler := SSRHandler(ssrCache, nil, []string{"false"}, 60, ".", newTestWorkerPool(ts.URL, true), nil, nil, nil)
+
+		w := httptest.NewRecorder()
+		handler(w, httptest.NewRequest("GET", "/page", nil))
+		if w.Body.String() != "fresh" {
+			t.Errorf("Expected fresh render, got %q", w.Body.String())
+		}
+		entry, _ := ssrCache.Get("/page")
+		if entry.StaleWhileRevalidate != 30*time.Second || entry.StaleIfError != 10*time.Minute {
+			t.Errorf("Expected worker stale windows, got %v and %v", entry.StaleWhileRevalidate, entry.StaleIfError)
+		}
+	})
+}
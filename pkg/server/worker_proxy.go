@@ -0,0 +1,117 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/devthefuture-org/blastra/pkg/worker"
+)
+
+// WorkerProxyConfig tunes the HTTP transport used to proxy SSR requests to workers
+type WorkerProxyConfig struct {
+	RenderTimeout         time.Duration            // Default timeout for rendering a page (0 disables)
+	RouteTimeouts         map[string]time.Duration // Render timeouts by path prefix, the longest prefix wins
+	DialTimeout           time.Duration            // Timeout for connecting to a worker
+	ResponseHeaderTimeout time.Duration            // Timeout for a worker to send response headers (0 disables)
+	IdleConnTimeout       time.Duration            // How long idle keep-alive connections are kept
+	MaxIdleConnsPerWorker int                      // Idle keep-alive connections kept per worker
+	MaxConnsPerWorker     int                      // Connections per worker, including active ones (0 means unlimited)
+	Streaming             bool                     // Flush worker output to clients as it is rendered instead of buffering it
+	Forwarding            *ForwardingPolicy        // What of the client request reaches workers (nil uses DefaultForwardingPolicy)
+	CacheStatuses         []int                    // Statuses of worker responses cached in the SSR cache (nil uses DefaultCacheStatuses)
+	NegativeCacheStatuses []int                    // Error statuses of worker responses cached in the 404 cache (nil uses DefaultNegativeCacheStatuses)
+	CacheEncodings        []string                 // Content codings cached bodies are stored in instead of raw (nil stores them raw)
+}
+
+// Statuses cached unless configured otherwise: pages in the SSR cache, not found pages in the 404 cache.
+// Permanent redirects are opt-in, e.g. CacheStatuses: []int{200, 301, 308}
+// (BLASTRA_CACHE_STATUSES=200,301,308), as a cached redirect outlives a fixed route.
+var (
+	DefaultCacheStatuses         = []int{http.StatusOK}
+	DefaultNegativeCacheStatuses = []int{http.StatusNotFound}
+)
+
+// DefaultWorkerProxyConfig returns the settings used when none are configured
+func DefaultWorkerProxyConfig() WorkerProxyConfig {
+	return WorkerProxyConfig{
+		RenderTimeout:         30 * time.Second,
+		DialTimeout:           2 * time.Second,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConnsPerWorker: 64,
+	}
+}
+
+// WorkerProxy holds the long-lived client used for every request to the workers,
+// so keep-alive connections are reused across requests
+type WorkerProxy struct {
+	config           WorkerProxyConfig
+	client           *http.Client
+	forwarding       *compiledForwarding
+	cacheStatuses    map[int]bool
+	negativeStatuses map[int]bool
+}
+
+// NewWorkerProxy creates a worker proxy with a transport tuned by config
+func NewWorkerProxy(config WorkerProxyConfig) *WorkerProxy {
+	transport := worker.NewTransport(&net.Dialer{
+		Timeout:   config.DialTimeout,
+		KeepAlive: 30 * time.Second,
+	})
+	transport.ResponseHeaderTimeout = config.ResponseHeaderTimeout
+	transport.IdleConnTimeout = config.IdleConnTimeout
+	transport.MaxIdleConns = 0 // Bounded per worker instead
+	transport.MaxIdleConnsPerHost = config.MaxIdleConnsPerWorker
+	transport.MaxConnsPerHost = config.MaxConnsPerWorker
+
+	forwarding := DefaultForwardingPolicy()
+	if config.Forwarding != nil {
+		forwarding = *config.Forwarding
+	}
+
+	cacheStatuses, negativeStatuses := DefaultCacheStatuses, DefaultNegativeCacheStatuses
+	if config.CacheStatuses != nil {
+		cacheStatuses = config.CacheStatuses
+	}
+	if config.NegativeCacheStatuses != nil {
+		negativeStatuses = config.NegativeCacheStatuses
+	}
+
+	return &WorkerProxy{
+		config:           config,
+		forwarding:       compileForwarding(forwarding),
+		cacheStatuses:    statusSet(cacheStatuses),
+		negativeStatuses: statusSet(negativeStatuses),
+		client: &http.Client{
+			Transport: transport,
+			CheckRedirect: func(req *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse // Don't follow redirects
+			},
+		},
+	}
+}
+
+// defaultWorkerProxy is used by handlers created without a worker proxy
+var defaultWorkerProxy = NewWorkerProxy(DefaultWorkerProxyConfig())
+
+func statusSet(statuses []int) map[int]bool {
+	set := make(map[int]bool, len(statuses))
+	for _, status := range statuses {
+		set[status] = true
+	}
+	return set
+}
+
+// renderTimeout returns the render timeout for a path
+func (p *WorkerProxy) renderTimeout(path string) time.Duration {
+	timeout := p.config.RenderTimeout
+	longest := -1
+	for prefix, d := range p.config.RouteTimeouts {
+		if strings.HasPrefix(path, prefix) && len(prefix) > longest {
+			timeout = d
+			longest = len(prefix)
+		}
+	}
+	return timeout
+}
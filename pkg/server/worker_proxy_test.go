@@ -0,0 +1,115 @@
+package server
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestWorkerProxy(t *testing.T) {
+	t.Run("route render timeouts", func(t *testing.T) {
+		proxy := NewWorkerProxy(WorkerProxyConfig{
+			RenderTimeout: 5 * time.Second,
+			RouteTimeouts: map[string]time.Duration{
+				"/reports/":       time.Minute,
+				"/reports/daily/": 2 * time.Minute,
+			},
+		})
+
+		tests := map[string]time.Duration{
+			"/":                   5 * time.Second,
+			"/reports/weekly":     time.Minute,
+			"/reports/daily/2024": 2 * time.Minute,
+		}
+		for path, want := range tests {
+			if got := proxy.renderTimeout(path); got != want {
+				t.Errorf("renderTimeout(%q) = %v, want %v", path, got, want)
+			}
+		}
+	})
+
+	t.Run("slow render times out", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(time.Second):
+			case <-r.Context().Done():
+			}
+			w.Write([]byte("too late"))
+		}))
+		defer ts.Close()
+
+		proxy := NewWorkerProxy(WorkerProxyConfig{
+			RenderTimeout: 5 * time.Second,
+			RouteTimeouts: map[string]time.Duration{"/slow": 50 * time.Millisecond},
+		})
+		wp := &testWorkerPool{endpoint: ts.URL, enabled: true}
+
+		req := httptest.NewRequest("GET", "/slow", nil)
+		w := httptest.NewRecorder()
+		start := time.Now()
+		if handled := handleWorkerSSR(w, req, wp, proxy, nil, nil, "/slow"); handled {
+			t.Error("Expected timed out render to fall back")
+		}
+		if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+			t.Errorf("Expected render to be cut at the route timeout, took %v", elapsed)
+		}
+		if len(wp.reported) != 1 || wp.reported[0] == nil {
+			t.Errorf("Expected timeout to be reported, got %v", wp.reported)
+		}
+	})
+
+	t.Run("client disconnecting isn't the worker's fault", func(t *testing.T) {
+		started := make(chan struct{})
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			close(started)
+			<-r.Context().Done()
+		}))
+		defer ts.Close()
+
+		wp := &testWorkerPool{endpoint: ts.URL, enabled: true}
+		ctx, cancel := context.WithCancel(context.Background())
+		req := httptest.NewRequest("GET", "/page", nil).WithContext(ctx)
+		go func() {
+			<-started
+			cancel()
+		}()
+
+		if handled := handleWorkerSSR(httptest.NewRecorder(), req, wp, NewWorkerProxy(DefaultWorkerProxyConfig()), nil, nil, "/page"); !handled {
+			t.Error("Expected no direct SSR fallback for a client that went away")
+		}
+		if len(wp.reported) != 1 || wp.reported[0] != nil {
+			t.Errorf("Expected the lease to be released without a failure, got %v", wp.reported)
+		}
+	})
+
+	t.Run("reuses connections", func(t *testing.T) {
+		var conns atomic.Int32
+		ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("ok"))
+		}))
+		ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+			if state == http.StateNew {
+				conns.Add(1)
+			}
+		}
+		ts.Start()
+		defer ts.Close()
+
+		proxy := NewWorkerProxy(DefaultWorkerProxyConfig())
+		wp := newTestWorkerPool(ts.URL, true)
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest("GET", "/page", nil)
+			w := httptest.NewRecorder()
+			if handled := handleWorkerSSR(w, req, wp, proxy, nil, nil, "/page"); !handled {
+				t.Fatal("Expected request to be handled")
+			}
+		}
+		if conns.Load() != 1 {
+			t.Errorf("Expected a single kept-alive connection, got %d", conns.Load())
+		}
+	})
+}
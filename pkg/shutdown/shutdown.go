@@ -8,6 +8,7 @@ import (
 	"syscall"
 	"time"
 
+	"github.com/devthefuture-org/blastra/pkg/cache"
 	"github.com/devthefuture-org/blastra/pkg/worker"
 	log "github.com/sirupsen/logrus"
 )
@@ -22,6 +23,7 @@ type Server interface {
 type ShutdownConfig struct {
 	Server          Server
 	WorkerPool      worker.IWorkerPool
+	Caches          []*cache.CacheProvider // Their queued writes are made once the server stopped serving
 	ShutdownTimeout time.Duration
 	TestShutdown    chan struct{} // Used for testing only
 }
@@ -56,6 +58,13 @@ func HandleGracefulShutdown(cfg *ShutdownConfig) chan error {
 			}
 		}
 
+		// Flush caches, within what is left of the timeout
+		for _, c := range cfg.Caches {
+			if err := c.Close(ctx); err != nil {
+				log.Errorf("Cache close failed: %v", err)
+			}
+		}
+
 		// Shutdown worker pool
 		if cfg.WorkerPool != nil {
 			cfg.WorkerPool.Shutdown()
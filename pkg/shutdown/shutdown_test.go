@@ -5,6 +5,9 @@ import (
 	"net/http"
 	"testing"
 	"time"
+
+	"github.com/devthefuture-org/blastra/pkg/cache"
+	"github.com/devthefuture-org/blastra/pkg/worker"
 )
 
 // mockServer implements the necessary methods of http.Server
@@ -35,6 +38,18 @@ func (w *mockWorkerPool) GetWorkerEndpoint() string {
 	return ""
 }
 
+func (w *mockWorkerPool) Acquire(ctx context.Context, key string) (*worker.Lease, error) {
+	return nil, worker.ErrNoWorkers
+}
+
+func (w *mockWorkerPool) GetWorkerStatuses() []worker.WorkerStatus {
+	return nil
+}
+
+func (w *mockWorkerPool) RollingRestart(reason string) error {
+	return worker.ErrRestartUnsupported
+}
+
 func (w *mockWorkerPool) Shutdown() {
 	w.shutdownCalled = true
 }
@@ -106,6 +121,32 @@ func TestHandleGracefulShutdown(t *testing.T) {
 		}
 	})
 
+	t.Run("queued cache writes are made", func(t *testing.T) {
+		external := &slowCache{Cache: cache.NewSSRInMemoryCache(cache.CacheConfig{TTL: time.Minute})}
+		provider := cache.NewCacheProvider(nil, external)
+		provider.UseWriteBehind(10)
+		keys := []string{"/a", "/b", "/c"}
+		for _, key := range keys {
+			provider.Set(key, []byte("content"))
+		}
+
+		testShutdown := make(chan struct{})
+		errors := HandleGracefulShutdown(&ShutdownConfig{
+			Server:          &mockServer{},
+			Caches:          []*cache.CacheProvider{provider},
+			ShutdownTimeout: 5 * time.Second,
+			TestShutdown:    testShutdown,
+		})
+		close(testShutdown)
+		<-errors
+
+		for _, key := range keys {
+			if _, found := external.Get(key); !found {
+				t.Errorf("Expected %s to be written before shutting down", key)
+			}
+		}
+	})
+
 	t.Run("nil worker pool", func(t *testing.T) {
 		server := &mockServer{}
 		testShutdown := make(chan struct{})
@@ -135,3 +176,18 @@ func TestHandleGracefulShutdown(t *testing.T) {
 		}
 	})
 }
+
+// slowCache takes a while to store entries, like a remote cache
+type slowCache struct {
+	cache.Cache
+}
+
+func (c *slowCache) Set(key string, content []byte) {
+	time.Sleep(20 * time.Millisecond)
+	c.Cache.Set(key, content)
+}
+
+func (c *slowCache) SetEntry(key string, entry cache.CacheEntry, ttl time.Duration) {
+	time.Sleep(20 * time.Millisecond)
+	c.Cache.SetEntry(key, entry, ttl)
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ParseByteSize parses sizes like "512M", "512MB", "1.5GiB" or "1073741824"
+// into bytes. Units are powers of 1024 and case insensitive, an empty size is 0.
+func ParseByteSize(value string) (uint64, error) {
+	s := strings.TrimSpace(strings.ToUpper(value))
+	if s == "" {
+		return 0, nil
+	}
+	s = strings.TrimSuffix(strings.TrimSuffix(s, "B"), "I")
+
+	multiplier := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "K"):
+		multiplier = 1 << 10
+	case strings.HasSuffix(s, "M"):
+		multiplier = 1 << 20
+	case strings.HasSuffix(s, "G"):
+		multiplier = 1 << 30
+	}
+	if multiplier > 1 {
+		s = s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+	size := n * float64(multiplier)
+	if err != nil || math.IsNaN(n) || math.IsInf(n, 0) || n < 0 || size >= math.MaxInt64 {
+		return 0, fmt.Errorf("invalid byte size %q", value)
+	}
+	return uint64(size), nil
+}
@@ -149,3 +149,31 @@ func TestSSRResponse(t *testing.T) {
 		}
 	})
 }
+
+func TestParseByteSize(t *testing.T) {
+	tests := []struct {
+		value string
+		want  uint64
+	}{
+		{"1048576", 1 << 20},
+		{"512K", 512 << 10},
+		{"512M", 512 << 20},
+		{"512MiB", 512 << 20},
+		{"1.5G", 3 << 29},
+		{"2gb", 2 << 30},
+		{"64MB", 64 << 20},
+		{"16 KB", 16 << 10},
+		{"", 0},
+	}
+	for _, tt := range tests {
+		got, err := ParseByteSize(tt.value)
+		if err != nil || got != tt.want {
+			t.Errorf("ParseByteSize(%q) = %d, %v, want %d", tt.value, got, err, tt.want)
+		}
+	}
+	for _, value := range []string{"lots", "-1M", "-1", "1e30G", "NaN", "nanM", "Inf", "+Inf", "-Inf", "infinity"} {
+		if _, err := ParseByteSize(value); err == nil {
+			t.Errorf("Expected %q to be an invalid size", value)
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package worker
+
+import (
+	"hash/fnv"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Balancer picks the worker that should serve a request among the workers
+// currently in rotation. The key identifies the request (the URL path) and is
+// only used by affinity-based strategies.
+type Balancer interface {
+	Pick(workers []*Worker, key string) *Worker
+}
+
+// Supported load-balancing strategies (BLASTRA_WORKER_BALANCER)
+const (
+	BalancerRoundRobin         = "round-robin"
+	BalancerLeastOutstanding   = "least-outstanding"
+	BalancerPowerOfTwo         = "p2c"
+	BalancerWeightedRoundRobin = "weighted-round-robin"
+	BalancerConsistentHash     = "consistent-hash"
+)
+
+// NewBalancer creates a balancer for the given strategy name, falling back to round robin
+func NewBalancer(strategy string) Balancer {
+	switch strings.ToLower(strategy) {
+	case "", BalancerRoundRobin:
+		return &roundRobinBalancer{}
+	case BalancerLeastOutstanding:
+		return &leastOutstandingBalancer{}
+	case BalancerPowerOfTwo:
+		return &powerOfTwoBalancer{}
+	case BalancerWeightedRoundRobin:
+		return &weightedRoundRobinBalancer{}
+	case BalancerConsistentHash:
+		return &consistentHashBalancer{}
+	default:
+		log.Warnf("Unknown worker balancer %q, using %s", strategy, BalancerRoundRobin)
+		return &roundRobinBalancer{}
+	}
+}
+
+type roundRobinBalancer struct {
+	counter uint64
+}
+
+func (b *roundRobinBalancer) Pick(workers []*Worker, key string) *Worker {
+	idx := atomic.AddUint64(&b.counter, 1)
+	return workers[idx%uint64(len(workers))]
+}
+
+// leastOutstandingBalancer picks the worker with the fewest in-flight requests,
+// rotating the starting point so ties are spread evenly
+type leastOutstandingBalancer struct {
+	counter uint64
+}
+
+func (b *leastOutstandingBalancer) Pick(workers []*Worker, key string) *Worker {
+	n := uint64(len(workers))
+	start := atomic.AddUint64(&b.counter, 1)
+	best := workers[start%n]
+	for i := uint64(1); i < n; i++ {
+		w := workers[(start+i)%n]
+		if w.inflight.Load() < best.inflight.Load() {
+			best = w
+		}
+	}
+	return best
+}
+
+// powerOfTwoBalancer samples two random workers and keeps the less loaded one
+type powerOfTwoBalancer struct{}
+
+func (b *powerOfTwoBalancer) Pick(workers []*Worker, key string) *Worker {
+	n := len(workers)
+	if n == 1 {
+		return workers[0]
+	}
+	i := rand.Intn(n)
+	j := rand.Intn(n - 1)
+	if j >= i {
+		j++
+	}
+	if workers[j].inflight.Load() < workers[i].inflight.Load() {
+		return workers[j]
+	}
+	return workers[i]
+}
+
+// weightedRoundRobinBalancer implements smooth weighted round robin, so a
+// worker with weight 3 gets three requests for every one sent to a weight 1
+// worker without receiving them in bursts
+type weightedRoundRobinBalancer struct {
+	mu sync.Mutex
+}
+
+func (b *weightedRoundRobinBalancer) Pick(workers []*Worker, key string) *Worker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	var best *Worker
+	total := 0
+	for _, w := range workers {
+		total += w.weight
+		w.currentWeight += w.weight
+		if best == nil || w.currentWeight > best.currentWeight {
+			best = w
+		}
+	}
+	best.currentWeight -= total
+	return best
+}
+
+// consistentHashBalancer uses rendezvous hashing on the key and the worker
+// slot, so a path keeps hitting the same worker (and its warm module cache)
+// across restarts, and only the keys of an ejected worker move elsewhere
+type consistentHashBalancer struct{}
+
+func (b *consistentHashBalancer) Pick(workers []*Worker, key string) *Worker {
+	var best *Worker
+	var bestScore uint64
+	for _, w := range workers {
+		h := fnv.New64a()
+		h.Write([]byte(key))
+		h.Write([]byte{0})
+		h.Write([]byte(strconv.Itoa(w.slot)))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best = w
+			bestScore = score
+		}
+	}
+	return best
+}
+
+// parseWeights parses a comma separated list of worker weights, defaulting
+// missing or invalid entries to 1
+func parseWeights(value string, count int) []int {
+	weights := make([]int, count)
+	parts := strings.Split(value, ",")
+	for i := range weights {
+		weights[i] = 1
+		if i < len(parts) {
+			if n, err := strconv.Atoi(strings.TrimSpace(parts[i])); err == nil && n > 0 {
+				weights[i] = n
+			}
+		}
+	}
+	return weights
+}
@@ -0,0 +1,131 @@
+package worker
+
+import (
+	"fmt"
+	"testing"
+)
+
+func testWorkers(n int) []*Worker {
+	workers := make([]*Worker, n)
+	for i := range workers {
+		workers[i] = &Worker{
+			slot:     i,
+			endpoint: fmt.Sprintf("http://worker-%d", i),
+			weight:   1,
+		}
+		workers[i].setState(WorkerReady)
+	}
+	return workers
+}
+
+func TestBalancers(t *testing.T) {
+	t.Run("round robin", func(t *testing.T) {
+		workers := testWorkers(3)
+		b := NewBalancer(BalancerRoundRobin)
+
+		counts := make(map[int]int)
+		for i := 0; i < 9; i++ {
+			counts[b.Pick(workers, "").slot]++
+		}
+		for slot, count := range counts {
+			if count != 3 {
+				t.Errorf("Expected worker %d to get 3 requests, got %d", slot, count)
+			}
+		}
+	})
+
+	t.Run("least outstanding", func(t *testing.T) {
+		workers := testWorkers(3)
+		workers[0].inflight.Store(5)
+		workers[1].inflight.Store(1)
+		workers[2].inflight.Store(3)
+		b := NewBalancer(BalancerLeastOutstanding)
+
+		for i := 0; i < 5; i++ {
+			if w := b.Pick(workers, ""); w.slot != 1 {
+				t.Errorf("Expected least loaded worker 1, got %d", w.slot)
+			}
+		}
+	})
+
+	t.Run("power of two choices", func(t *testing.T) {
+		workers := testWorkers(2)
+		workers[0].inflight.Store(10)
+		b := NewBalancer(BalancerPowerOfTwo)
+
+		// With two workers both are always sampled
+		for i := 0; i < 10; i++ {
+			if w := b.Pick(workers, ""); w.slot != 1 {
+				t.Errorf("Expected less loaded worker 1, got %d", w.slot)
+			}
+		}
+	})
+
+	t.Run("weighted round robin", func(t *testing.T) {
+		workers := testWorkers(2)
+		workers[0].weight = 3
+		b := NewBalancer(BalancerWeightedRoundRobin)
+
+		counts := make(map[int]int)
+		var sequence []int
+		for i := 0; i < 8; i++ {
+			slot := b.Pick(workers, "").slot
+			counts[slot]++
+			sequence = append(sequence, slot)
+		}
+		if counts[0] != 6 || counts[1] != 2 {
+			t.Errorf("Expected 6/2 split, got %v", counts)
+		}
+		// Smooth WRR interleaves instead of sending bursts
+		if fmt.Sprint(sequence[:4]) != "[0 0 1 0]" {
+			t.Errorf("Unexpected pick sequence %v", sequence)
+		}
+	})
+
+	t.Run("consistent hash", func(t *testing.T) {
+		workers := testWorkers(4)
+		b := NewBalancer(BalancerConsistentHash)
+
+		first := b.Pick(workers, "/products/42")
+		for i := 0; i < 5; i++ {
+			if w := b.Pick(workers, "/products/42"); w != first {
+				t.Error("Expected same key to map to the same worker")
+			}
+		}
+
+		// Removing an unrelated worker must not move the key
+		var remaining []*Worker
+		removed := false
+		for _, w := range workers {
+			if w != first && !removed {
+				removed = true
+				continue
+			}
+			remaining = append(remaining, w)
+		}
+		if w := b.Pick(remaining, "/products/42"); w != first {
+			t.Error("Expected key to stay on its worker when another worker leaves")
+		}
+
+		spread := make(map[int]bool)
+		for i := 0; i < 100; i++ {
+			spread[b.Pick(workers, fmt.Sprintf("/page/%d", i)).slot] = true
+		}
+		if len(spread) < 2 {
+			t.Error("Expected keys to spread over several workers")
+		}
+	})
+
+	t.Run("unknown strategy falls back to round robin", func(t *testing.T) {
+		if _, ok := NewBalancer("random").(*roundRobinBalancer); !ok {
+			t.Error("Expected round robin fallback")
+		}
+	})
+}
+
+func TestParseWeights(t *testing.T) {
+	weights := parseWeights("3, 0,x", 4)
+	if fmt.Sprint(weights) != "[3 1 1 1]" {
+		t.Errorf("Unexpected weights %v", weights)
+	}
+}
@@ -0,0 +1,110 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+// UnixEndpointPrefix marks endpoints of workers listening on a Unix domain socket,
+// e.g. "unix:/tmp/blastra-workers-123/worker-0-1.sock"
+const UnixEndpointPrefix = "unix:"
+
+// socketHosts maps the synthetic host of each worker socket to its path. Giving
+// every socket its own host makes http.Transport pool keep-alive connections per
+// worker, while DialWorker routes the connections to the right socket.
+var socketHosts sync.Map
+
+// socketHost returns the synthetic host used in request URLs for a worker socket
+func socketHost(socketPath string) string {
+	h := fnv.New64a()
+	h.Write([]byte(socketPath))
+	return fmt.Sprintf("worker-%x.sock", h.Sum64())
+}
+
+func unregisterSocket(socketPath string) {
+	socketHosts.Delete(socketHost(socketPath))
+}
+
+// ResolveEndpoint returns the base URL to build requests against for a worker
+// endpoint, and the socket the request is dialed to for Unix socket endpoints
+// (empty for TCP). Requests must go through a transport dialing with DialWorker.
+func ResolveEndpoint(endpoint string) (baseURL, socketPath string) {
+	socketPath, ok := strings.CutPrefix(endpoint, UnixEndpointPrefix)
+	if !ok {
+		return endpoint, ""
+	}
+	host := socketHost(socketPath)
+	socketHosts.LoadOrStore(host, socketPath)
+	return "http://" + host, socketPath
+}
+
+var workerDialer = &net.Dialer{
+	Timeout:   5 * time.Second,
+	KeepAlive: 30 * time.Second,
+}
+
+// DialWorker dials worker sockets for hosts returned by ResolveEndpoint and
+// falls back to a regular dial for TCP endpoints
+func DialWorker(ctx context.Context, network, addr string) (net.Conn, error) {
+	return dialWorker(ctx, workerDialer, network, addr)
+}
+
+func dialWorker(ctx context.Context, dialer *net.Dialer, network, addr string) (net.Conn, error) {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		if socketPath, ok := socketHosts.Load(host); ok {
+			return dialer.DialContext(ctx, "unix", socketPath.(string))
+		}
+		if strings.HasPrefix(host, "worker-") && strings.HasSuffix(host, ".sock") {
+			return nil, fmt.Errorf("worker socket for %s is gone", host)
+		}
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// workerProxy never proxies worker sockets, other endpoints honour the environment
+func workerProxy(req *http.Request) (*url.URL, error) {
+	if _, ok := socketHosts.Load(req.URL.Hostname()); ok {
+		return nil, nil
+	}
+	return http.ProxyFromEnvironment(req)
+}
+
+// NewTransport returns an http.Transport able to reach every worker endpoint,
+// dialing with the given dialer (nil for defaults)
+func NewTransport(dialer *net.Dialer) *http.Transport {
+	if dialer == nil {
+		dialer = workerDialer
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = workerProxy
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialWorker(ctx, dialer, network, addr)
+	}
+	transport.MaxIdleConnsPerHost = 32
+	return transport
+}
+
+// sharedTransport is used by every client returned from NewEndpointClient, so
+// connections to workers are kept alive across requests
+var sharedTransport = NewTransport(nil)
+
+// NewEndpointClient returns an HTTP client talking to the given worker endpoint and
+// the base URL to use for its requests. Redirects are passed through, not followed.
+func NewEndpointClient(endpoint string, timeout time.Duration) (*http.Client, string) {
+	baseURL, _ := ResolveEndpoint(endpoint)
+	client := &http.Client{
+		Transport: sharedTransport,
+		Timeout:   timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse // Don't follow redirects
+		},
+	}
+	return client, baseURL
+}
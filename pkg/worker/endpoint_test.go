@@ -0,0 +1,93 @@
+package worker
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// startSocketServer serves name on a Unix socket, counting new connections
+func startSocketServer(t *testing.T, name string, conns *atomic.Int32) string {
+	t.Helper()
+	socketPath := filepath.Join(t.TempDir(), name+".sock")
+	ln, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatalf("Failed to listen on socket: %v", err)
+	}
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(name + " " + r.URL.Path))
+	}))
+	ts.Listener = ln
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			conns.Add(1)
+		}
+	}
+	ts.Start()
+	t.Cleanup(ts.Close)
+	return UnixEndpointPrefix + socketPath
+}
+
+func get(t *testing.T, endpoint, path string) (string, error) {
+	t.Helper()
+	client, baseURL := NewEndpointClient(endpoint, 2*time.Second)
+	resp, err := client.Get(baseURL + path)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	return string(body), err
+}
+
+func TestEndpointTransport(t *testing.T) {
+	var connsA, connsB atomic.Int32
+	endpointA := startSocketServer(t, "a", &connsA)
+	endpointB := startSocketServer(t, "b", &connsB)
+
+	t.Run("routes to each worker socket", func(t *testing.T) {
+		for i := 0; i < 3; i++ {
+			for endpoint, want := range map[string]string{endpointA: "a /page", endpointB: "b /page"} {
+				body, err := get(t, endpoint, "/page")
+				if err != nil {
+					t.Fatalf("Request to %s failed: %v", endpoint, err)
+				}
+				if body != want {
+					t.Errorf("Expected %q from %s, got %q", want, endpoint, body)
+				}
+			}
+		}
+		// Connections are kept alive across clients sharing the transport
+		if connsA.Load() != 1 || connsB.Load() != 1 {
+			t.Errorf("Expected one connection per worker, got %d and %d", connsA.Load(), connsB.Load())
+		}
+	})
+
+	t.Run("tcp endpoints", func(t *testing.T) {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Write([]byte("tcp " + r.URL.Path))
+		}))
+		defer ts.Close()
+
+		if body, err := get(t, ts.URL, "/page"); err != nil || body != "tcp /page" {
+			t.Errorf("Expected TCP response, got %q (%v)", body, err)
+		}
+	})
+
+	t.Run("released socket", func(t *testing.T) {
+		var conns atomic.Int32
+		endpoint := startSocketServer(t, "c", &conns)
+		_, socketPath := ResolveEndpoint(endpoint)
+		(&Worker{socketPath: socketPath}).releaseAddress()
+
+		sharedTransport.CloseIdleConnections()
+		if _, err := get(t, endpoint, "/"); err == nil {
+			t.Error("Expected request to a removed socket to fail")
+		}
+	})
+}
@@ -0,0 +1,213 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// WorkerStatus is a point-in-time view of a worker, as exposed by IWorkerPool
+type WorkerStatus struct {
+	Slot         int       `json:"slot"`
+	Endpoint     string    `json:"endpoint"`
+	State        string    `json:"state"`
+	PID          int       `json:"pid,omitempty"`
+	StartedAt    time.Time `json:"startedAt,omitempty"`
+	Restarts     int       `json:"restarts"`
+	HealthErrors int32     `json:"healthErrors"`
+	ProxyErrors  int32     `json:"proxyErrors"`
+	Inflight     int64     `json:"inflight"`
+	Requests     int64     `json:"requests"`
+	Weight       int       `json:"weight"`
+	RSSBytes     uint64    `json:"rssBytes"`
+	CPUSeconds   float64   `json:"cpuSeconds"`
+	CPUPercent   float64   `json:"cpuPercent"`
+	LastError    string    `json:"lastError,omitempty"`
+}
+
+// healthSettings configures active probing and passive ejection of workers
+type healthSettings struct {
+	path      string
+	interval  time.Duration
+	timeout   time.Duration
+	failures  int // consecutive failed probes before ejection
+	successes int // consecutive successful probes before re-admission
+	ejectAt   int // consecutive proxy errors before passive ejection
+}
+
+func loadHealthSettings() healthSettings {
+	h := healthSettings{
+		path:      getEnvString("BLASTRA_WORKER_HEALTH_PATH", "/__blastra/health"),
+		interval:  getEnvDuration("BLASTRA_WORKER_HEALTH_INTERVAL", 5*time.Second),
+		timeout:   getEnvDuration("BLASTRA_WORKER_HEALTH_TIMEOUT", 2*time.Second),
+		failures:  getEnvInt("BLASTRA_WORKER_HEALTH_FAILURES", 3),
+		successes: getEnvInt("BLASTRA_WORKER_HEALTH_SUCCESSES", 2),
+		ejectAt:   getEnvInt("BLASTRA_WORKER_EJECT_ERRORS", 5),
+	}
+	if h.failures < 1 {
+		h.failures = 1
+	}
+	if h.successes < 1 {
+		h.successes = 1
+	}
+	return h
+}
+
+// startHealthChecks probes every worker periodically until the pool context is cancelled.
+// Without active probing there is nothing to re-admit ejected workers, so passive ejection
+// is only enabled alongside it.
+func (wp *WorkerPool) startHealthChecks() {
+	h := wp.settings.health
+	if h.interval <= 0 {
+		log.Debug("Worker health checks disabled")
+		return
+	}
+
+	log.WithFields(log.Fields{
+		"path":      h.path,
+		"interval":  h.interval.String(),
+		"failures":  h.failures,
+		"successes": h.successes,
+	}).Debug("Starting worker health checks")
+
+	go func() {
+		ticker := time.NewTicker(h.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-wp.ctx.Done():
+				return
+			case <-ticker.C:
+				var wg sync.WaitGroup
+				for _, w := range wp.snapshotWorkers() {
+					state := w.getState()
+					if state != WorkerReady && state != WorkerUnhealthy {
+						continue
+					}
+					wg.Add(1)
+					go func(w *Worker) {
+						defer wg.Done()
+						client, baseURL := NewEndpointClient(w.endpoint, h.timeout)
+						wp.recordProbe(w, probeWorker(wp.ctx, client, baseURL+h.path))
+					}(w)
+				}
+				wg.Wait()
+			}
+		}
+	}()
+}
+
+// probeWorker issues a health probe, which passes on a 2xx response. Workers
+// without the health route fail it, so point BLASTRA_WORKER_HEALTH_PATH to a
+// page they render, or disable probing with BLASTRA_WORKER_HEALTH_INTERVAL=0.
+func probeWorker(ctx context.Context, client *http.Client, url string) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("health probe returned status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func (wp *WorkerPool) recordProbe(w *Worker, err error) {
+	h := wp.settings.health
+	w.healthMu.Lock()
+	defer w.healthMu.Unlock()
+
+	if err != nil {
+		w.healthSuccesses = 0
+		w.healthFailures++
+		w.lastError = err.Error()
+		if w.healthFailures >= int32(h.failures) && w.state.CompareAndSwap(int32(WorkerReady), int32(WorkerUnhealthy)) {
+			log.WithFields(log.Fields{
+				"slot":     w.slot,
+				"endpoint": w.endpoint,
+				"failures": w.healthFailures,
+				"error":    err.Error(),
+			}).Warn("Worker failed health checks, removing from rotation")
+		}
+		return
+	}
+
+	w.healthFailures = 0
+	w.healthSuccesses++
+	if w.healthSuccesses >= int32(h.successes) && w.state.CompareAndSwap(int32(WorkerUnhealthy), int32(WorkerReady)) {
+		w.proxyErrors = 0
+		log.WithFields(log.Fields{
+			"slot":     w.slot,
+			"endpoint": w.endpoint,
+		}).Info("Worker recovered, returning to rotation")
+		wp.notifyCapacity()
+	}
+}
+
+// recordResult records the outcome of a request proxied to a worker, ejecting
+// the worker after too many consecutive errors
+func (wp *WorkerPool) recordResult(w *Worker, err error) {
+	h := wp.settings.health
+	w.healthMu.Lock()
+	defer w.healthMu.Unlock()
+
+	if err == nil {
+		w.proxyErrors = 0
+		return
+	}
+
+	w.proxyErrors++
+	w.lastError = err.Error()
+	if h.interval <= 0 || h.ejectAt <= 0 || w.proxyErrors < int32(h.ejectAt) {
+		return
+	}
+	if w.state.CompareAndSwap(int32(WorkerReady), int32(WorkerUnhealthy)) {
+		w.healthSuccesses = 0
+		log.WithFields(log.Fields{
+			"slot":         w.slot,
+			"endpoint":     w.endpoint,
+			"proxy_errors": w.proxyErrors,
+			"error":        err.Error(),
+		}).Warn("Worker ejected after consecutive proxy errors")
+	}
+}
+
+// GetWorkerStatuses returns the current state of every worker slot
+func (wp *WorkerPool) GetWorkerStatuses() []WorkerStatus {
+	workers := wp.snapshotWorkers()
+	statuses := make([]WorkerStatus, 0, len(workers))
+	for _, w := range workers {
+		w.healthMu.Lock()
+		status := WorkerStatus{
+			Slot:         w.slot,
+			Endpoint:     w.endpoint,
+			State:        w.getState().String(),
+			StartedAt:    w.startedAt,
+			Restarts:     len(w.restarts),
+			HealthErrors: w.healthFailures,
+			ProxyErrors:  w.proxyErrors,
+			Inflight:     w.inflight.Load(),
+			Requests:     w.requests.Load(),
+			Weight:       w.weight,
+			LastError:    w.lastError,
+		}
+		w.healthMu.Unlock()
+		usage := w.usage.get()
+		status.RSSBytes = usage.rssBytes
+		status.CPUSeconds = float64(usage.cpuTicks) / clockTicks
+		status.CPUPercent = usage.cpuPercent
+		if w.cmd != nil && w.cmd.Process != nil {
+			status.PID = w.cmd.Process.Pid
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
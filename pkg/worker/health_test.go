@@ -0,0 +1,130 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func waitForState(t *testing.T, wp *WorkerPool, slot int, state string, timeout time.Duration) bool {
+	t.Helper()
+	deadline := time.Now().Add(timeout)
+	for time.Now().Before(deadline) {
+		if wp.GetWorkerStatuses()[slot].State == state {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestProbeWorker(t *testing.T) {
+	for _, tt := range []struct {
+		status int
+		ok     bool
+	}{
+		{http.StatusOK, true},
+		{http.StatusNoContent, true},
+		{http.StatusFound, false},
+		{http.StatusNotFound, false},
+		{http.StatusServiceUnavailable, false},
+	} {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(tt.status)
+		}))
+		err := probeWorker(context.Background(), ts.Client(), ts.URL+"/__blastra/health")
+		if (err == nil) != tt.ok {
+			t.Errorf("Probe answered with %d: got error %v, want ok=%v", tt.status, err, tt.ok)
+		}
+		ts.Close()
+	}
+}
+
+func TestWorkerHealthChecks(t *testing.T) {
+	t.Run("active probes eject and re-admit external workers", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_HEALTH_INTERVAL", "20ms")
+		setEnv(t, "BLASTRA_WORKER_HEALTH_FAILURES", "2")
+		setEnv(t, "BLASTRA_WORKER_HEALTH_SUCCESSES", "2")
+
+		var failing atomic.Bool
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/__blastra/health" {
+				t.Errorf("Unexpected probe path %s", r.URL.Path)
+			}
+			if failing.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+				return
+			}
+			w.Write([]byte("OK"))
+		}))
+		defer ts.Close()
+
+		pool, err := StartWorkerPoolWithConfig(0, ".", "", nil, []string{ts.URL})
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+		wp := pool.(*WorkerPool)
+
+		failing.Store(true)
+		if !waitForState(t, wp, 0, "unhealthy", 2*time.Second) {
+			t.Fatal("Expected worker to be ejected after failed probes")
+		}
+		if pool.GetWorkerEndpoint() != "" {
+			t.Error("Expected ejected worker to be out of rotation")
+		}
+
+		failing.Store(false)
+		if !waitForState(t, wp, 0, "ready", 2*time.Second) {
+			t.Fatal("Expected worker to be re-admitted after successful probes")
+		}
+		if pool.GetWorkerEndpoint() != ts.URL {
+			t.Error("Expected recovered worker back in rotation")
+		}
+	})
+
+	t.Run("passive ejection on proxy errors", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_HEALTH_INTERVAL", "1h")
+		setEnv(t, "BLASTRA_WORKER_EJECT_ERRORS", "3")
+
+		pool, err := StartWorkerPoolWithConfig(0, ".", "", nil, []string{"http://worker-a", "http://worker-b"})
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+
+		wp := pool.(*WorkerPool)
+		workerA := wp.snapshotWorkers()[0]
+
+		proxyErr := errors.New("connection refused")
+		wp.recordResult(workerA, proxyErr)
+		wp.recordResult(workerA, proxyErr)
+		wp.recordResult(workerA, nil) // success resets the streak
+		wp.recordResult(workerA, proxyErr)
+		wp.recordResult(workerA, proxyErr)
+
+		statuses := pool.GetWorkerStatuses()
+		if statuses[0].State != "ready" {
+			t.Fatalf("Expected worker to stay ready, got %s", statuses[0].State)
+		}
+
+		wp.recordResult(workerA, proxyErr)
+		statuses = pool.GetWorkerStatuses()
+		if statuses[0].State != "unhealthy" {
+			t.Fatalf("Expected worker to be ejected, got %s", statuses[0].State)
+		}
+		if statuses[0].ProxyErrors != 3 || statuses[0].LastError != proxyErr.Error() {
+			t.Errorf("Unexpected status %+v", statuses[0])
+		}
+
+		for i := 0; i < 5; i++ {
+			if endpoint := pool.GetWorkerEndpoint(); endpoint != "http://worker-b" {
+				t.Errorf("Expected only healthy worker in rotation, got %s", endpoint)
+			}
+		}
+	})
+}
@@ -0,0 +1,194 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrNoWorkers is returned by Acquire when no worker is in rotation
+	ErrNoWorkers = errors.New("no worker available")
+	// ErrQueueFull is returned when too many requests are already waiting for a worker
+	ErrQueueFull = errors.New("worker queue full")
+	// ErrQueueTimeout is returned when no worker freed up within the queue timeout
+	ErrQueueTimeout = errors.New("timed out waiting for a worker")
+)
+
+// BusyError reports that every worker is at its concurrency limit and the
+// request could not be queued, along with how long clients should back off
+type BusyError struct {
+	Err        error
+	RetryAfter time.Duration
+}
+
+func (e *BusyError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *BusyError) Unwrap() error {
+	return e.Err
+}
+
+// Lease reserves a worker for a single request. It must be released exactly
+// once when the request completes; extra calls to Release are ignored.
+type Lease struct {
+	endpoint string
+	release  func(err error)
+	once     sync.Once
+}
+
+// NewLease creates a lease for the given endpoint, calling release when it is released
+func NewLease(endpoint string, release func(err error)) *Lease {
+	return &Lease{endpoint: endpoint, release: release}
+}
+
+func (l *Lease) Endpoint() string {
+	return l.endpoint
+}
+
+// Release returns the worker to the pool. A non-nil err reports that proxying
+// to the worker failed, which counts towards passive ejection.
+func (l *Lease) Release(err error) {
+	l.once.Do(func() {
+		if l.release != nil {
+			l.release(err)
+		}
+	})
+}
+
+// leaseSettings bounds per-worker concurrency and the wait queue in front of it
+type leaseSettings struct {
+	maxConcurrency int // 0 means unlimited
+	queueSize      int
+	queueTimeout   time.Duration
+	retryAfter     time.Duration
+}
+
+func loadLeaseSettings() leaseSettings {
+	return leaseSettings{
+		maxConcurrency: getEnvInt("BLASTRA_WORKER_MAX_CONCURRENCY", 0),
+		queueSize:      getEnvInt("BLASTRA_WORKER_QUEUE_SIZE", 100),
+		queueTimeout:   getEnvDuration("BLASTRA_WORKER_QUEUE_TIMEOUT", 5*time.Second),
+		retryAfter:     getEnvDuration("BLASTRA_WORKER_RETRY_AFTER", time.Second),
+	}
+}
+
+// Acquire leases a worker for the request identified by key. When every worker
+// is at its concurrency limit the request waits in a bounded queue until a
+// worker frees up, the queue timeout expires or ctx is done.
+func (wp *WorkerPool) Acquire(ctx context.Context, key string) (*Lease, error) {
+	lease, err := wp.tryAcquire(key)
+	if lease != nil || err != nil {
+		return lease, err
+	}
+
+	l := wp.settings.lease
+	if int(wp.waiting.Add(1)) > l.queueSize {
+		wp.waiting.Add(-1)
+		log.Warnf("Worker queue full, rejecting request for %s", key)
+		return nil, &BusyError{Err: ErrQueueFull, RetryAfter: l.retryAfter}
+	}
+	defer wp.waiting.Add(-1)
+
+	timer := time.NewTimer(l.queueTimeout)
+	defer timer.Stop()
+
+	for {
+		// Grab the wake-up channel before checking, so a release in between is not missed
+		freed := wp.capacityChan()
+		lease, err := wp.tryAcquire(key)
+		if lease != nil || err != nil {
+			return lease, err
+		}
+
+		select {
+		case <-freed:
+		case <-timer.C:
+			log.Warnf("Timed out waiting for a worker for %s", key)
+			return nil, &BusyError{Err: ErrQueueTimeout, RetryAfter: l.retryAfter}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// tryAcquire leases a worker with spare capacity. It returns (nil, nil) when
+// workers are in rotation but all of them are saturated.
+func (wp *WorkerPool) tryAcquire(key string) (*Lease, error) {
+	workers := wp.snapshotWorkers()
+	if !wp.enabled || len(workers) == 0 {
+		log.Debug("No available workers in pool")
+		return nil, ErrNoWorkers
+	}
+
+	max := int64(wp.settings.lease.maxConcurrency)
+	candidates := make([]*Worker, 0, len(workers))
+	inRotation := false
+	for _, worker := range workers {
+		if !worker.isReady() {
+			continue
+		}
+		inRotation = true
+		if max <= 0 || worker.inflight.Load() < max {
+			candidates = append(candidates, worker)
+		}
+	}
+	if !inRotation {
+		log.Debug("No ready workers in pool")
+		return nil, ErrNoWorkers
+	}
+
+	for len(candidates) > 0 {
+		worker := wp.balancer.Pick(candidates, key)
+		if n := worker.inflight.Add(1); max <= 0 || n <= max {
+			worker.requests.Add(1)
+			log.Debugf("Dispatching request for %s to worker endpoint: %s", key, worker.endpoint)
+			return NewLease(worker.endpoint, func(err error) {
+				wp.release(worker, err)
+			}), nil
+		}
+
+		// Lost a race for the last slot, try the remaining workers
+		worker.inflight.Add(-1)
+		for i, c := range candidates {
+			if c == worker {
+				candidates = append(candidates[:i:i], candidates[i+1:]...)
+				break
+			}
+		}
+	}
+	return nil, nil
+}
+
+func (wp *WorkerPool) release(w *Worker, err error) {
+	w.inflight.Add(-1)
+	wp.recordResult(w, err)
+	wp.notifyCapacity()
+}
+
+// capacityChan returns a channel closed the next time worker capacity frees up
+func (wp *WorkerPool) capacityChan() <-chan struct{} {
+	wp.capacityMu.Lock()
+	defer wp.capacityMu.Unlock()
+	if wp.capacity == nil {
+		wp.capacity = make(chan struct{})
+	}
+	return wp.capacity
+}
+
+// notifyCapacity wakes up queued requests
+func (wp *WorkerPool) notifyCapacity() {
+	if wp.waiting.Load() == 0 {
+		return
+	}
+	wp.capacityMu.Lock()
+	if wp.capacity != nil {
+		close(wp.capacity)
+		wp.capacity = nil
+	}
+	wp.capacityMu.Unlock()
+}
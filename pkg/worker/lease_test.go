@@ -0,0 +1,133 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+)
+
+func startExternalPool(t *testing.T, urls ...string) *WorkerPool {
+	t.Helper()
+	setEnv(t, "BLASTRA_WORKER_HEALTH_INTERVAL", "0")
+	pool, err := StartWorkerPoolWithConfig(0, ".", "", nil, urls)
+	if err != nil {
+		t.Fatalf("Failed to create worker pool: %v", err)
+	}
+	t.Cleanup(pool.Shutdown)
+	return pool.(*WorkerPool)
+}
+
+func TestWorkerLease(t *testing.T) {
+	t.Run("tracks in-flight requests", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_BALANCER", BalancerLeastOutstanding)
+		wp := startExternalPool(t, "http://worker-a", "http://worker-b")
+
+		first, err := wp.Acquire(context.Background(), "/a")
+		if err != nil {
+			t.Fatalf("Failed to acquire lease: %v", err)
+		}
+		second, err := wp.Acquire(context.Background(), "/b")
+		if err != nil {
+			t.Fatalf("Failed to acquire lease: %v", err)
+		}
+		if first.Endpoint() == second.Endpoint() {
+			t.Fatalf("Expected busy worker to be avoided, got %s twice", first.Endpoint())
+		}
+
+		first.Release(nil)
+		first.Release(nil) // Double release is ignored
+		third, err := wp.Acquire(context.Background(), "/c")
+		if err != nil {
+			t.Fatalf("Failed to acquire lease: %v", err)
+		}
+		if third.Endpoint() != first.Endpoint() {
+			t.Errorf("Expected released worker %s to be picked, got %s", first.Endpoint(), third.Endpoint())
+		}
+
+		for _, status := range wp.GetWorkerStatuses() {
+			if status.Inflight != 1 {
+				t.Errorf("Expected 1 in-flight request on %s, got %d", status.Endpoint, status.Inflight)
+			}
+		}
+	})
+
+	t.Run("queued request gets released worker", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_MAX_CONCURRENCY", "1")
+		setEnv(t, "BLASTRA_WORKER_QUEUE_TIMEOUT", "2s")
+		wp := startExternalPool(t, "http://worker-a")
+
+		held, err := wp.Acquire(context.Background(), "/a")
+		if err != nil {
+			t.Fatalf("Failed to acquire lease: %v", err)
+		}
+
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			held.Release(nil)
+		}()
+
+		start := time.Now()
+		queued, err := wp.Acquire(context.Background(), "/b")
+		if err != nil {
+			t.Fatalf("Expected queued request to get a worker, got %v", err)
+		}
+		defer queued.Release(nil)
+		if time.Since(start) < 40*time.Millisecond {
+			t.Error("Expected queued request to wait for the release")
+		}
+	})
+
+	t.Run("queue timeout", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_MAX_CONCURRENCY", "1")
+		setEnv(t, "BLASTRA_WORKER_QUEUE_TIMEOUT", "50ms")
+		setEnv(t, "BLASTRA_WORKER_RETRY_AFTER", "3s")
+		wp := startExternalPool(t, "http://worker-a")
+
+		held, _ := wp.Acquire(context.Background(), "/a")
+		defer held.Release(nil)
+
+		_, err := wp.Acquire(context.Background(), "/b")
+		var busy *BusyError
+		if !errors.As(err, &busy) || !errors.Is(err, ErrQueueTimeout) {
+			t.Fatalf("Expected queue timeout, got %v", err)
+		}
+		if busy.RetryAfter != 3*time.Second {
+			t.Errorf("Expected RetryAfter 3s, got %v", busy.RetryAfter)
+		}
+	})
+
+	t.Run("queue full", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_MAX_CONCURRENCY", "1")
+		setEnv(t, "BLASTRA_WORKER_QUEUE_SIZE", "0")
+		wp := startExternalPool(t, "http://worker-a")
+
+		held, _ := wp.Acquire(context.Background(), "/a")
+		defer held.Release(nil)
+
+		if _, err := wp.Acquire(context.Background(), "/b"); !errors.Is(err, ErrQueueFull) {
+			t.Fatalf("Expected queue full, got %v", err)
+		}
+	})
+
+	t.Run("request deadline", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_MAX_CONCURRENCY", "1")
+		wp := startExternalPool(t, "http://worker-a")
+
+		held, _ := wp.Acquire(context.Background(), "/a")
+		defer held.Release(nil)
+
+		ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+		defer cancel()
+		if _, err := wp.Acquire(ctx, "/b"); !errors.Is(err, context.DeadlineExceeded) {
+			t.Fatalf("Expected deadline exceeded, got %v", err)
+		}
+	})
+
+	t.Run("no workers", func(t *testing.T) {
+		pool, _ := StartWorkerPoolWithCommand(0, ".", "", nil)
+		if _, err := pool.Acquire(context.Background(), "/"); !errors.Is(err, ErrNoWorkers) {
+			t.Fatalf("Expected ErrNoWorkers, got %v", err)
+		}
+	})
+}
@@ -0,0 +1,135 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Worker transports
+const (
+	TransportTCP  = "tcp"  // workers listen on a loopback port
+	TransportUnix = "unix" // workers listen on a Unix domain socket
+)
+
+// ErrNoFreePort is returned when every port of the configured range is taken
+var ErrNoFreePort = errors.New("no free port in worker port range")
+
+// portRange is an inclusive range of ports local workers may listen on
+type portRange struct {
+	first int
+	last  int
+}
+
+var defaultPortRange = portRange{first: 5174, last: 6173}
+
+// parsePortRange parses "first-last", or a single port
+func parsePortRange(value string) (portRange, error) {
+	lo, hi, found := strings.Cut(strings.TrimSpace(value), "-")
+	if !found {
+		hi = lo
+	}
+	first, err := strconv.Atoi(strings.TrimSpace(lo))
+	if err != nil {
+		return portRange{}, fmt.Errorf("invalid port range %q", value)
+	}
+	last, err := strconv.Atoi(strings.TrimSpace(hi))
+	if err != nil {
+		return portRange{}, fmt.Errorf("invalid port range %q", value)
+	}
+	if first < 1 || last > 65535 || first > last {
+		return portRange{}, fmt.Errorf("invalid port range %q", value)
+	}
+	return portRange{first: first, last: last}, nil
+}
+
+func getEnvPortRange(key string, def portRange) portRange {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	if r, err := parsePortRange(val); err == nil {
+		return r
+	}
+	return def
+}
+
+// Ports reserved by workers of any pool in this process. The cursor hands ports
+// out round robin so a restarted worker gets a different port than its predecessor.
+var (
+	reservedPorts = make(map[int]bool)
+	portCursor    int
+	portsMu       sync.Mutex
+)
+
+// allocatePort reserves a port of r that is free both in this process and on the host
+func allocatePort(r portRange) (int, error) {
+	portsMu.Lock()
+	defer portsMu.Unlock()
+
+	size := r.last - r.first + 1
+	start := portCursor + 1
+	if start < r.first || start > r.last {
+		start = r.first
+	}
+	for i := 0; i < size; i++ {
+		port := r.first + (start-r.first+i)%size
+		if reservedPorts[port] || !portAvailable(port) {
+			continue
+		}
+		reservedPorts[port] = true
+		portCursor = port
+		return port, nil
+	}
+	return 0, fmt.Errorf("%w %d-%d", ErrNoFreePort, r.first, r.last)
+}
+
+// portAvailable bind-checks a port on all interfaces, like the Node server binds it
+func portAvailable(port int) bool {
+	ln, err := net.Listen("tcp", ":"+strconv.Itoa(port))
+	if err != nil {
+		return false
+	}
+	ln.Close()
+	return true
+}
+
+func releasePort(port int) {
+	portsMu.Lock()
+	delete(reservedPorts, port)
+	portsMu.Unlock()
+}
+
+// newSocketPath returns a fresh socket path for a worker slot, removing any stale file
+func (wp *WorkerPool) newSocketPath(slot int) (string, error) {
+	path := filepath.Join(wp.socketDir, fmt.Sprintf("worker-%d-%d.sock", slot, wp.socketSeq.Add(1)))
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return "", err
+	}
+	return path, nil
+}
+
+// releaseAddress frees the port or removes the socket file of an exited worker
+func (w *Worker) releaseAddress() {
+	if w.socketPath != "" {
+		unregisterSocket(w.socketPath)
+		_ = os.Remove(w.socketPath)
+		return
+	}
+	if w.port > 0 {
+		releasePort(w.port)
+	}
+}
+
+// address describes where the worker listens, for logs
+func (w *Worker) address() string {
+	if w.socketPath != "" {
+		return w.socketPath
+	}
+	return "127.0.0.1:" + strconv.Itoa(w.port)
+}
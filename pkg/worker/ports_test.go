@@ -0,0 +1,148 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestHelperWorker is not a real test: it is the worker process spawned by
+// startHelperPool, serving HTTP on the port or socket handed out by the pool.
+func TestHelperWorker(t *testing.T) {
+	if os.Getenv("BLASTRA_TEST_HELPER_WORKER") != "1" {
+		t.Skip("only runs as a helper process")
+	}
+	network, address := "tcp", ":"+os.Getenv("PORT")
+	if socket := os.Getenv("BLASTRA_SOCKET"); socket != "" {
+		network, address = "unix", socket
+	}
+	ln, err := net.Listen(network, address)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+	_ = http.Serve(ln, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "rendered %s", r.URL.Path)
+	}))
+	os.Exit(0)
+}
+
+func startHelperPool(t *testing.T) IWorkerPool {
+	t.Helper()
+	setEnv(t, "BLASTRA_TEST_HELPER_WORKER", "1")
+	setEnv(t, "BLASTRA_WORKER_READY_METHOD", "tcp+http")
+	setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "10s")
+	pool, err := StartWorkerPoolWithCommand(1, ".", os.Args[0], []string{"-test.run=^TestHelperWorker$"})
+	if err != nil {
+		t.Fatalf("Failed to create worker pool: %v", err)
+	}
+	t.Cleanup(pool.Shutdown)
+	return pool
+}
+
+func renderThroughPool(t *testing.T, pool IWorkerPool, path string) string {
+	t.Helper()
+	lease, err := pool.Acquire(context.Background(), path)
+	if err != nil {
+		t.Fatalf("Failed to acquire lease: %v", err)
+	}
+	defer lease.Release(nil)
+
+	client, baseURL := NewEndpointClient(lease.Endpoint(), startTimeout)
+	resp, err := client.Get(baseURL + path)
+	if err != nil {
+		t.Fatalf("Worker request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	body, _ := io.ReadAll(resp.Body)
+	return string(body)
+}
+
+func TestParsePortRange(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    portRange
+		wantErr bool
+	}{
+		{"5174-5200", portRange{5174, 5200}, false},
+		{" 6000 - 6001 ", portRange{6000, 6001}, false},
+		{"7000", portRange{7000, 7000}, false},
+		{"5200-5174", portRange{}, true},
+		{"0-10", portRange{}, true},
+		{"a-b", portRange{}, true},
+	}
+	for _, tt := range tests {
+		got, err := parsePortRange(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parsePortRange(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestAllocatePort(t *testing.T) {
+	// Hold a port like a stray process would
+	ln, err := net.Listen("tcp", ":0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	defer ln.Close()
+	taken := ln.Addr().(*net.TCPAddr).Port
+
+	t.Run("skips ports in use", func(t *testing.T) {
+		port, err := allocatePort(portRange{taken, taken + 5})
+		if err != nil {
+			t.Fatalf("Failed to allocate port: %v", err)
+		}
+		defer releasePort(port)
+		if port == taken {
+			t.Errorf("Expected port %d held by another process to be skipped", taken)
+		}
+	})
+
+	t.Run("range exhausted", func(t *testing.T) {
+		if _, err := allocatePort(portRange{taken, taken}); !errors.Is(err, ErrNoFreePort) {
+			t.Errorf("Expected ErrNoFreePort, got %v", err)
+		}
+	})
+
+	t.Run("worker uses configured range", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_PORT_RANGE", fmt.Sprintf("%d-%d", taken, taken+5))
+		pool := startHelperPool(t)
+
+		endpoint := pool.GetWorkerEndpoint()
+		port, _ := strconv.Atoi(endpoint[strings.LastIndex(endpoint, ":")+1:])
+		if port <= taken || port > taken+5 {
+			t.Errorf("Expected worker port in %d-%d, got %s", taken+1, taken+5, endpoint)
+		}
+		if body := renderThroughPool(t, pool, "/about"); body != "rendered /about" {
+			t.Errorf("Unexpected worker response %q", body)
+		}
+	})
+}
+
+func TestUnixSocketTransport(t *testing.T) {
+	setEnv(t, "BLASTRA_WORKER_TRANSPORT", TransportUnix)
+	setEnv(t, "BLASTRA_WORKER_SOCKET_DIR", t.TempDir())
+	pool := startHelperPool(t)
+
+	endpoint := pool.GetWorkerEndpoint()
+	if !strings.HasPrefix(endpoint, UnixEndpointPrefix) {
+		t.Fatalf("Expected unix endpoint, got %s", endpoint)
+	}
+	if body := renderThroughPool(t, pool, "/about"); body != "rendered /about" {
+		t.Errorf("Unexpected worker response %q", body)
+	}
+
+	socketDir := pool.(*WorkerPool).socketDir
+	pool.Shutdown()
+	if _, err := os.Stat(socketDir); !os.IsNotExist(err) {
+		t.Errorf("Expected socket directory %s to be removed, got %v", socketDir, err)
+	}
+}
@@ -0,0 +1,175 @@
+package worker
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Readiness methods, combined with "," or "+" (e.g. "stdout+http"); all of them must pass
+const (
+	ReadyMethodStdout = "stdout" // wait for the ready pattern on stdout
+	ReadyMethodTCP    = "tcp"    // wait for the port or socket to accept connections
+	ReadyMethodHTTP   = "http"   // wait for a 2xx response on the ready path
+)
+
+// readySettings configures how a spawned worker is deemed ready to enter rotation
+type readySettings struct {
+	methods  []string
+	pattern  string
+	path     string
+	timeout  time.Duration // 0 skips readiness checks
+	interval time.Duration
+}
+
+func loadReadySettings(healthPath string) readySettings {
+	return readySettings{
+		methods:  parseReadyMethods(getEnvString("BLASTRA_WORKER_READY_METHOD", ReadyMethodTCP)),
+		pattern:  getEnvString("BLASTRA_WORKER_READY_PATTERN", "BLASTRA_READY"),
+		path:     getEnvString("BLASTRA_WORKER_READY_PATH", healthPath),
+		timeout:  getEnvDuration("BLASTRA_WORKER_READY_TIMEOUT", 10*time.Second),
+		interval: getEnvDuration("BLASTRA_WORKER_PROBE_INTERVAL", 50*time.Millisecond),
+	}
+}
+
+// parseReadyMethods parses a method list, dropping unknown entries and falling back to tcp
+func parseReadyMethods(value string) []string {
+	var methods []string
+	seen := make(map[string]bool)
+	for _, part := range strings.FieldsFunc(value, func(r rune) bool { return r == ',' || r == '+' }) {
+		method := strings.ToLower(strings.TrimSpace(part))
+		switch method {
+		case ReadyMethodStdout, ReadyMethodTCP, ReadyMethodHTTP:
+			if !seen[method] {
+				seen[method] = true
+				methods = append(methods, method)
+			}
+		case "":
+		default:
+			log.Warnf("Unknown worker readiness method %q, ignoring", method)
+		}
+	}
+	if len(methods) == 0 {
+		return []string{ReadyMethodTCP}
+	}
+	return methods
+}
+
+// readySignal collects the startup signals a worker emits on stdout
+type readySignal struct {
+	marker     chan struct{} // closed when the ready pattern is seen
+	markerOnce sync.Once
+	closed     chan struct{} // closed when stdout ends, i.e. the process went away
+}
+
+func newReadySignal() *readySignal {
+	return &readySignal{
+		marker: make(chan struct{}),
+		closed: make(chan struct{}),
+	}
+}
+
+func (s *readySignal) markReady() {
+	s.markerOnce.Do(func() { close(s.marker) })
+}
+
+// waitReady runs every configured readiness method against a shared deadline
+func (wp *WorkerPool) waitReady(w *Worker, signal *readySignal) (string, error) {
+	r := wp.settings.ready
+	ctx, cancel := context.WithTimeout(wp.ctx, r.timeout)
+	defer cancel()
+
+	for _, method := range r.methods {
+		var err error
+		switch method {
+		case ReadyMethodStdout:
+			err = waitReadyStdout(ctx, signal, r.pattern)
+		case ReadyMethodTCP:
+			err = waitReadyConnect(ctx, w, r.interval)
+		case ReadyMethodHTTP:
+			err = waitReadyHTTP(ctx, w.endpoint, r.path, r.interval)
+		}
+		if err != nil {
+			return method, err
+		}
+	}
+	return strings.Join(r.methods, "+"), nil
+}
+
+func waitReadyStdout(ctx context.Context, signal *readySignal, pattern string) error {
+	select {
+	case <-signal.marker:
+		return nil
+	case <-signal.closed:
+		// The marker may have been the last line before stdout closed
+		select {
+		case <-signal.marker:
+			return nil
+		default:
+		}
+		return fmt.Errorf("worker exited before printing %q", pattern)
+	case <-ctx.Done():
+		return fmt.Errorf("no %q on stdout: %w", pattern, ctx.Err())
+	}
+}
+
+// waitReadyConnect waits for the worker's port or socket to accept connections
+func waitReadyConnect(ctx context.Context, w *Worker, interval time.Duration) error {
+	network := "tcp"
+	if w.socketPath != "" {
+		network = "unix"
+	}
+
+	var dialer net.Dialer
+	var lastErr error
+	for {
+		dialCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		conn, err := dialer.DialContext(dialCtx, network, w.address())
+		cancel()
+		if err == nil {
+			conn.Close()
+			return nil
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%s not accepting connections: %w", w.address(), lastErr)
+		case <-time.After(interval):
+		}
+	}
+}
+
+func waitReadyHTTP(ctx context.Context, endpoint, path string, interval time.Duration) error {
+	client, baseURL := NewEndpointClient(endpoint, 2*time.Second)
+	url := baseURL + path
+
+	var lastErr error
+	for {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			if resp.StatusCode >= 200 && resp.StatusCode < 300 {
+				return nil
+			}
+			err = fmt.Errorf("status %d", resp.StatusCode)
+		}
+		lastErr = err
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("GET %s not ready: %v", url, lastErr)
+		case <-time.After(interval):
+		}
+	}
+}
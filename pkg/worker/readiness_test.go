@@ -0,0 +1,117 @@
+package worker
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestParseReadyMethods(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", []string{ReadyMethodTCP}},
+		{"stdout", []string{ReadyMethodStdout}},
+		{"stdout+http", []string{ReadyMethodStdout, ReadyMethodHTTP}},
+		{" TCP , http ", []string{ReadyMethodTCP, ReadyMethodHTTP}},
+		{"tcp+tcp", []string{ReadyMethodTCP}},
+		{"bogus", []string{ReadyMethodTCP}},
+	}
+	for _, tt := range tests {
+		if got := parseReadyMethods(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseReadyMethods(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestWorkerReadiness(t *testing.T) {
+	t.Run("stdout marker", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_METHOD", "stdout")
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "5s")
+		cmd := createScript(t, `#!/bin/sh
+trap 'exit 0' TERM
+sleep 0.2
+echo "server listening"
+echo "BLASTRA_READY"
+while true; do sleep 0.1; done
+`)
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", cmd, nil)
+		if err != nil {
+			t.Fatalf("Expected worker to become ready, got %v", err)
+		}
+		defer pool.Shutdown()
+		if pool.GetWorkerEndpoint() == "" {
+			t.Error("Expected ready worker in rotation")
+		}
+	})
+
+	t.Run("custom pattern", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_METHOD", "stdout")
+		setEnv(t, "BLASTRA_WORKER_READY_PATTERN", "bundle loaded")
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "300ms")
+		cmd := createScript(t, `#!/bin/sh
+trap 'exit 0' TERM
+echo "BLASTRA_READY"
+while true; do sleep 0.1; done
+`)
+
+		if pool, err := StartWorkerPoolWithCommand(1, ".", cmd, nil); err == nil {
+			pool.Shutdown()
+			t.Fatal("Expected readiness to time out without the custom pattern")
+		}
+	})
+
+	t.Run("exit before marker fails fast", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_METHOD", "stdout")
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "10s")
+		cmd := createScript(t, `#!/bin/sh
+echo "boom" >&2
+exit 1
+`)
+
+		start := time.Now()
+		if pool, err := StartWorkerPoolWithCommand(1, ".", cmd, nil); err == nil {
+			pool.Shutdown()
+			t.Fatal("Expected readiness to fail")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Expected early failure, took %v", elapsed)
+		}
+	})
+}
+
+func TestWaitReadyHTTP(t *testing.T) {
+	var calls atomic.Int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// Not ready for the first probes, like a worker still importing its bundle
+		if calls.Add(1) < 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	if err := waitReadyHTTP(ctx, ts.URL, "/health", 10*time.Millisecond); err != nil {
+		t.Fatalf("Expected HTTP readiness, got %v", err)
+	}
+	if calls.Load() != 3 {
+		t.Errorf("Expected 3 probes, got %d", calls.Load())
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	notFound := httptest.NewServer(http.NotFoundHandler())
+	defer notFound.Close()
+	if err := waitReadyHTTP(ctx, notFound.URL, "/", 10*time.Millisecond); err == nil {
+		t.Error("Expected non-2xx responses to fail readiness")
+	}
+}
@@ -0,0 +1,201 @@
+package worker
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+var (
+	// ErrRestartUnsupported is returned when the pool has no local workers to restart
+	ErrRestartUnsupported = errors.New("rolling restart requires local workers")
+	// ErrRestartInProgress is returned when another rolling restart is already running
+	ErrRestartInProgress = errors.New("rolling restart already in progress")
+)
+
+// recycleSettings configures worker replacement, both on demand and by policy
+type recycleSettings struct {
+	maxRequests  int64         // recycle a worker after serving this many requests (0 disables)
+	maxAge       time.Duration // recycle a worker after this uptime (0 disables)
+	maxRSS       uint64        // recycle a worker whose process tree exceeds this RSS in bytes (0 disables)
+	interval     time.Duration // how often resource usage is sampled and policies are evaluated
+	drainTimeout time.Duration // how long to wait for in-flight requests of a replaced worker
+	stopTimeout  time.Duration // how long a replaced worker gets to exit before being killed
+}
+
+func loadRecycleSettings() recycleSettings {
+	return recycleSettings{
+		maxRequests:  int64(getEnvInt("BLASTRA_WORKER_MAX_REQUESTS", 0)),
+		maxAge:       getEnvDuration("BLASTRA_WORKER_MAX_AGE", 0),
+		maxRSS:       getEnvByteSize("BLASTRA_WORKER_MAX_RSS", 0),
+		interval:     getEnvDuration("BLASTRA_WORKER_RECYCLE_INTERVAL", 10*time.Second),
+		drainTimeout: getEnvDuration("BLASTRA_WORKER_DRAIN_TIMEOUT", 30*time.Second),
+		stopTimeout:  getEnvDuration("BLASTRA_WORKER_STOP_TIMEOUT", 10*time.Second),
+	}
+}
+
+// RollingRestart replaces every local worker one at a time: a replacement is
+// spawned and must become ready before it takes over the slot, then the old
+// worker is drained and stopped, so capacity never drops below the current
+// number of ready workers. Used to pick up a new server bundle or to recycle
+// leaky processes without restarting the Go server.
+func (wp *WorkerPool) RollingRestart(reason string) error {
+	if !wp.enabled || wp.external {
+		return ErrRestartUnsupported
+	}
+	if !wp.rolling.TryLock() {
+		return ErrRestartInProgress
+	}
+	defer wp.rolling.Unlock()
+
+	count := len(wp.snapshotWorkers())
+	log.WithFields(log.Fields{
+		"reason":  reason,
+		"workers": count,
+	}).Info("Starting rolling restart of workers")
+
+	for slot := 0; slot < count; slot++ {
+		workers := wp.snapshotWorkers()
+		if slot >= len(workers) {
+			return fmt.Errorf("worker pool shut down during rolling restart")
+		}
+		if err := wp.recycleWorker(workers[slot], reason); err != nil {
+			log.WithFields(log.Fields{
+				"slot":  slot,
+				"error": err.Error(),
+			}).Error("Rolling restart aborted")
+			return err
+		}
+	}
+
+	log.WithField("reason", reason).Info("Rolling restart completed")
+	return nil
+}
+
+// recycleWorker swaps a worker for a freshly spawned one, then drains and stops the old one
+func (wp *WorkerPool) recycleWorker(old *Worker, reason string) error {
+	r := wp.settings.recycle
+	usage := old.usage.get()
+	log.WithFields(log.Fields{
+		"slot":        old.slot,
+		"endpoint":    old.endpoint,
+		"reason":      reason,
+		"requests":    old.requests.Load(),
+		"uptime":      time.Since(old.startedAt).Round(time.Second).String(),
+		"rss_bytes":   usage.rssBytes,
+		"cpu_percent": fmt.Sprintf("%.1f", usage.cpuPercent),
+		"stderr_tail": strings.Join(old.stderrTail.snapshot(), "\n"),
+	}).Info("Recycling worker")
+
+	next, err := wp.spawnWorker(old.slot)
+	if err != nil {
+		return fmt.Errorf("failed to start replacement for worker %d: %w", old.slot, err)
+	}
+
+	if !wp.replaceWorker(old.slot, old, next) {
+		// The slot changed meanwhile (crash restart or shutdown), keep what is there
+		next.discard()
+		return fmt.Errorf("worker %d was replaced concurrently", old.slot)
+	}
+	old.setState(WorkerDraining)
+	wp.wg.Add(1)
+	go wp.monitorWorker(next)
+
+	// Let in-flight requests finish before stopping the old process
+	deadline := time.Now().Add(r.drainTimeout)
+	for old.inflight.Load() > 0 && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if n := old.inflight.Load(); n > 0 {
+		log.WithFields(log.Fields{
+			"slot":     old.slot,
+			"endpoint": old.endpoint,
+			"inflight": n,
+		}).Warn("Worker drain timed out, stopping with requests in flight")
+	}
+
+	wp.stopWorker(old)
+	log.WithFields(log.Fields{
+		"slot":         old.slot,
+		"old_endpoint": old.endpoint,
+		"endpoint":     next.endpoint,
+	}).Info("Worker recycled")
+	return nil
+}
+
+// stopWorker asks a monitored worker process to exit and kills it after the stop timeout
+func (wp *WorkerPool) stopWorker(w *Worker) {
+	w.setState(WorkerStopped)
+	w.kill()
+}
+
+// startRecycler periodically samples worker resource usage and recycles workers
+// that hit the max requests, max age or max RSS policy
+func (wp *WorkerPool) startRecycler() {
+	r := wp.settings.recycle
+	if r.interval <= 0 {
+		return
+	}
+	policies := r.maxRequests > 0 || r.maxAge > 0 || r.maxRSS > 0
+
+	go func() {
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-wp.ctx.Done():
+				return
+			case <-ticker.C:
+				wp.sampleUsage()
+				if policies {
+					wp.recycleByPolicy()
+				}
+			}
+		}
+	}()
+}
+
+func (wp *WorkerPool) recycleByPolicy() {
+	// Skip this round if a rolling restart is already replacing workers
+	if !wp.rolling.TryLock() {
+		return
+	}
+	defer wp.rolling.Unlock()
+
+	for _, w := range wp.snapshotWorkers() {
+		if wp.ctx.Err() != nil {
+			return
+		}
+		reason := wp.recycleReason(w)
+		if reason == "" {
+			continue
+		}
+		if err := wp.recycleWorker(w, reason); err != nil {
+			log.WithFields(log.Fields{
+				"slot":  w.slot,
+				"error": err.Error(),
+			}).Error("Failed to recycle worker")
+		}
+	}
+}
+
+// recycleReason returns why a worker should be recycled, or an empty string
+func (wp *WorkerPool) recycleReason(w *Worker) string {
+	r := wp.settings.recycle
+	if !w.isReady() {
+		return ""
+	}
+	if r.maxRequests > 0 && w.requests.Load() >= r.maxRequests {
+		return fmt.Sprintf("served %d requests (max %d)", w.requests.Load(), r.maxRequests)
+	}
+	if r.maxAge > 0 && time.Since(w.startedAt) >= r.maxAge {
+		return fmt.Sprintf("uptime exceeded max age %s", r.maxAge)
+	}
+	if rss := w.usage.get().rssBytes; r.maxRSS > 0 && rss > r.maxRSS {
+		return fmt.Sprintf("RSS %d bytes exceeded max %d bytes", rss, r.maxRSS)
+	}
+	return ""
+}
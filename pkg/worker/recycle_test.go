@@ -0,0 +1,168 @@
+package worker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func endpointsOf(wp *WorkerPool) map[string]bool {
+	endpoints := make(map[string]bool)
+	for _, w := range wp.snapshotWorkers() {
+		endpoints[w.endpoint] = true
+	}
+	return endpoints
+}
+
+func TestRollingRestart(t *testing.T) {
+	mockCmd := createScript(t, mockScript)
+
+	t.Run("replaces every worker", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+
+		pool, err := StartWorkerPoolWithCommand(2, ".", mockCmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+		wp := pool.(*WorkerPool)
+
+		before := endpointsOf(wp)
+		oldWorkers := wp.snapshotWorkers()
+
+		if err := pool.RollingRestart("test"); err != nil {
+			t.Fatalf("Rolling restart failed: %v", err)
+		}
+
+		for endpoint := range endpointsOf(wp) {
+			if before[endpoint] {
+				t.Errorf("Expected %s to be replaced", endpoint)
+			}
+		}
+		for _, w := range wp.snapshotWorkers() {
+			if !w.isReady() {
+				t.Errorf("Expected replacement worker %d to be ready", w.slot)
+			}
+		}
+		for _, w := range oldWorkers {
+			select {
+			case <-w.done:
+			default:
+				t.Errorf("Expected old worker %d to be stopped", w.slot)
+			}
+		}
+	})
+
+	t.Run("drains in-flight requests", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+		setEnv(t, "BLASTRA_WORKER_DRAIN_TIMEOUT", "5s")
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", mockCmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+		wp := pool.(*WorkerPool)
+		old := wp.snapshotWorkers()[0]
+
+		lease, err := pool.Acquire(context.Background(), "/")
+		if err != nil {
+			t.Fatalf("Failed to acquire lease: %v", err)
+		}
+
+		done := make(chan error, 1)
+		go func() { done <- pool.RollingRestart("test") }()
+
+		// The replacement takes over while the old worker keeps serving its request
+		deadline := time.Now().Add(5 * time.Second)
+		for old.getState() != WorkerDraining && time.Now().Before(deadline) {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if old.getState() != WorkerDraining {
+			t.Fatal("Expected old worker to be draining")
+		}
+		if endpoint := pool.GetWorkerEndpoint(); endpoint == "" || endpoint == old.endpoint {
+			t.Errorf("Expected replacement in rotation, got %q", endpoint)
+		}
+
+		select {
+		case <-done:
+			t.Fatal("Expected rolling restart to wait for the in-flight request")
+		case <-time.After(100 * time.Millisecond):
+		}
+
+		lease.Release(nil)
+		if err := <-done; err != nil {
+			t.Fatalf("Rolling restart failed: %v", err)
+		}
+	})
+
+	t.Run("max requests policy recycles worker", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+		setEnv(t, "BLASTRA_WORKER_MAX_REQUESTS", "2")
+		setEnv(t, "BLASTRA_WORKER_RECYCLE_INTERVAL", "20ms")
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", mockCmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+
+		first := pool.GetWorkerEndpoint()
+		for i := 0; i < 2; i++ {
+			lease, err := pool.Acquire(context.Background(), "/")
+			if err != nil {
+				t.Fatalf("Failed to acquire lease: %v", err)
+			}
+			lease.Release(nil)
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if endpoint := pool.GetWorkerEndpoint(); endpoint != "" && endpoint != first {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatal("Expected worker to be recycled after max requests")
+	})
+
+	t.Run("old worker gets the stop signal", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+		setEnv(t, "BLASTRA_WORKER_STOP_TIMEOUT", "10s")
+		marker := filepath.Join(t.TempDir(), "interrupted")
+		cmd := createScript(t, fmt.Sprintf(`#!/bin/sh
+trap '' TERM
+trap 'touch %s; exit 0' INT
+while true; do sleep 0.1; done
+`, marker))
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", cmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+
+		start := time.Now()
+		if err := pool.RollingRestart("test"); err != nil {
+			t.Fatalf("Rolling restart failed: %v", err)
+		}
+		if _, err := os.Stat(marker); err != nil {
+			t.Error("Expected old worker to be interrupted like on shutdown")
+		}
+		if elapsed := time.Since(start); elapsed > 5*time.Second {
+			t.Errorf("Expected old worker to exit on the signal, not be killed after the grace period (%v)", elapsed)
+		}
+	})
+
+	t.Run("external workers cannot be restarted", func(t *testing.T) {
+		wp := startExternalPool(t, "http://worker-a")
+		if err := wp.RollingRestart("test"); !errors.Is(err, ErrRestartUnsupported) {
+			t.Fatalf("Expected ErrRestartUnsupported, got %v", err)
+		}
+	})
+}
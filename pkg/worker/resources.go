@@ -0,0 +1,161 @@
+package worker
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// clockTicks is USER_HZ, the unit of CPU times in /proc/<pid>/stat. It is 100 on
+// every mainstream Linux architecture and not readable without cgo.
+const clockTicks = 100
+
+// resourceUsage is the last sampled resource usage of a worker process tree
+type resourceUsage struct {
+	rssBytes   uint64
+	cpuTicks   uint64
+	cpuPercent float64
+	sampledAt  time.Time
+}
+
+// usageTracker holds a worker's resource usage, guarded by its own mutex
+type usageTracker struct {
+	mu    sync.Mutex
+	usage resourceUsage
+}
+
+func (u *usageTracker) get() resourceUsage {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	return u.usage
+}
+
+// update stores a new sample, deriving CPU utilisation from the previous one
+func (u *usageTracker) update(rssBytes, cpuTicks uint64, at time.Time) {
+	u.mu.Lock()
+	defer u.mu.Unlock()
+	prev := u.usage
+	u.usage = resourceUsage{rssBytes: rssBytes, cpuTicks: cpuTicks, sampledAt: at}
+	if !prev.sampledAt.IsZero() && cpuTicks >= prev.cpuTicks {
+		if elapsed := at.Sub(prev.sampledAt).Seconds(); elapsed > 0 {
+			u.usage.cpuPercent = float64(cpuTicks-prev.cpuTicks) / clockTicks / elapsed * 100
+		}
+	}
+}
+
+// procStat is the subset of /proc/<pid>/stat used for worker accounting
+type procStat struct {
+	ppid     int
+	cpuTicks uint64 // utime + stime
+	rssPages uint64
+}
+
+// parseProcStat parses the content of /proc/<pid>/stat
+func parseProcStat(data string) (procStat, error) {
+	// The command name may contain spaces and parentheses, fields start after the last ')'
+	end := strings.LastIndexByte(data, ')')
+	if end < 0 {
+		return procStat{}, fmt.Errorf("malformed stat line")
+	}
+	fields := strings.Fields(data[end+1:])
+	// fields[0] is the state (field 3), so field N lives at index N-3
+	if len(fields) < 22 {
+		return procStat{}, fmt.Errorf("stat line has %d fields", len(fields))
+	}
+
+	ppid, err := strconv.Atoi(fields[1])
+	if err != nil {
+		return procStat{}, err
+	}
+	utime, err := strconv.ParseUint(fields[11], 10, 64)
+	if err != nil {
+		return procStat{}, err
+	}
+	stime, err := strconv.ParseUint(fields[12], 10, 64)
+	if err != nil {
+		return procStat{}, err
+	}
+	rss, err := strconv.ParseInt(fields[21], 10, 64)
+	if err != nil {
+		return procStat{}, err
+	}
+	if rss < 0 {
+		rss = 0
+	}
+	return procStat{ppid: ppid, cpuTicks: utime + stime, rssPages: uint64(rss)}, nil
+}
+
+// readProcTable reads the stat of every process under procRoot, keyed by pid
+func readProcTable(procRoot string) (map[int]procStat, error) {
+	entries, err := os.ReadDir(procRoot)
+	if err != nil {
+		return nil, err
+	}
+	table := make(map[int]procStat, len(entries))
+	for _, entry := range entries {
+		pid, err := strconv.Atoi(entry.Name())
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join(procRoot, entry.Name(), "stat"))
+		if err != nil {
+			continue // Process exited meanwhile
+		}
+		if stat, err := parseProcStat(string(data)); err == nil {
+			table[pid] = stat
+		}
+	}
+	return table, nil
+}
+
+// treeUsage sums RSS pages and CPU ticks over pid and all of its descendants.
+// Workers usually run the SSR server as a child of the blastra CLI, so the
+// worker process alone would miss most of the memory.
+func treeUsage(table map[int]procStat, pid int) (rssPages, cpuTicks uint64, ok bool) {
+	if _, ok := table[pid]; !ok {
+		return 0, 0, false
+	}
+	children := make(map[int][]int)
+	for child, stat := range table {
+		children[stat.ppid] = append(children[stat.ppid], child)
+	}
+
+	queue := []int{pid}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		stat := table[current]
+		rssPages += stat.rssPages
+		cpuTicks += stat.cpuTicks
+		queue = append(queue, children[current]...)
+	}
+	return rssPages, cpuTicks, true
+}
+
+// sampleUsage records the RSS and CPU usage of every local worker from /proc
+func (wp *WorkerPool) sampleUsage() {
+	table, err := readProcTable("/proc")
+	if err != nil {
+		log.Debugf("Worker resource sampling unavailable: %v", err)
+		return
+	}
+
+	now := time.Now()
+	pageSize := uint64(os.Getpagesize())
+	for _, w := range wp.snapshotWorkers() {
+		if w.cmd == nil || w.cmd.Process == nil {
+			continue
+		}
+		rssPages, cpuTicks, ok := treeUsage(table, w.cmd.Process.Pid)
+		if !ok {
+			continue
+		}
+		w.usage.update(rssPages*pageSize, cpuTicks, now)
+	}
+}
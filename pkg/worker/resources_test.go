@@ -0,0 +1,101 @@
+package worker
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestParseProcStat(t *testing.T) {
+	line := "4242 (node (worker) x) S 4200 4242 4242 0 -1 4194560 1 0 0 0 150 50 0 0 20 0 11 0 100 1000000 2048 18446744073709551615"
+	stat, err := parseProcStat(line)
+	if err != nil {
+		t.Fatalf("Failed to parse stat line: %v", err)
+	}
+	if stat.ppid != 4200 || stat.cpuTicks != 200 || stat.rssPages != 2048 {
+		t.Errorf("Unexpected stat %+v", stat)
+	}
+
+	if _, err := parseProcStat("4242 (node) S 1"); err == nil {
+		t.Error("Expected truncated stat line to fail")
+	}
+}
+
+func TestTreeUsage(t *testing.T) {
+	table := map[int]procStat{
+		1:  {ppid: 0, cpuTicks: 1000, rssPages: 1000},
+		10: {ppid: 1, cpuTicks: 10, rssPages: 100},  // CLI
+		11: {ppid: 10, cpuTicks: 20, rssPages: 200}, // SSR server
+		12: {ppid: 11, cpuTicks: 5, rssPages: 50},   // helper
+		20: {ppid: 1, cpuTicks: 99, rssPages: 999},  // unrelated
+	}
+
+	rss, cpu, ok := treeUsage(table, 10)
+	if !ok || rss != 350 || cpu != 35 {
+		t.Errorf("Expected tree usage 350 pages / 35 ticks, got %d / %d (ok=%v)", rss, cpu, ok)
+	}
+	if _, _, ok := treeUsage(table, 99); ok {
+		t.Error("Expected missing pid to report not ok")
+	}
+}
+
+func TestUsageTracker(t *testing.T) {
+	var u usageTracker
+	start := time.Now()
+	u.update(1024, 100, start)
+	if u.get().cpuPercent != 0 {
+		t.Error("Expected no CPU utilisation from a single sample")
+	}
+
+	// 50 ticks over one second is half a core
+	u.update(2048, 150, start.Add(time.Second))
+	usage := u.get()
+	if usage.rssBytes != 2048 || usage.cpuPercent != 50 {
+		t.Errorf("Unexpected usage %+v", usage)
+	}
+}
+
+func TestWorkerResourceLimits(t *testing.T) {
+	if _, err := os.Stat("/proc/self/stat"); err != nil {
+		t.Skip("procfs not available")
+	}
+	mockCmd := createScript(t, mockScript)
+
+	t.Run("samples usage", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", mockCmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+
+		pool.(*WorkerPool).sampleUsage()
+		statuses := pool.GetWorkerStatuses()
+		if len(statuses) != 1 || statuses[0].RSSBytes == 0 {
+			t.Errorf("Expected sampled RSS, got %+v", statuses)
+		}
+	})
+
+	t.Run("max RSS recycles worker", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+		setEnv(t, "BLASTRA_WORKER_MAX_RSS", "1K")
+		setEnv(t, "BLASTRA_WORKER_RECYCLE_INTERVAL", "20ms")
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", mockCmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+
+		first := pool.GetWorkerEndpoint()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if endpoint := pool.GetWorkerEndpoint(); endpoint != "" && endpoint != first {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatal("Expected worker to be recycled after exceeding max RSS")
+	})
+}
@@ -0,0 +1,145 @@
+package worker
+
+import (
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// monitorWorker waits for the worker process to exit and hands unexpected
+// exits over to the supervisor so the slot gets a replacement
+func (wp *WorkerPool) monitorWorker(w *Worker) {
+	defer wp.wg.Done()
+	err := w.cmd.Wait()
+	close(w.done)
+	dur := time.Since(w.startedAt)
+	pid := w.cmd.Process.Pid
+
+	// A stopped or draining worker was terminated on purpose (shutdown or replacement)
+	state := w.getState()
+	intentional := wp.ctx.Err() != nil || state == WorkerStopped || state == WorkerDraining
+	if !intentional {
+		w.setState(WorkerDown)
+		if err != nil {
+			code, sig := exitDetails(err)
+			log.WithFields(log.Fields{
+				"slot":        w.slot,
+				"address":     w.address(),
+				"pid":         pid,
+				"exit_error":  err.Error(),
+				"exit_code":   code,
+				"signal":      sig,
+				"duration_ms": dur.Milliseconds(),
+				"stderr_tail": strings.Join(w.stderrTail.snapshot(), "\n"),
+			}).Error("Worker exited with error")
+		} else {
+			log.WithFields(log.Fields{
+				"slot":        w.slot,
+				"address":     w.address(),
+				"pid":         pid,
+				"duration_ms": dur.Milliseconds(),
+			}).Info("Worker exited")
+		}
+	}
+	w.releaseAddress() // Release port or socket when worker exits
+
+	if !intentional && wp.settings.restartEnabled {
+		// A worker that stayed up for a whole window is considered healthy again
+		if dur >= wp.settings.restartWindow {
+			w.failures = 0
+		}
+		wp.restartWorker(w)
+	}
+}
+
+// restartWorker respawns the worker of a slot with exponential backoff.
+// Restarts are capped to maxRestarts per restartWindow: once the cap is hit the
+// slot is considered crash looping and stays out of rotation for a full window.
+func (wp *WorkerPool) restartWorker(prev *Worker) {
+	s := wp.settings
+	failures := prev.failures
+	history := pruneRestarts(prev.restarts, time.Now().Add(-s.restartWindow))
+
+	for {
+		delay := restartBackoff(s.restartBackoff, s.restartBackoffMax, failures)
+		if s.maxRestarts > 0 && len(history) >= s.maxRestarts {
+			log.WithFields(log.Fields{
+				"slot":         prev.slot,
+				"restarts":     len(history),
+				"window":       s.restartWindow.String(),
+				"stderr_tail":  strings.Join(prev.stderrTail.snapshot(), "\n"),
+				"max_restarts": s.maxRestarts,
+			}).Error("Worker crash loop detected, pausing restarts")
+			delay = s.restartWindow
+			history = nil
+		}
+
+		log.WithFields(log.Fields{
+			"slot":     prev.slot,
+			"attempt":  failures + 1,
+			"delay_ms": delay.Milliseconds(),
+		}).Warn("Restarting worker")
+
+		select {
+		case <-wp.ctx.Done():
+			return
+		case <-time.After(delay):
+		}
+
+		failures++
+		history = append(history, time.Now())
+
+		next, err := wp.spawnWorker(prev.slot)
+		if err != nil {
+			if wp.ctx.Err() != nil {
+				return
+			}
+			log.WithFields(log.Fields{
+				"slot":  prev.slot,
+				"error": err.Error(),
+			}).Error("Worker restart failed")
+			continue
+		}
+		next.restarts = history
+		next.failures = failures
+
+		if !wp.replaceWorker(prev.slot, prev, next) {
+			// Pool was shut down while the replacement was starting
+			next.discard()
+			return
+		}
+
+		wp.wg.Add(1)
+		go wp.monitorWorker(next)
+
+		log.WithFields(log.Fields{
+			"slot":     prev.slot,
+			"endpoint": next.endpoint,
+			"restarts": len(history),
+		}).Info("Worker restarted")
+		return
+	}
+}
+
+// restartBackoff returns base * 2^failures capped at max
+func restartBackoff(base, max time.Duration, failures int) time.Duration {
+	delay := base
+	for i := 0; i < failures && delay < max; i++ {
+		delay *= 2
+	}
+	if delay > max {
+		delay = max
+	}
+	return delay
+}
+
+func pruneRestarts(history []time.Time, since time.Time) []time.Time {
+	var out []time.Time
+	for _, t := range history {
+		if t.After(since) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
@@ -0,0 +1,138 @@
+package worker
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// Mock command that simulates a worker process crashing shortly after start
+const crashingScript = `#!/bin/sh
+sleep 0.3
+exit 1
+`
+
+func createScript(t *testing.T, content string) string {
+	t.Helper()
+	tmpfile, err := os.CreateTemp("", "mock-worker-*.sh")
+	if err != nil {
+		t.Fatalf("Failed to create mock script: %v", err)
+	}
+	tmpPath := tmpfile.Name()
+	t.Cleanup(func() { os.Remove(tmpPath) })
+	if _, err := tmpfile.WriteString(content); err != nil {
+		t.Fatalf("Failed to write mock script: %v", err)
+	}
+	if err := tmpfile.Close(); err != nil {
+		t.Fatalf("Failed to close mock script: %v", err)
+	}
+	if err := os.Chmod(tmpPath, 0755); err != nil {
+		t.Fatalf("Failed to set script permissions: %v", err)
+	}
+	return tmpPath
+}
+
+func setEnv(t *testing.T, key, value string) {
+	t.Helper()
+	prev, had := os.LookupEnv(key)
+	os.Setenv(key, value)
+	t.Cleanup(func() {
+		if had {
+			os.Setenv(key, prev)
+		} else {
+			os.Unsetenv(key)
+		}
+	})
+}
+
+func TestWorkerSupervisor(t *testing.T) {
+	t.Run("crashed worker is restarted", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+		setEnv(t, "BLASTRA_WORKER_RESTART_BACKOFF", "10ms")
+		mockCmd := createScript(t, crashingScript)
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", mockCmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+
+		first := pool.GetWorkerEndpoint()
+		if first == "" {
+			t.Fatal("Expected initial endpoint")
+		}
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if endpoint := pool.GetWorkerEndpoint(); endpoint != "" && endpoint != first {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatal("Expected crashed worker to be replaced with a new endpoint")
+	})
+
+	t.Run("down worker is out of rotation", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+		setEnv(t, "BLASTRA_WORKER_RESTART", "false")
+		mockCmd := createScript(t, crashingScript)
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", mockCmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if pool.GetWorkerEndpoint() == "" {
+				return
+			}
+			time.Sleep(20 * time.Millisecond)
+		}
+		t.Fatal("Expected crashed worker to be removed from rotation")
+	})
+
+	t.Run("crash loop caps restarts", func(t *testing.T) {
+		setEnv(t, "BLASTRA_WORKER_READY_TIMEOUT", "0")
+		setEnv(t, "BLASTRA_WORKER_RESTART_BACKOFF", "10ms")
+		setEnv(t, "BLASTRA_WORKER_MAX_RESTARTS", "2")
+		setEnv(t, "BLASTRA_WORKER_RESTART_WINDOW", "1m")
+		mockCmd := createScript(t, "#!/bin/sh\nexit 1\n")
+
+		pool, err := StartWorkerPoolWithCommand(1, ".", mockCmd, nil)
+		if err != nil {
+			t.Fatalf("Failed to create worker pool: %v", err)
+		}
+		defer pool.Shutdown()
+
+		wp := pool.(*WorkerPool)
+		time.Sleep(1500 * time.Millisecond)
+
+		w := wp.snapshotWorkers()[0]
+		if len(w.restarts) != 2 {
+			t.Errorf("Expected restarts to stop at 2, got %d", len(w.restarts))
+		}
+		if w.isReady() {
+			t.Error("Expected crash looping worker to stay out of rotation")
+		}
+	})
+}
+
+func TestRestartBackoff(t *testing.T) {
+	base := 100 * time.Millisecond
+	max := time.Second
+
+	cases := map[int]time.Duration{
+		0:  100 * time.Millisecond,
+		1:  200 * time.Millisecond,
+		3:  800 * time.Millisecond,
+		4:  time.Second,
+		10: time.Second,
+	}
+	for failures, want := range cases {
+		if got := restartBackoff(base, max, failures); got != want {
+			t.Errorf("restartBackoff(%d) = %v, want %v", failures, got, want)
+		}
+	}
+}
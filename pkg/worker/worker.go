@@ -5,7 +5,6 @@ import (
 	"context"
 	"fmt"
 	"io"
-	"net"
 	"os"
 	"os/exec"
 	"strconv"
@@ -16,37 +15,213 @@ import (
 	"time"
 
 	log "github.com/sirupsen/logrus"
+
+	"github.com/devthefuture-org/blastra/pkg/utils"
 )
 
 // IWorkerPool defines the interface for worker pools
 type IWorkerPool interface {
 	GetWorkerEndpoint() string
+	// Acquire leases a worker for the request identified by key; the lease
+	// must be released once the request completes
+	Acquire(ctx context.Context, key string) (*Lease, error)
+	GetWorkerStatuses() []WorkerStatus
+	RollingRestart(reason string) error
 	Shutdown()
 }
 
+// WorkerState describes where a worker is in its lifecycle
+type WorkerState int32
+
+const (
+	WorkerStarting WorkerState = iota
+	WorkerReady
+	WorkerUnhealthy
+	WorkerDraining
+	WorkerDown
+	WorkerStopped
+)
+
+func (s WorkerState) String() string {
+	switch s {
+	case WorkerStarting:
+		return "starting"
+	case WorkerReady:
+		return "ready"
+	case WorkerUnhealthy:
+		return "unhealthy"
+	case WorkerDraining:
+		return "draining"
+	case WorkerDown:
+		return "down"
+	case WorkerStopped:
+		return "stopped"
+	default:
+		return "unknown"
+	}
+}
+
 type Worker struct {
-	port     int
-	cmd      *exec.Cmd
-	endpoint string // Used for both local and external workers
+	slot       int
+	port       int
+	socketPath string // set instead of port when the worker listens on a Unix socket
+	cmd        *exec.Cmd
+	endpoint   string // Used for both local and external workers
+	state      atomic.Int32
+	startedAt  time.Time
+	stderrTail *ringBuffer
+	done       chan struct{} // closed once the monitored process has exited
+	stopGrace  time.Duration // how long the process gets to exit after workerStopSignal
+
+	// Load balancing
+	weight        int
+	currentWeight int // smooth weighted round robin state, guarded by the balancer
+	inflight      atomic.Int64
+	requests      atomic.Int64
+
+	// Restart bookkeeping carried over from the worker previously in this slot
+	restarts []time.Time
+	failures int
+
+	// Resource usage sampled from /proc
+	usage usageTracker
+
+	// Health tracking, guarded by healthMu
+	healthMu        sync.Mutex
+	healthFailures  int32
+	healthSuccesses int32
+	proxyErrors     int32
+	lastError       string
+}
+
+func (w *Worker) getState() WorkerState {
+	return WorkerState(w.state.Load())
+}
+
+func (w *Worker) setState(s WorkerState) {
+	w.state.Store(int32(s))
+}
+
+func (w *Worker) isReady() bool {
+	return w.getState() == WorkerReady
+}
+
+// workerStopSignal asks a worker process to exit gracefully, whatever the reason it is stopped
+var workerStopSignal os.Signal = os.Interrupt
+
+// kill sends workerStopSignal to the worker process and kills it if it hasn't
+// exited after its stop grace period. The process must be monitored, so done is closed on exit.
+func (w *Worker) kill() {
+	if w.cmd == nil || w.cmd.Process == nil {
+		return
+	}
+	_ = w.cmd.Process.Signal(workerStopSignal)
+	select {
+	case <-w.done:
+	case <-time.After(w.stopGrace):
+		log.WithFields(log.Fields{
+			"slot": w.slot,
+			"pid":  w.cmd.Process.Pid,
+		}).Warn("Worker did not stop in time, killing it")
+		_ = w.cmd.Process.Kill()
+		<-w.done
+	}
+}
+
+// discard terminates a worker that was never handed to monitorWorker and reaps its process
+func (w *Worker) discard() {
+	w.setState(WorkerStopped)
+	go func() {
+		_ = w.cmd.Wait()
+		close(w.done)
+	}()
+	w.kill()
+	w.releaseAddress()
 }
 
 type WorkerPool struct {
+	mu       sync.RWMutex
 	workers  []*Worker
-	counter  uint64
+	balancer Balancer
 	enabled  bool
+	external bool
 	cwd      string
+	ctx      context.Context
 	cancelFn context.CancelFunc
 	wg       sync.WaitGroup
 	command  string
 	args     []string
+	settings poolSettings
+	rolling  sync.Mutex // held while workers are being recycled
+
+	// Unix socket transport
+	socketDir string
+	socketSeq atomic.Int64
+
+	// Requests queued for a worker, woken up through capacity
+	waiting    atomic.Int64
+	capacityMu sync.Mutex
+	capacity   chan struct{}
 }
 
-// Keep track of used ports and last used port to ensure uniqueness across restarts
-var (
-	usedPorts    = make(map[int]bool)
-	lastUsedPort = 5173 // Start with base port - 1
-	usedPortsMux sync.Mutex
-)
+// poolSettings holds worker tuning read from the environment (env-driven to avoid API changes)
+type poolSettings struct {
+	streamStdio      bool
+	stderrTailLines  int
+	nodeOptionsExtra string
+	debugEnv         string
+	forceColor       bool
+	balancer         string
+	weights          string
+
+	// Worker addresses
+	transport   string
+	portRange   portRange
+	socketDir   string
+	portRetries int
+
+	// Supervision
+	restartEnabled    bool
+	restartBackoff    time.Duration
+	restartBackoffMax time.Duration
+	maxRestarts       int
+	restartWindow     time.Duration
+
+	ready   readySettings
+	health  healthSettings
+	lease   leaseSettings
+	recycle recycleSettings
+}
+
+func loadPoolSettings() poolSettings {
+	s := poolSettings{
+		streamStdio:       getEnvBool("BLASTRA_WORKER_STDIO_STREAM", false),
+		stderrTailLines:   getEnvInt("BLASTRA_WORKER_STDERR_TAIL_LINES", 200),
+		nodeOptionsExtra:  os.Getenv("BLASTRA_WORKER_NODE_OPTIONS"),
+		debugEnv:          os.Getenv("BLASTRA_WORKER_DEBUG"),
+		forceColor:        getEnvBool("BLASTRA_WORKER_FORCE_COLOR", true),
+		balancer:          os.Getenv("BLASTRA_WORKER_BALANCER"),
+		weights:           os.Getenv("BLASTRA_WORKER_WEIGHTS"),
+		transport:         strings.ToLower(getEnvString("BLASTRA_WORKER_TRANSPORT", TransportTCP)),
+		portRange:         getEnvPortRange("BLASTRA_WORKER_PORT_RANGE", defaultPortRange),
+		socketDir:         os.Getenv("BLASTRA_WORKER_SOCKET_DIR"),
+		portRetries:       getEnvInt("BLASTRA_WORKER_PORT_RETRIES", 3),
+		restartEnabled:    getEnvBool("BLASTRA_WORKER_RESTART", true),
+		restartBackoff:    getEnvDuration("BLASTRA_WORKER_RESTART_BACKOFF", 200*time.Millisecond),
+		restartBackoffMax: getEnvDuration("BLASTRA_WORKER_RESTART_BACKOFF_MAX", 30*time.Second),
+		maxRestarts:       getEnvInt("BLASTRA_WORKER_MAX_RESTARTS", 5),
+		restartWindow:     getEnvDuration("BLASTRA_WORKER_RESTART_WINDOW", time.Minute),
+		health:            loadHealthSettings(),
+		lease:             loadLeaseSettings(),
+		recycle:           loadRecycleSettings(),
+	}
+	if s.transport != TransportTCP && s.transport != TransportUnix {
+		log.Warnf("Unknown worker transport %q, falling back to %s", s.transport, TransportTCP)
+		s.transport = TransportTCP
+	}
+	s.ready = loadReadySettings(s.health.path)
+	return s
+}
 
 // Default values for backward compatibility
 var (
@@ -108,6 +283,13 @@ func getEnvBool(key string, def bool) bool {
 	}
 }
 
+func getEnvString(key string, def string) string {
+	if val := os.Getenv(key); val != "" {
+		return val
+	}
+	return def
+}
+
 func getEnvInt(key string, def int) int {
 	val := os.Getenv(key)
 	if val == "" {
@@ -130,6 +312,17 @@ func getEnvDuration(key string, def time.Duration) time.Duration {
 	return def
 }
 
+func getEnvByteSize(key string, def uint64) uint64 {
+	val := os.Getenv(key)
+	if val == "" {
+		return def
+	}
+	if n, err := utils.ParseByteSize(val); err == nil {
+		return n
+	}
+	return def
+}
+
 func exitDetails(err error) (code int, signal string) {
 	if ee, ok := err.(*exec.ExitError); ok {
 		if ws, ok := ee.Sys().(syscall.WaitStatus); ok {
@@ -143,35 +336,6 @@ func exitDetails(err error) (code int, signal string) {
 	return
 }
 
-func getNextAvailablePort(basePort int) int {
-	usedPortsMux.Lock()
-	defer usedPortsMux.Unlock()
-
-	// Always start from the last used port + 1 to ensure different ports after restart
-	port := lastUsedPort + 1
-	for usedPorts[port] {
-		port++
-	}
-	usedPorts[port] = true
-	lastUsedPort = port
-	return port
-}
-
-func releasePort(port int) {
-	usedPortsMux.Lock()
-	delete(usedPorts, port)
-	usedPortsMux.Unlock()
-}
-
-func releaseAllPorts() {
-	usedPortsMux.Lock()
-	for port := range usedPorts {
-		delete(usedPorts, port)
-	}
-	// Don't reset lastUsedPort to ensure new ports after restart
-	usedPortsMux.Unlock()
-}
-
 // StartWorkerPool initializes a worker pool with default command and args
 func StartWorkerPool(workerCount int, cwd string) (IWorkerPool, error) {
 	return StartWorkerPoolWithConfig(workerCount, cwd, defaultCommand, defaultArgs, nil)
@@ -187,17 +351,29 @@ func StartWorkerPoolWithConfig(workerCount int, cwd string, command string, args
 	// If external URLs are provided, create a worker pool with those URLs
 	if len(externalURLs) > 0 {
 		log.Debug("Using external worker URLs")
+		ctx, cancel := context.WithCancel(context.Background())
+		settings := loadPoolSettings()
 		wp := &WorkerPool{
-			enabled: true,
+			balancer: NewBalancer(settings.balancer),
+			enabled:  true,
+			external: true,
+			ctx:      ctx,
+			cancelFn: cancel,
+			settings: settings,
 		}
 
-		for _, url := range externalURLs {
+		weights := parseWeights(settings.weights, len(externalURLs))
+		for i, url := range externalURLs {
 			worker := &Worker{
+				slot:     i,
 				endpoint: url,
+				weight:   weights[i],
 			}
+			worker.setState(WorkerReady)
 			wp.workers = append(wp.workers, worker)
 		}
 
+		wp.startHealthChecks()
 		return wp, nil
 	}
 
@@ -207,223 +383,271 @@ func StartWorkerPoolWithConfig(workerCount int, cwd string, command string, args
 		return &WorkerPool{enabled: false}, nil
 	}
 
-	// Release all ports before starting new workers to ensure clean state
-	releaseAllPorts()
-
 	log.Debugf("Starting worker pool with %d workers", workerCount)
 	ctx, cancel := context.WithCancel(context.Background())
+	settings := loadPoolSettings()
 	wp := &WorkerPool{
+		balancer: NewBalancer(settings.balancer),
 		enabled:  true,
 		cwd:      cwd,
+		ctx:      ctx,
 		cancelFn: cancel,
 		command:  command,
 		args:     args,
+		settings: settings,
 	}
 
-	// Worker diagnostics configuration (env-driven to avoid API changes)
-	streamStdio := getEnvBool("BLASTRA_WORKER_STDIO_STREAM", false)
-	stderrTailLines := getEnvInt("BLASTRA_WORKER_STDERR_TAIL_LINES", 200)
-	readyPattern := os.Getenv("BLASTRA_WORKER_READY_PATTERN")
-	if readyPattern == "" {
-		readyPattern = "BLASTRA_READY"
-	}
-	readyTimeout := getEnvDuration("BLASTRA_WORKER_READY_TIMEOUT", 10*time.Second)
-	nodeOptionsExtra := os.Getenv("BLASTRA_WORKER_NODE_OPTIONS")
-	debugEnv := os.Getenv("BLASTRA_WORKER_DEBUG")
-	forceColor := getEnvBool("BLASTRA_WORKER_FORCE_COLOR", true)
-
-	for i := 0; i < workerCount; i++ {
-		port := getNextAvailablePort(5174)
-		log.Debugf("Starting worker on port %d", port)
-
-		cmd := exec.CommandContext(ctx, wp.command, wp.args...)
-		cmd.Dir = cwd
-
-		// Build environment
-		env := append(os.Environ(), "PORT="+strconv.Itoa(port))
-		if forceColor {
-			env = append(env, "FORCE_COLOR=1")
-		}
-		baseNodeOpts := "--enable-source-maps --trace-uncaught"
-		combinedNodeOpts := strings.TrimSpace(strings.Join([]string{os.Getenv("NODE_OPTIONS"), baseNodeOpts, nodeOptionsExtra}, " "))
-		if combinedNodeOpts != "" {
-			env = append(env, "NODE_OPTIONS="+combinedNodeOpts)
-		}
-		if debugEnv != "" {
-			env = append(env, "DEBUG="+debugEnv)
-		}
-		cmd.Env = env
-
-		// Always capture stdio to avoid deadlocks and keep diagnostics
-		stdoutPipe, err := cmd.StdoutPipe()
+	if settings.transport == TransportUnix {
+		dir, err := os.MkdirTemp(settings.socketDir, "blastra-workers-")
 		if err != nil {
 			cancel()
-			releaseAllPorts()
-			return nil, err
+			return nil, fmt.Errorf("failed to create worker socket directory: %w", err)
 		}
-		stderrPipe, err := cmd.StderrPipe()
+		wp.socketDir = dir
+		log.Debugf("Workers listen on Unix sockets in %s", dir)
+	}
+
+	for i := 0; i < workerCount; i++ {
+		worker, err := wp.spawnWorker(i)
 		if err != nil {
-			cancel()
-			releaseAllPorts()
-			return nil, err
-		}
+			// Stop any previously started workers
+			for _, w := range wp.workers {
+				w.setState(WorkerStopped)
+				w.kill()
+				w.releaseAddress()
+			}
 
-		startedAt := time.Now()
-		if err := cmd.Start(); err != nil {
+			// Cancel context to stop any in-flight operations
 			cancel()
-			releaseAllPorts()
-			log.Errorf("Failed to start worker on port %d: %v", port, err)
+			wp.removeSocketDir()
 			return nil, err
 		}
-		pid := cmd.Process.Pid
 
+		wp.workers = append(wp.workers, worker)
+		wp.wg.Add(1)
+		go wp.monitorWorker(worker)
+
+		// Short sleep to stagger starts
+		time.Sleep(100 * time.Millisecond)
+	}
+
+	log.Debug("All workers started successfully")
+	wp.startHealthChecks()
+	wp.startRecycler()
+	return wp, nil
+}
+
+// spawnWorker starts a worker process for the given slot and waits for it to become ready.
+// The returned worker is not monitored yet; the caller is responsible for starting monitorWorker.
+// A worker that dies because another process grabbed its port is retried on a different port.
+func (wp *WorkerPool) spawnWorker(slot int) (*Worker, error) {
+	retries := wp.settings.portRetries
+	for attempt := 0; ; attempt++ {
+		worker, conflict, err := wp.startWorker(slot)
+		if err == nil || !conflict || attempt >= retries || wp.ctx.Err() != nil {
+			return worker, err
+		}
 		log.WithFields(log.Fields{
-			"port": port,
-			"pid":  pid,
-			"cmd":  wp.command,
-			"args": strings.Join(wp.args, " "),
-			"cwd":  cwd,
-		}).Info("Worker started")
-
-		// stderr tail buffer
-		stderrTail := newRingBuffer(stderrTailLines)
-
-		// Stream stdout
-		go func(p, procPid int, r io.Reader) {
-			scanner := bufio.NewScanner(r)
-			for scanner.Scan() {
-				line := scanner.Text()
-				// optional streaming
-				if streamStdio || log.IsLevelEnabled(log.DebugLevel) {
-					log.WithFields(log.Fields{
-						"port":   p,
-						"pid":    procPid,
-						"stream": "stdout",
-					}).Debug(line)
-				}
-			}
-		}(port, pid, stdoutPipe)
-
-		// Stream stderr (always keep tail)
-		go func(p, procPid int, r io.Reader) {
-			scanner := bufio.NewScanner(r)
-			for scanner.Scan() {
-				line := scanner.Text()
-				stderrTail.add(line)
-				if streamStdio || log.IsLevelEnabled(log.DebugLevel) {
-					log.WithFields(log.Fields{
-						"port":   p,
-						"pid":    procPid,
-						"stream": "stderr",
-					}).Debug(line)
-				}
-			}
-		}(port, pid, stderrPipe)
-
-		// Wait for TCP readiness and fail on timeout
-		if readyTimeout > 0 {
-			probeInterval := getEnvDuration("BLASTRA_WORKER_PROBE_INTERVAL", 50*time.Millisecond)
-			deadline := time.Now().Add(readyTimeout)
-			ready := false
-			for time.Now().Before(deadline) {
-				conn, err := net.DialTimeout("tcp", "127.0.0.1:"+strconv.Itoa(port), 200*time.Millisecond)
-				if err == nil {
-					conn.Close()
-					ready = true
-					break
-				}
-				time.Sleep(probeInterval)
-			}
+			"slot":    slot,
+			"attempt": attempt + 1,
+			"error":   err.Error(),
+		}).Warn("Worker port taken by another process, retrying on another port")
+	}
+}
+
+// allocateAddress picks where a new worker listens: a fresh socket path or a free port
+func (wp *WorkerPool) allocateAddress(slot int) (port int, socketPath string, err error) {
+	if wp.settings.transport == TransportUnix {
+		socketPath, err = wp.newSocketPath(slot)
+		return 0, socketPath, err
+	}
+	port, err = allocatePort(wp.settings.portRange)
+	return port, "", err
+}
+
+// startWorker makes a single attempt at starting a worker. conflict reports that
+// the worker exited before becoming ready while its port is held by another process.
+func (wp *WorkerPool) startWorker(slot int) (worker *Worker, conflict bool, err error) {
+	s := wp.settings
+	port, socketPath, err := wp.allocateAddress(slot)
+	if err != nil {
+		log.Errorf("Failed to allocate worker address: %v", err)
+		return nil, false, err
+	}
+	worker = &Worker{
+		slot:       slot,
+		port:       port,
+		socketPath: socketPath,
+		weight:     1,
+		stderrTail: newRingBuffer(s.stderrTailLines),
+		done:       make(chan struct{}),
+		stopGrace:  s.recycle.stopTimeout,
+	}
+	if socketPath != "" {
+		worker.endpoint = UnixEndpointPrefix + socketPath
+	} else {
+		worker.endpoint = "http://localhost:" + strconv.Itoa(port)
+	}
+	log.Debugf("Starting worker on %s", worker.address())
+
+	cmd := exec.CommandContext(wp.ctx, wp.command, wp.args...)
+	cmd.Dir = wp.cwd
+	// Shutting the pool down stops workers like any other path does
+	cmd.Cancel = func() error { return cmd.Process.Signal(workerStopSignal) }
+	cmd.WaitDelay = s.recycle.stopTimeout
+	worker.cmd = cmd
+
+	// Build environment
+	env := os.Environ()
+	if socketPath != "" {
+		env = append(env, "BLASTRA_SOCKET="+socketPath)
+	} else {
+		env = append(env, "PORT="+strconv.Itoa(port))
+	}
+	if s.forceColor {
+		env = append(env, "FORCE_COLOR=1")
+	}
+	baseNodeOpts := "--enable-source-maps --trace-uncaught"
+	combinedNodeOpts := strings.TrimSpace(strings.Join([]string{os.Getenv("NODE_OPTIONS"), baseNodeOpts, s.nodeOptionsExtra}, " "))
+	if combinedNodeOpts != "" {
+		env = append(env, "NODE_OPTIONS="+combinedNodeOpts)
+	}
+	if s.debugEnv != "" {
+		env = append(env, "DEBUG="+s.debugEnv)
+	}
+	cmd.Env = env
 
-			if ready {
-				dur := time.Since(startedAt)
+	// Always capture stdio to avoid deadlocks and keep diagnostics
+	stdoutPipe, err := cmd.StdoutPipe()
+	if err != nil {
+		worker.releaseAddress()
+		return nil, false, err
+	}
+	stderrPipe, err := cmd.StderrPipe()
+	if err != nil {
+		worker.releaseAddress()
+		return nil, false, err
+	}
+
+	worker.startedAt = time.Now()
+	if err := cmd.Start(); err != nil {
+		worker.releaseAddress()
+		log.Errorf("Failed to start worker on %s: %v", worker.address(), err)
+		return nil, false, err
+	}
+	pid := cmd.Process.Pid
+
+	log.WithFields(log.Fields{
+		"slot":    slot,
+		"address": worker.address(),
+		"pid":     pid,
+		"cmd":     wp.command,
+		"args":    strings.Join(wp.args, " "),
+		"cwd":     wp.cwd,
+	}).Info("Worker started")
+
+	// Stream stdout, watching for the readiness marker
+	signal := newReadySignal()
+	go func(addr string, procPid int, r io.Reader) {
+		defer close(signal.closed)
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if strings.Contains(line, s.ready.pattern) {
+				signal.markReady()
+			}
+			// optional streaming
+			if s.streamStdio || log.IsLevelEnabled(log.DebugLevel) {
 				log.WithFields(log.Fields{
-					"port":     port,
-					"pid":      pid,
-					"ready_ms": dur.Milliseconds(),
-					"method":   "tcp",
-				}).Info("Worker is ready")
-			} else {
-				// Log error, terminate this worker, clean up and fail pool startup
+					"address": addr,
+					"pid":     procPid,
+					"stream":  "stdout",
+				}).Debug(line)
+			}
+		}
+	}(worker.address(), pid, stdoutPipe)
+
+	// Stream stderr (always keep tail)
+	go func(addr string, procPid int, r io.Reader) {
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan() {
+			line := scanner.Text()
+			worker.stderrTail.add(line)
+			if s.streamStdio || log.IsLevelEnabled(log.DebugLevel) {
 				log.WithFields(log.Fields{
-					"port":        port,
-					"pid":         pid,
-					"timeout":     readyTimeout.String(),
-					"method":      "tcp",
-					"stderr_tail": strings.Join(stderrTail.snapshot(), "\n"),
-				}).Error("Worker readiness TCP check timed out")
-
-				// Try graceful then forceful termination of current process
-				if cmd.Process != nil {
-					_ = cmd.Process.Signal(os.Interrupt)
-					time.Sleep(100 * time.Millisecond)
-					_ = cmd.Process.Kill()
-				}
-				releasePort(port)
-
-				// Stop any previously started workers
-				for _, w := range wp.workers {
-					if w.cmd != nil && w.cmd.Process != nil {
-						_ = w.cmd.Process.Signal(os.Interrupt)
-						time.Sleep(100 * time.Millisecond)
-						_ = w.cmd.Process.Kill()
-					}
-					if w.port > 0 {
-						releasePort(w.port)
-					}
-				}
-
-				// Cancel context to stop any in-flight operations
-				cancel()
-
-				return nil, fmt.Errorf("worker on port %d failed readiness within %s", port, readyTimeout)
+					"address": addr,
+					"pid":     procPid,
+					"stream":  "stderr",
+				}).Debug(line)
 			}
 		}
+	}(worker.address(), pid, stderrPipe)
+
+	worker.setState(WorkerStarting)
 
-		worker := &Worker{
-			port:     port,
-			cmd:      cmd,
-			endpoint: "http://localhost:" + strconv.Itoa(port),
+	// Wait for the configured readiness methods and fail on timeout
+	if s.ready.timeout > 0 {
+		method, err := wp.waitReady(worker, signal)
+		if err != nil {
+			// Log error, terminate this worker and clean up
+			log.WithFields(log.Fields{
+				"slot":        slot,
+				"address":     worker.address(),
+				"pid":         pid,
+				"timeout":     s.ready.timeout.String(),
+				"method":      method,
+				"error":       err.Error(),
+				"stderr_tail": strings.Join(worker.stderrTail.snapshot(), "\n"),
+			}).Error("Worker readiness check failed")
+
+			// The process is gone while someone else listens on its port: a port conflict
+			select {
+			case <-signal.closed:
+				conflict = socketPath == "" && !portAvailable(port)
+			default:
+			}
+
+			worker.discard()
+
+			return nil, conflict, fmt.Errorf("worker on %s failed %s readiness within %s: %w", worker.address(), method, s.ready.timeout, err)
 		}
 
-		wp.workers = append(wp.workers, worker)
-		wp.wg.Add(1)
+		log.WithFields(log.Fields{
+			"slot":     slot,
+			"address":  worker.address(),
+			"pid":      pid,
+			"ready_ms": time.Since(worker.startedAt).Milliseconds(),
+			"method":   method,
+		}).Info("Worker is ready")
+	}
 
-		// Monitor worker process
-		go func(w *Worker, started time.Time, tail *ringBuffer, procPid int) {
-			defer wp.wg.Done()
-			err := w.cmd.Wait()
-			dur := time.Since(started)
-
-			if ctx.Err() == nil { // Only log if not cancelled
-				if err != nil {
-					code, sig := exitDetails(err)
-					log.WithFields(log.Fields{
-						"port":        w.port,
-						"pid":         procPid,
-						"exit_error":  err.Error(),
-						"exit_code":   code,
-						"signal":      sig,
-						"duration_ms": dur.Milliseconds(),
-						"stderr_tail": strings.Join(tail.snapshot(), "\n"),
-					}).Error("Worker exited with error")
-				} else {
-					log.WithFields(log.Fields{
-						"port":        w.port,
-						"pid":         procPid,
-						"duration_ms": dur.Milliseconds(),
-					}).Info("Worker exited")
-				}
-			}
-			releasePort(w.port) // Release port when worker exits
-		}(worker, startedAt, stderrTail, pid)
+	worker.setState(WorkerReady)
+	return worker, false, nil
+}
 
-		// Short sleep to stagger starts
-		time.Sleep(100 * time.Millisecond)
+// replaceWorker atomically swaps the worker in a slot, returning false if the
+// slot no longer holds the expected worker (e.g. the pool was shut down)
+func (wp *WorkerPool) replaceWorker(slot int, prev, next *Worker) bool {
+	wp.mu.Lock()
+	defer wp.mu.Unlock()
+
+	if slot >= len(wp.workers) || wp.workers[slot] != prev {
+		return false
 	}
 
-	log.Debug("All workers started successfully")
-	return wp, nil
+	// Copy on write so readers holding the previous slice are unaffected
+	workers := make([]*Worker, len(wp.workers))
+	copy(workers, wp.workers)
+	workers[slot] = next
+	wp.workers = workers
+	wp.notifyCapacity()
+	return true
+}
+
+func (wp *WorkerPool) snapshotWorkers() []*Worker {
+	wp.mu.RLock()
+	defer wp.mu.RUnlock()
+	return wp.workers
 }
 
 func (wp *WorkerPool) Shutdown() {
@@ -431,13 +655,16 @@ func (wp *WorkerPool) Shutdown() {
 		return
 	}
 
-	// If we have no cancelFn, we're using external workers
-	if wp.cancelFn == nil {
+	if wp.external {
+		wp.cancelFn() // Stop health checks
 		log.Debug("External worker pool shutdown - no local workers to stop")
 		return
 	}
 
 	log.Debug("Shutting down worker pool")
+	for _, worker := range wp.snapshotWorkers() {
+		worker.setState(WorkerStopped)
+	}
 	wp.cancelFn() // Signal all workers to stop
 
 	// Create a channel to signal timeout
@@ -451,36 +678,60 @@ func (wp *WorkerPool) Shutdown() {
 	select {
 	case <-done:
 		log.Debug("All workers shut down successfully")
-	case <-time.After(2 * time.Second):
+	case <-time.After(wp.settings.recycle.stopTimeout + time.Second):
+		// Workers are killed after their stop grace period, this is a last resort
 		log.Warn("Worker shutdown timed out, forcefully terminating")
-		for _, worker := range wp.workers {
+		for _, worker := range wp.snapshotWorkers() {
 			if worker.cmd != nil && worker.cmd.Process != nil {
-				// Send SIGTERM first
-				worker.cmd.Process.Signal(os.Interrupt)
-				// Give it a short time to clean up
-				time.Sleep(100 * time.Millisecond)
-				// If still running, force kill
-				worker.cmd.Process.Kill()
-			}
-			if worker.port > 0 {
-				releasePort(worker.port)
+				_ = worker.cmd.Process.Kill()
 			}
+			worker.releaseAddress()
 		}
 		// Wait for cleanup after kill
 		wp.wg.Wait()
 	}
 
 	// Clear worker list
+	wp.mu.Lock()
 	wp.workers = nil
+	wp.mu.Unlock()
+	wp.removeSocketDir()
+}
+
+// removeSocketDir deletes the socket directory of a Unix socket pool
+func (wp *WorkerPool) removeSocketDir() {
+	if wp.socketDir != "" {
+		_ = os.RemoveAll(wp.socketDir)
+	}
 }
 
 func (wp *WorkerPool) GetWorkerEndpoint() string {
-	if !wp.enabled || len(wp.workers) == 0 {
-		log.Debug("No available workers in pool")
+	worker := wp.pickWorker("")
+	if worker == nil {
 		return ""
 	}
-	idx := atomic.AddUint64(&wp.counter, 1)
-	worker := wp.workers[int(idx)%len(wp.workers)]
 	log.Debugf("Dispatching request to worker endpoint: %s", worker.endpoint)
 	return worker.endpoint
 }
+
+// pickWorker lets the balancer choose among the workers currently in rotation
+func (wp *WorkerPool) pickWorker(key string) *Worker {
+	workers := wp.snapshotWorkers()
+	if !wp.enabled || len(workers) == 0 {
+		log.Debug("No available workers in pool")
+		return nil
+	}
+
+	candidates := make([]*Worker, 0, len(workers))
+	for _, worker := range workers {
+		if worker.isReady() {
+			candidates = append(candidates, worker)
+		}
+	}
+	if len(candidates) == 0 {
+		log.Debug("No ready workers in pool")
+		return nil
+	}
+
+	return wp.balancer.Pick(candidates, key)
+}